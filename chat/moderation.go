@@ -0,0 +1,41 @@
+package chat
+
+import (
+	"sync"
+	"time"
+)
+
+// mutes of rooms, keyed by room id then user id, they
+// are not persisted, as the hubs.
+var (
+	mutes   = map[uint]map[uint]time.Time{}
+	mutesMu sync.Mutex
+)
+
+// Mute drops chat messages of the user in the room
+// until `until`.
+func Mute(roomId uint, uid uint, until time.Time) {
+	mutesMu.Lock()
+	defer mutesMu.Unlock()
+	if mutes[roomId] == nil {
+		mutes[roomId] = map[uint]time.Time{}
+	}
+	mutes[roomId][uid] = until
+}
+
+func Unmute(roomId uint, uid uint) {
+	mutesMu.Lock()
+	defer mutesMu.Unlock()
+	delete(mutes[roomId], uid)
+}
+
+func IsMuted(roomId uint, uid uint) bool {
+	mutesMu.Lock()
+	defer mutesMu.Unlock()
+	until, ok := mutes[roomId][uid]
+	if ok && time.Now().After(until) {
+		delete(mutes[roomId], uid)
+		return false
+	}
+	return ok
+}
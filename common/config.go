@@ -10,6 +10,12 @@ import (
 )
 
 type ChocolateConfig struct {
+	Server struct {
+		// networks of the reverse proxies whose
+		// `X-Forwarded-For` is believed, empty trusts
+		// none and the peer address is the client ip.
+		TrustedProxies []string `yaml:"trusted-proxies"`
+	}
 	Jwt struct {
 		Key struct {
 			Public  string
@@ -21,11 +27,88 @@ type ChocolateConfig struct {
 		ApiAddr  string `yaml:"api-addr"`
 		RtmpAddr string `yaml:"rtmp-addr"`
 		HlsAddr  string `yaml:"hls-addr"`
-		Stats    struct {
+		// origin nodes of the cluster, the addrs above are
+		// used as the only node if it is empty.
+		Nodes []SrsNodeConfig `yaml:"nodes"`
+		Stats struct {
 			CacheNumber     uint `yaml:"cache-num"`
 			CollectInterval uint `yaml:"collect-interval"`
 		}
 	}
+	Reconcile struct {
+		// seconds, 0 disables the periodic reconciliation,
+		// rooms are still reconciled once on startup.
+		Interval uint `yaml:"interval"`
+		// seconds a streaming room may stay without any
+		// publisher before set idle, 0 means never.
+		IdleTimeout uint `yaml:"idle-timeout"`
+	}
+	Policy struct {
+		// seconds between checks, 0 disables the periodic
+		// checks, streams are still checked on publish.
+		Interval uint `yaml:"interval"`
+		// seconds between the warning and the cut-off.
+		Grace uint `yaml:"grace"`
+		// role name -> seconds a publish may last.
+		MaxDuration map[string]uint `yaml:"max-duration"`
+		MaxKbps     uint            `yaml:"max-kbps"`
+		MaxWidth    uint            `yaml:"max-width"`
+		MaxHeight   uint            `yaml:"max-height"`
+		// empty allows any codec.
+		VideoCodecs []string `yaml:"video-codecs"`
+		AudioCodecs []string `yaml:"audio-codecs"`
+		// `HH:MM` in local time, may span midnight.
+		QuietHours struct {
+			Start string `yaml:"start"`
+			End   string `yaml:"end"`
+		} `yaml:"quiet-hours"`
+		// roles no policy applies to.
+		ExemptRoles []string `yaml:"exempt-roles"`
+	}
+	Callbacks struct {
+		// networks srs callbacks may come from.
+		AllowedNetworks []string `yaml:"allowed-networks"`
+		// if set, callbacks must carry it as the `token`
+		// query, or sign the body with it by hmac-sha256
+		// in the `X-Chocolate-Signature` header.
+		Secret string `yaml:"secret"`
+	}
+	Permission struct {
+		// seconds, how long a permission decision is
+		// reused, changes made through the api drop
+		// the decisions affected right away.
+		DecisionCacheTTL uint `yaml:"decision-cache-ttl"`
+	}
+	Playback struct {
+		// seconds
+		SegmentTokenTTL uint `yaml:"segment-token-ttl"`
+		// seconds, how long a playback ticket identifies
+		// the viewer.
+		TicketTTL uint `yaml:"ticket-ttl"`
+		Cache     struct {
+			BudgetMB uint `yaml:"budget-mb"`
+			// milliseconds
+			PlaylistTTL uint `yaml:"playlist-ttl-ms"`
+			// seconds
+			SegmentTTL uint `yaml:"segment-ttl"`
+		}
+	}
+	Forward struct {
+		FFmpegPath    string `yaml:"ffmpeg-path"`
+		MaxRetries    uint   `yaml:"max-retries"`
+		RetryInterval uint   `yaml:"retry-interval"`
+		MaxPerRoom    uint   `yaml:"max-per-room"`
+	}
+}
+
+type SrsNodeConfig struct {
+	Name     string `yaml:"name"`
+	ApiAddr  string `yaml:"api-addr"`
+	HttpAddr string `yaml:"http-addr"`
+	RtmpAddr string `yaml:"rtmp-addr"`
+	// the rtmp address given to streamers, left empty
+	// to let clients guess it from the site address.
+	PublicRtmpAddr string `yaml:"public-rtmp-addr"`
 }
 
 var DEBUG bool
@@ -207,5 +207,6 @@ func VerifyBytes(payload []byte, sig []byte) bool {
 	if privKey == nil {
 		pubKey, privKey = readJwtKey()
 	}
-	return ed25519.Verify(*pubKey, payload, sig)
+	checksum := sha256.Sum256(payload)
+	return ed25519.Verify(*pubKey, checksum[:], sig)
 }
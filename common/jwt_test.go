@@ -29,3 +29,10 @@ func TestJwt(t *testing.T) {
 	assert(payload.User, payloadDecrypted.User, "User", t)
 	assert(payload.Username, payloadDecrypted.Username, "Username", t)
 }
+
+func TestSignBytes(t *testing.T) {
+	payload := []byte("r=1,u=2,e=3")
+	sig := SignBytes(payload)
+	assert(true, VerifyBytes(payload, sig), "VerifyBytes", t)
+	assert(false, VerifyBytes([]byte("r=1,u=2,e=4"), sig), "VerifyBytes tampered", t)
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// CreateSignedToken encodes the payload along with
+// its signature into an url-safe string, the payload
+// is NOT encrypted, do not put secrets in it.
+func CreateSignedToken(payload string) string {
+	sig := SignBytes([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// VerifySignedToken returns the payload if the
+// signature of the token is valid.
+func VerifySignedToken(token string) (string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", false
+	}
+	if !VerifyBytes(payload, sig) {
+		return "", false
+	}
+	return string(payload), true
+}
@@ -40,6 +40,45 @@ const (
 	RequestRoomNotPublishingStream
 	RequestTimeRangeTooLong
 	RequestAutoCompletePrefixTooLong
+	RequestForwardDestinationNotFound
+	RequestInvalidForwardDestination
+	RequestForwardDestinationCountReachedMax
+	RequestPlaybackTokenInvalid
+	RequestBroadcastNotFound
+	RequestScheduleNotFound
+	RequestInvalidSchedule
+	RequestScheduleCountReachedMax
+	RequestCalendarTokenInvalid
+	RequestCategoryNotFound
+	RequestCategoryExists
+	RequestInvalidCategoryName
+	RequestInvalidRoomTags
+	RequestRoomDescriptionTooLong
+	RequestUnknownRoomVisibility
+	RequestRoomInviteNotFound
+	RequestRoomInviteExpired
+	RequestInvalidRoomInvite
+	RequestRoomInviteCountReachedMax
+	RequestUnknownRoomMemberRole
+	RequestRoomMemberNotFound
+	RequestInvalidRoomMember
+	RequestMissingRoomAbility
+	RequestInvalidChatMute
+	RequestInvalidPermissionItem
+	RequestInvalidAccessPolicy
+	RequestInvalidRoomPassword
+	RequestWrongRoomPassword
+	RequestRoomPasswordRequired
+	RequestTooManyPasswordAttempts
+	RequestRoomViewerCapReached
+	RequestPlaybackSessionCapReached
+	RequestInvalidIPBan
+	RequestIPBanNotFound
+	RequestIPBanned
+	RequestInvalidRoomTransfer
+	RequestRoomTransferNotFound
+	RequestInvalidRoomTemplate
+	RequestRoomTemplateNotFound
 )
 
 const (
@@ -107,4 +146,62 @@ const (
 	DatabasePermItemAutoComepelteLookupError
 
 	DatabaseCommitTransactionError
+
+	DatabaseCreateForwardDestinationError
+	DatabaseListForwardDestinationsError
+	DatabaseUpdateForwardDestinationError
+	DatabaseDeleteForwardDestinationError
+
+	DatabaseUpdateRoomSrsNodeError
+	DatabaseSetRoomViewersError
+
+	DatabaseCreateBroadcastError
+	DatabaseUpdateBroadcastError
+	DatabaseListBroadcastsError
+
+	DatabaseCreateScheduleError
+	DatabaseUpdateScheduleError
+	DatabaseDeleteScheduleError
+	DatabaseListSchedulesError
+	DatabaseFollowRoomError
+	DatabaseListFollowsError
+
+	DatabaseCreateCategoryError
+	DatabaseUpdateCategoryError
+	DatabaseDeleteCategoryError
+	DatabaseListCategoriesError
+	DatabaseUpdateRoomTagsError
+	DatabaseListRoomTagsError
+	DatabaseUpdateRoomDescriptionError
+	DatabaseUpdateRoomCategoryError
+	DatabaseUpdateRoomVisibilityError
+
+	DatabaseCreateRoomInviteError
+	DatabaseListRoomInvitesError
+	DatabaseDeleteRoomInviteError
+	DatabaseRedeemRoomInviteError
+
+	DatabaseListRoomMembersError
+	DatabaseUpdateRoomMemberError
+
+	DatabaseUpdateRoomAccessPolicyError
+	DatabaseSumUserWatchTimeError
+
+	DatabaseUpdateRoomPasswordError
+	DatabaseUpdateRoomMaxViewersError
+
+	DatabaseCreateIPBanError
+	DatabaseListIPBansError
+	DatabaseDeleteIPBanError
+	DatabaseUpdateIPBanHitsError
+
+	DatabaseCreateRoomTransferError
+	DatabaseListRoomTransfersError
+	DatabaseDeleteRoomTransferError
+	DatabaseTransferRoomError
+	DatabaseCreateRoomTemplateError
+	DatabaseListRoomTemplatesError
+	DatabaseDeleteRoomTemplateError
+
+	DatabaseUpdateCalendarTokenError
 )
@@ -0,0 +1,104 @@
+package forward
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sheey11/chocolate/common"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	common.HookPostConfigLoad(func(cfg *common.ChocolateConfig) {
+		if cfg.Forward.FFmpegPath != "" {
+			ffmpegPath = cfg.Forward.FFmpegPath
+		}
+		if cfg.Forward.MaxRetries != 0 {
+			maxRetries = cfg.Forward.MaxRetries
+		}
+		if cfg.Forward.RetryInterval != 0 {
+			retryInterval = time.Second * time.Duration(cfg.Forward.RetryInterval)
+		}
+	})
+}
+
+// rooms -> destinations -> relay
+var relays = map[uint]map[uint]Relay{}
+var mu = sync.Mutex{}
+
+// Start relays the stream of given room from source to
+// the destination, a relay already running for the
+// destination will be replaced.
+func Start(roomId uint, destId uint, source string, destination string) {
+	relay := NewRelay(source, destination)
+
+	mu.Lock()
+	room, ok := relays[roomId]
+	if !ok {
+		room = map[uint]Relay{}
+		relays[roomId] = room
+	}
+	old := room[destId]
+	room[destId] = relay
+	mu.Unlock()
+
+	if old != nil {
+		old.Stop()
+	}
+	relay.Start()
+	logrus.WithField("room_id", roomId).WithField("destination_id", destId).Debug("forward relay started")
+}
+
+func Stop(roomId uint, destId uint) {
+	mu.Lock()
+	var relay Relay
+	if room, ok := relays[roomId]; ok {
+		relay = room[destId]
+		delete(room, destId)
+		if len(room) == 0 {
+			delete(relays, roomId)
+		}
+	}
+	mu.Unlock()
+
+	if relay != nil {
+		relay.Stop()
+	}
+}
+
+// StopRoom stops every relay of the room, it is
+// called when the room stops publishing.
+func StopRoom(roomId uint) {
+	mu.Lock()
+	room := relays[roomId]
+	delete(relays, roomId)
+	mu.Unlock()
+
+	for _, relay := range room {
+		relay.Stop()
+	}
+}
+
+type DestinationStatus struct {
+	Status Status  `json:"status"`
+	Error  *string `json:"error"`
+}
+
+func GetStatus(roomId uint, destId uint) DestinationStatus {
+	mu.Lock()
+	var relay Relay
+	if room, ok := relays[roomId]; ok {
+		relay = room[destId]
+	}
+	mu.Unlock()
+
+	if relay == nil {
+		return DestinationStatus{Status: StatusIdle}
+	}
+	result := DestinationStatus{Status: relay.Status()}
+	if err := relay.LastError(); err != nil {
+		msg := err.Error()
+		result.Error = &msg
+	}
+	return result
+}
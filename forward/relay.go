@@ -0,0 +1,215 @@
+package forward
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type Status string
+
+const (
+	StatusIdle       Status = "idle"
+	StatusConnecting Status = "connecting"
+	StatusRunning    Status = "running"
+	StatusFailed     Status = "failed"
+	StatusStopped    Status = "stopped"
+)
+
+// Relay pulls the stream from `source` and pushes
+// it to `destination`. Implementations must be
+// safe to call from multiple goroutines.
+type Relay interface {
+	// Start is non-blocking, the relay keeps
+	// running until Stop is called or it gives up
+	// after several failures.
+	Start()
+	Stop()
+	Status() Status
+	LastError() error
+}
+
+type RelayFactory func(source string, destination string) Relay
+
+// replace this to use another relay implementation,
+// e.g. in tests.
+var NewRelay RelayFactory = newFFmpegRelay
+
+var (
+	ffmpegPath    = "ffmpeg"
+	maxRetries    = uint(5)
+	retryInterval = 3 * time.Second
+	// a process running longer than this is considered
+	// healthy, the retry counter will be reset.
+	healthyDuration = 30 * time.Second
+)
+
+type ffmpegRelay struct {
+	source      string
+	destination string
+
+	mu      sync.Mutex
+	status  Status
+	lastErr error
+	cmd     *exec.Cmd
+	stop    chan struct{}
+}
+
+func newFFmpegRelay(source string, destination string) Relay {
+	return &ffmpegRelay{
+		source:      source,
+		destination: destination,
+		status:      StatusIdle,
+	}
+}
+
+func (r *ffmpegRelay) Start() {
+	r.mu.Lock()
+	if r.stop != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.stop = make(chan struct{})
+	r.status = StatusConnecting
+	r.lastErr = nil
+	r.mu.Unlock()
+
+	go r.run()
+}
+
+func (r *ffmpegRelay) run() {
+	var retries uint = 0
+	for {
+		started := time.Now()
+		err := r.runOnce()
+
+		select {
+		case <-r.stop:
+			r.setStatus(StatusStopped, nil)
+			return
+		default:
+		}
+
+		if time.Since(started) > healthyDuration {
+			retries = 0
+		}
+		retries++
+		logrus.
+			WithError(err).
+			WithField("destination", maskDestination(r.destination)).
+			WithField("retries", retries).
+			Warn("forward relay exited unexpectedly")
+
+		if retries > maxRetries {
+			r.setStatus(StatusFailed, err)
+			return
+		}
+		r.setStatus(StatusConnecting, err)
+
+		select {
+		case <-r.stop:
+			r.setStatus(StatusStopped, nil)
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (r *ffmpegRelay) runOnce() error {
+	stderr := bytes.Buffer{}
+	// the destination carries the stream key, the command
+	// must never be logged or reported.
+	cmd := exec.Command(ffmpegPath,
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", r.source,
+		"-c", "copy",
+		"-f", "flv",
+		r.destination,
+	)
+	cmd.Stderr = &stderr
+
+	r.mu.Lock()
+	select {
+	case <-r.stop:
+		r.mu.Unlock()
+		return nil
+	default:
+	}
+	if err := cmd.Start(); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.cmd = cmd
+	r.status = StatusRunning
+	r.mu.Unlock()
+
+	err := cmd.Wait()
+
+	r.mu.Lock()
+	r.cmd = nil
+	r.mu.Unlock()
+
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		lines := strings.Split(msg, "\n")
+		// ffmpeg prefixes errors with the url, the error is
+		// logged and shown to the owner.
+		line := strings.ReplaceAll(lines[len(lines)-1], r.destination, maskDestination(r.destination))
+		return errors.New(line)
+	}
+	if err == nil {
+		return errors.New("relay process exited")
+	}
+	return err
+}
+
+func (r *ffmpegRelay) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop == nil {
+		return
+	}
+	select {
+	case <-r.stop:
+		return
+	default:
+		close(r.stop)
+	}
+	if r.cmd != nil && r.cmd.Process != nil {
+		r.cmd.Process.Kill()
+	}
+	r.status = StatusStopped
+}
+
+func (r *ffmpegRelay) setStatus(status Status, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+	r.lastErr = err
+}
+
+func (r *ffmpegRelay) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+func (r *ffmpegRelay) LastError() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastErr
+}
+
+// stream keys must never appear in logs.
+func maskDestination(dest string) string {
+	i := strings.LastIndex(dest, "/")
+	if i < 0 || i == len(dest)-1 {
+		return dest
+	}
+	return dest[:i+1] + "***"
+}
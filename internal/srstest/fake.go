@@ -0,0 +1,195 @@
+package srstest
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/sheey11/chocolate/srs"
+)
+
+// FakeServer is an in-process srs keeping streams and
+// clients in memory, for tests to substitute the
+// default server by `srs.SetDefault` or nodes by
+// `srs.SetNodes`.
+type FakeServer struct {
+	mu      sync.Mutex
+	streams map[string]*srs.Stream
+	clients map[string]*srs.Client
+	kicked  []string
+	reloads int
+
+	// ServerID is reported as `server` by the api.
+	ServerID string
+	// HttpAddr is where the http server is, tests may
+	// point it to a `httptest.Server`.
+	HttpAddr string
+	RtmpAddr string
+	// RtcHandler answers sdp exchanges, the exchange
+	// fails if it is nil.
+	RtcHandler func(kind srs.RtcKind, query url.Values, offer []byte) (*srs.RtcAnswer, error)
+}
+
+var _ srs.Server = (*FakeServer)(nil)
+
+func NewFakeServer() *FakeServer {
+	return &FakeServer{
+		streams:  map[string]*srs.Stream{},
+		clients:  map[string]*srs.Client{},
+		HttpAddr: "127.0.0.1:8080",
+		RtmpAddr: "127.0.0.1:1935",
+	}
+}
+
+func (f *FakeServer) AddStream(stream srs.Stream) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.streams[stream.ID] = &stream
+}
+
+// AddClient adds a client of stream `client.StreamID`,
+// a publishing client makes the stream active.
+func (f *FakeServer) AddClient(client srs.Client) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.clients[client.ID] = &client
+	if stream, ok := f.streams[client.StreamID]; ok {
+		stream.ClientCount++
+		if client.Publish {
+			stream.Publish.Active = true
+			stream.Publish.CID = client.ID
+		}
+	}
+}
+
+// Kicked returns ids of the kicked clients in order.
+func (f *FakeServer) Kicked() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.kicked...)
+}
+
+func (f *FakeServer) Reloads() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reloads
+}
+
+func (f *FakeServer) HttpUrl(path string) string {
+	base := strings.TrimSuffix(f.HttpAddr, "/")
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "http://" + base
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return base + path
+}
+
+func (f *FakeServer) RtmpUrl(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		path = "/" + path
+	}
+	return "rtmp://" + f.RtmpAddr + path
+}
+
+func (f *FakeServer) GetVersion() (*srs.Version, error) {
+	return &srs.Version{ServerInfo: srs.ServerInfo{ServerID: f.ServerID}, Major: 5}, nil
+}
+
+func (f *FakeServer) GetSummaries() (*srs.Summaries, error) {
+	return &srs.Summaries{}, nil
+}
+
+func (f *FakeServer) GetMemInfo() (*srs.MemInfo, error) {
+	return &srs.MemInfo{}, nil
+}
+
+func (f *FakeServer) GetFeatures() (*srs.Features, error) {
+	return &srs.Features{}, nil
+}
+
+func (f *FakeServer) GetVHosts() (*srs.VHosts, error) {
+	return &srs.VHosts{}, nil
+}
+
+func (f *FakeServer) GetStreams() (*srs.Streams, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	streams := &srs.Streams{StreamList: []srs.Stream{}}
+	for _, stream := range f.streams {
+		streams.StreamList = append(streams.StreamList, *stream)
+	}
+	return streams, nil
+}
+
+func (f *FakeServer) GetStream(id string) (*srs.Stream, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stream, ok := f.streams[id]
+	if !ok {
+		return nil, srs.ErrNotFound
+	}
+	s := *stream
+	return &s, nil
+}
+
+func (f *FakeServer) GetClients() (*srs.Clients, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	clients := &srs.Clients{ClientList: []srs.Client{}}
+	for _, client := range f.clients {
+		clients.ClientList = append(clients.ClientList, *client)
+	}
+	return clients, nil
+}
+
+func (f *FakeServer) GetClient(id string) (*srs.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	client, ok := f.clients[id]
+	if !ok {
+		return nil, srs.ErrNotFound
+	}
+	c := *client
+	return &c, nil
+}
+
+func (f *FakeServer) KickClient(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	client, ok := f.clients[id]
+	if !ok {
+		return srs.ErrNotFound
+	}
+	delete(f.clients, id)
+	f.kicked = append(f.kicked, id)
+	if stream, ok := f.streams[client.StreamID]; ok {
+		stream.ClientCount--
+		if client.Publish {
+			stream.Publish.Active = false
+			stream.Publish.CID = ""
+		}
+	}
+	return nil
+}
+
+func (f *FakeServer) Reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reloads++
+	return nil
+}
+
+func (f *FakeServer) ExchangeSdp(kind srs.RtcKind, query url.Values, offer []byte) (*srs.RtcAnswer, error) {
+	if f.RtcHandler == nil {
+		return nil, errors.New("fake srs does not support rtc")
+	}
+	return f.RtcHandler(kind, query, offer)
+}
+
+func (f *FakeServer) TeardownRtcSession(kind srs.RtcKind, query url.Values) (int, error) {
+	return http.StatusOK, nil
+}
@@ -0,0 +1,28 @@
+package srstest
+
+import (
+	"testing"
+
+	"github.com/sheey11/chocolate/srs"
+)
+
+func TestFakeServerKick(t *testing.T) {
+	fake := NewFakeServer()
+	fake.AddStream(srs.Stream{ID: "st1", Name: "1"})
+	fake.AddClient(srs.Client{ID: "c1", StreamID: "st1", Publish: true})
+	fake.AddClient(srs.Client{ID: "c2", StreamID: "st1"})
+
+	srs.SetDefault(fake)
+	defer srs.SetDefault(nil)
+
+	if err := srs.Default().KickClient("c1"); err != nil {
+		t.Fatal(err)
+	}
+	stream, _ := fake.GetStream("st1")
+	if stream.Publish.Active || stream.ClientCount != 1 {
+		t.Fatalf("unexpected stream after kick %+v", stream)
+	}
+	if kicked := fake.Kicked(); len(kicked) != 1 || kicked[0] != "c1" {
+		t.Fatalf("unexpected kicked clients %v", kicked)
+	}
+}
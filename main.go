@@ -11,6 +11,7 @@ import (
 	"github.com/sheey11/chocolate/middleware"
 	"github.com/sheey11/chocolate/models"
 	"github.com/sheey11/chocolate/routes"
+	"github.com/sheey11/chocolate/service"
 	"github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
 )
@@ -49,8 +50,17 @@ func startServer(addr string, connStr string) {
 	if err != nil {
 		logrus.WithError(err).Fatal("connect database failed")
 	}
+	service.StartReconciler()
+	service.StartPolicyEnforcer()
+	service.StartPermissionItemJanitor()
+	service.StartIPBanRefresher()
 
 	engine = gin.New()
+	// client ips decide bans and rate limits, so only
+	// proxies configured may tell them.
+	if err := engine.SetTrustedProxies(common.Config.Server.TrustedProxies); err != nil {
+		logrus.WithError(err).Fatal("bad trusted proxies")
+	}
 	engine.Use(gin.Recovery())
 	engine.Use(middleware.Cors())
 	engine.Use(middleware.Log())
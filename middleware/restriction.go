@@ -9,6 +9,12 @@ import (
 )
 
 func IPRestriction(cidrs []string) gin.HandlerFunc {
+	return IPRestrictionWithRejection(cidrs, nil)
+}
+
+// IPRestrictionWithRejection calls `onReject`, if not nil,
+// with requests from outside `cidrs` before aborting them.
+func IPRestrictionWithRejection(cidrs []string, onReject func(c *gin.Context)) gin.HandlerFunc {
 	masks := make([]*net.IPNet, len(cidrs))
 	for i, cidr := range cidrs {
 		_, mask, err := net.ParseCIDR(cidr)
@@ -29,6 +35,9 @@ func IPRestriction(cidrs []string) gin.HandlerFunc {
 		}
 
 		if !allowed {
+			if onReject != nil {
+				onReject(c)
+			}
 			c.Status(http.StatusForbidden)
 			c.Abort()
 		}
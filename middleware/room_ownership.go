@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sheey11/chocolate/common"
 	"github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
 	"github.com/sheey11/chocolate/service"
 	"github.com/sirupsen/logrus"
 )
@@ -47,3 +48,42 @@ func RoomOwnershipRequired(paramName string) gin.HandlerFunc {
 		}
 	}
 }
+
+// RoomAbilityRequired authorizes by the room roles of
+// the user, the owner and admins hold every ability.
+// Must be used after AuthRequired.
+func RoomAbilityRequired(paramName string, ability models.RoomAbilities) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param(paramName))
+		if err != nil || id < 0 {
+			c.Abort()
+			c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidRoomID, "bad id parameter"))
+			return
+		}
+
+		room, err := service.GetRoomByID(uint(id))
+		if err != nil {
+			c.Abort()
+			if rerr, ok := err.(errors.RequestError); ok {
+				c.JSON(http.StatusNotFound, rerr.ToResponse())
+			} else {
+				logrus.WithError(err).Error("error retriving room")
+				c.JSON(http.StatusInternalServerError, common.SampleResponse(http.StatusInternalServerError, "internal server error"))
+			}
+			return
+		}
+
+		abilities, err := service.GetRoomAbilities(room, service.GetUserFromContext(c))
+		if err != nil {
+			logrus.WithError(err).Error("error retriving room abilities")
+			c.Abort()
+			c.JSON(http.StatusInternalServerError, common.SampleResponse(http.StatusInternalServerError, "internal server error"))
+			return
+		}
+		if !abilities.Covers(ability) {
+			c.Abort()
+			c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestMissingRoomAbility, "you are not allowed to do this to the room"))
+			return
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BroadcastEndReason string
+
+const (
+	BroadcastEndReasonLive      BroadcastEndReason = ""
+	BroadcastEndReasonUnpublish BroadcastEndReason = "unpublish"
+	BroadcastEndReasonCutOff    BroadcastEndReason = "cut-off"
+	// the publisher is gone without unpublishing, found
+	// by the reconciler or the next publish.
+	BroadcastEndReasonLost BroadcastEndReason = "lost"
+)
+
+// Broadcast is a single publish of a room, from the
+// publish callback to the unpublish or cut-off.
+type Broadcast struct {
+	gorm.Model
+	RoomID    uint   `gorm:"index;not null"`
+	Title     string `gorm:"type:varchar(32)"`
+	SrsNode   *string
+	ClientID  string `gorm:"type:varchar(32)"`
+	StartTime time.Time
+	EndTime   *time.Time `gorm:"default:null"`
+	EndReason BroadcastEndReason
+	// e.g. the reason of a cut-off.
+	EndDetail   string
+	PeakViewers uint
+	// integral of viewers over time, divided by the
+	// duration it is the average concurrent viewers.
+	ViewerSeconds float64
+	LastViewers   uint
+	LastViewersAt time.Time
+	// filled on close, counted on read for live broadcasts.
+	UniqueViewers uint
+	Chats         uint
+	// the scheduled occurrence it is linked to.
+	ScheduleID  *uint      `gorm:"index;default:null"`
+	ScheduledAt *time.Time `gorm:"default:null"`
+}
+
+func (b *Broadcast) Live() bool {
+	return b.EndTime == nil
+}
+
+func (b *Broadcast) Duration() time.Duration {
+	return b.DurationAt(time.Now())
+}
+
+func (b *Broadcast) DurationAt(now time.Time) time.Duration {
+	if b.EndTime == nil {
+		return now.Sub(b.StartTime)
+	}
+	return b.EndTime.Sub(b.StartTime)
+}
+
+func (b *Broadcast) AverageViewers() float64 {
+	return b.AverageViewersAt(time.Now())
+}
+
+// AverageViewersAt counts the viewers since the last
+// sample in for live broadcasts.
+func (b *Broadcast) AverageViewersAt(now time.Time) float64 {
+	seconds := b.DurationAt(now).Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	total := b.ViewerSeconds
+	if b.EndTime == nil {
+		total += float64(b.LastViewers) * math.Max(0, now.Sub(b.LastViewersAt).Seconds())
+	}
+	return total / seconds
+}
+
+// SampleViewers integrates the viewers since the last
+// sample, which are held until now, and starts a new
+// segment with `viewers`. Samples out of order add
+// nothing.
+func (b *Broadcast) SampleViewers(viewers uint, now time.Time) {
+	if elapsed := now.Sub(b.LastViewersAt).Seconds(); elapsed > 0 {
+		b.ViewerSeconds += float64(b.LastViewers) * elapsed
+	}
+	if now.After(b.LastViewersAt) {
+		b.LastViewersAt = now
+	}
+	b.LastViewers = viewers
+	if viewers > b.PeakViewers {
+		b.PeakViewers = viewers
+	}
+}
+
+// CountUniqueViewers counts the users whose sessions
+// overlap the broadcast until `until`.
+func (b *Broadcast) CountUniqueViewers(sessions []*UserWatchingSession, until time.Time) uint {
+	users := map[uint]bool{}
+	for _, session := range sessions {
+		if session.StartTime.After(until) {
+			continue
+		}
+		if session.EndTime != nil && session.EndTime.Before(b.StartTime) {
+			continue
+		}
+		users[session.UserID] = true
+	}
+	return uint(len(users))
+}
+
+// the chat types counted into chat volume.
+var broadcastChatTypes = []ChatMessageType{
+	ChatMessageTypeMessage,
+	ChatMessageTypeGift,
+	ChatMessageTypeSuperChat,
+}
+
+func CreateBroadcast(room *Room, node *string, clientId string) (*Broadcast, cerrors.ChocolateError) {
+	now := time.Now()
+	broadcast := Broadcast{
+		RoomID:        room.ID,
+		Title:         room.Title,
+		SrsNode:       node,
+		ClientID:      clientId,
+		StartTime:     now,
+		LastViewers:   room.Viewers,
+		LastViewersAt: now,
+		PeakViewers:   room.Viewers,
+	}
+	c := db.Create(&broadcast)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreateBroadcastError,
+			Message:    "error on creating broadcast",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id":   room.ID,
+				"client_id": clientId,
+			},
+		}
+	}
+	return &broadcast, nil
+}
+
+// SampleBroadcastViewers accumulates the viewers of the
+// live broadcast of a room, call it every time the
+// viewers of the room changes.
+func SampleBroadcastViewers(roomId uint) cerrors.ChocolateError {
+	dbError := func(c *gorm.DB, message string) cerrors.ChocolateError {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateBroadcastError,
+			Message:    message,
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	broadcast := Broadcast{}
+	c := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("room_id = ? AND end_time IS NULL", roomId).
+		Order("id DESC").
+		Limit(1).
+		Find(&broadcast)
+	if c.Error != nil {
+		return dbError(c, "error on locking live broadcast")
+	} else if c.RowsAffected == 0 {
+		return nil
+	}
+
+	// read after locking, so samples apply in order.
+	var viewers uint
+	c = tx.Model(&Room{}).Select("viewers").Where("id = ?", roomId).Scan(&viewers)
+	if c.Error != nil {
+		return dbError(c, "error on reading room viewers")
+	}
+
+	broadcast.SampleViewers(viewers, time.Now())
+	c = tx.Model(&broadcast).Updates(map[string]interface{}{
+		"viewer_seconds":  broadcast.ViewerSeconds,
+		"last_viewers":    broadcast.LastViewers,
+		"last_viewers_at": broadcast.LastViewersAt,
+		"peak_viewers":    broadcast.PeakViewers,
+	})
+	if c.Error != nil {
+		return dbError(c, "error on sampling broadcast viewers")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCommitTransactionError,
+			Message:    "error on sampling broadcast viewers",
+			InnerError: err,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return nil
+}
+
+// countBroadcastAudience counts the unique logged in
+// viewers and chat messages during the broadcast.
+func countBroadcastAudience(b *Broadcast, until time.Time) (uint, uint, cerrors.ChocolateError) {
+	var sessions []*UserWatchingSession
+	c := db.Select("user_id", "start_time", "end_time").
+		Where("room_id = ?", b.RoomID).
+		Where("start_time <= ?", until).
+		Where("(end_time IS NULL OR end_time >= ?)", b.StartTime).
+		Find(&sessions)
+	if c.Error != nil {
+		return 0, 0, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListUserWatchingHistoryError,
+			Message:    "error on counting broadcast unique viewers",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"broadcast_id": b.ID,
+			},
+		}
+	}
+
+	var chats int64
+	c = db.Model(&ChatMessage{}).
+		Where("room_id = ?", b.RoomID).
+		Where("type IN ?", broadcastChatTypes).
+		Where("created_at BETWEEN ? AND ?", b.StartTime, until).
+		Count(&chats)
+	if c.Error != nil {
+		return 0, 0, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListChatMessageError,
+			Message:    "error on counting broadcast chat messages",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"broadcast_id": b.ID,
+			},
+		}
+	}
+	return b.CountUniqueViewers(sessions, until), uint(chats), nil
+}
+
+// GetLiveBroadcast returns nil if the room is not
+// broadcasting.
+func GetLiveBroadcast(roomId uint) (*Broadcast, cerrors.ChocolateError) {
+	broadcast := Broadcast{}
+	c := db.Where("room_id = ? AND end_time IS NULL", roomId).Order("id DESC").First(&broadcast)
+	if errors.Is(c.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListBroadcastsError,
+			Message:    "error on lookup live broadcast",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return &broadcast, nil
+}
+
+// CloseBroadcast ends the live broadcast of a room if
+// there is one.
+func CloseBroadcast(roomId uint, reason BroadcastEndReason, detail string) cerrors.ChocolateError {
+	if err := SampleBroadcastViewers(roomId); err != nil {
+		return err
+	}
+
+	broadcast, err := GetLiveBroadcast(roomId)
+	if err != nil || broadcast == nil {
+		return err
+	}
+
+	now := time.Now()
+	unique, chats, err := countBroadcastAudience(broadcast, now)
+	if err != nil {
+		return err
+	}
+
+	// a concurrent close may have ended it already.
+	c := db.Model(broadcast).
+		Where("end_time IS NULL").
+		Updates(map[string]interface{}{
+			"end_time":       now,
+			"end_reason":     reason,
+			"end_detail":     detail,
+			"unique_viewers": unique,
+			"chats":          chats,
+		})
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateBroadcastError,
+			Message:    "error on closing broadcast",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id":      roomId,
+				"broadcast_id": broadcast.ID,
+			},
+		}
+	}
+	return nil
+}
+
+// fillLiveBroadcastAudience counts the audience of live
+// broadcasts so far.
+func fillLiveBroadcastAudience(broadcasts []*Broadcast) cerrors.ChocolateError {
+	now := time.Now()
+	for _, b := range broadcasts {
+		if !b.Live() {
+			continue
+		}
+		unique, chats, err := countBroadcastAudience(b, now)
+		if err != nil {
+			return err
+		}
+		b.UniqueViewers, b.Chats = unique, chats
+	}
+	return nil
+}
+
+// ListBroadcasts lists broadcasts of a room, latest first.
+func ListBroadcasts(roomId uint, limit uint, page uint) (uint, []*Broadcast, cerrors.ChocolateError) {
+	var count int64
+	c := db.Model(&Broadcast{}).Where("room_id = ?", roomId).Count(&count)
+	if c.Error != nil {
+		return 0, nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListBroadcastsError,
+			Message:    "error on counting broadcasts",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+
+	var result []*Broadcast
+	c = db.Model(&Broadcast{}).
+		Where("room_id = ?", roomId).
+		Order("start_time DESC").
+		Limit(int(limit)).
+		Offset(int((page - 1) * limit)).
+		Find(&result)
+	if c.Error != nil {
+		return 0, nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListBroadcastsError,
+			Message:    "error on listing broadcasts",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+
+	if err := fillLiveBroadcastAudience(result); err != nil {
+		return 0, nil, err
+	}
+	return uint(count), result, nil
+}
+
+func GetBroadcast(roomId uint, id uint) (*Broadcast, cerrors.ChocolateError) {
+	broadcast := Broadcast{}
+	c := db.First(&broadcast, "id = ? AND room_id = ?", id, roomId)
+	if c.Error != nil {
+		if errors.Is(c.Error, gorm.ErrRecordNotFound) {
+			return nil, cerrors.RequestError{
+				ID:      cerrors.RequestBroadcastNotFound,
+				Message: "broadcast not found",
+			}
+		} else {
+			return nil, cerrors.DatabaseError{
+				ID:         cerrors.DatabaseListBroadcastsError,
+				Message:    "error on lookup broadcast",
+				InnerError: c.Error,
+				Sql:        c.Statement.SQL.String(),
+				StackTrace: cerrors.GetStackTrace(),
+				Context: map[string]interface{}{
+					"room_id": roomId,
+					"id":      id,
+				},
+			}
+		}
+	}
+
+	if err := fillLiveBroadcastAudience([]*Broadcast{&broadcast}); err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
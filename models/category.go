@@ -0,0 +1,185 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm"
+)
+
+// Category groups rooms in the directory, categories are
+// managed by admins while tags are free-form.
+type Category struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"type:varchar(32);not null;uniqueIndex" json:"name"`
+	CreatedAt time.Time `json:"-"`
+}
+
+func ListCategories() ([]*Category, cerrors.ChocolateError) {
+	var result []*Category
+	c := db.Model(&Category{}).Order("name").Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListCategoriesError,
+			Message:    "error on listing categories",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+		}
+	}
+	return result, nil
+}
+
+func GetCategory(id uint) (*Category, cerrors.ChocolateError) {
+	category := Category{}
+	c := db.First(&category, "id = ?", id)
+	if c.Error != nil {
+		if errors.Is(c.Error, gorm.ErrRecordNotFound) {
+			return nil, cerrors.RequestError{
+				ID:      cerrors.RequestCategoryNotFound,
+				Message: "category not found",
+			}
+		} else {
+			return nil, cerrors.DatabaseError{
+				ID:         cerrors.DatabaseListCategoriesError,
+				Message:    "error on lookup category",
+				InnerError: c.Error,
+				Sql:        c.Statement.SQL.String(),
+				StackTrace: cerrors.GetStackTrace(),
+				Context: map[string]interface{}{
+					"id": id,
+				},
+			}
+		}
+	}
+	return &category, nil
+}
+
+func isCategoryNameTaken(name string, except uint) (bool, cerrors.ChocolateError) {
+	var count int64
+	c := db.Model(&Category{}).Where("name = ? AND id <> ?", name, except).Count(&count)
+	if c.Error != nil {
+		return false, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListCategoriesError,
+			Message:    "error on checking category name",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"name": name,
+			},
+		}
+	}
+	return count > 0, nil
+}
+
+func CreateCategory(name string) (*Category, cerrors.ChocolateError) {
+	taken, err := isCategoryNameTaken(name, 0)
+	if err != nil {
+		return nil, err
+	} else if taken {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestCategoryExists,
+			Message: "category exists",
+		}
+	}
+
+	category := Category{Name: name}
+	c := db.Create(&category)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreateCategoryError,
+			Message:    "error on creating category",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"name": name,
+			},
+		}
+	}
+	return &category, nil
+}
+
+func RenameCategory(id uint, name string) cerrors.ChocolateError {
+	taken, err := isCategoryNameTaken(name, id)
+	if err != nil {
+		return err
+	} else if taken {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestCategoryExists,
+			Message: "category exists",
+		}
+	}
+
+	c := db.Model(&Category{}).Where("id = ?", id).Update("name", name)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateCategoryError,
+			Message:    "error on renaming category",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"id":   id,
+				"name": name,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestCategoryNotFound,
+			Message: "category not found",
+		}
+	}
+	return nil
+}
+
+// DeleteCategory also uncategorizes its rooms.
+func DeleteCategory(id uint) cerrors.ChocolateError {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	c := tx.Model(&Room{}).Where("category_id = ?", id).Update("category_id", nil)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateCategoryError,
+			Message:    "error on uncategorizing rooms",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"id": id,
+			},
+		}
+	}
+
+	c = tx.Delete(&Category{}, id)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseDeleteCategoryError,
+			Message:    "error on deleting category",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"id": id,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestCategoryNotFound,
+			Message: "category not found",
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCommitTransactionError,
+			Message:    "error while deleting category",
+			StackTrace: cerrors.GetStackTrace(),
+			InnerError: err,
+		}
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ const (
 	ChatMessageTypeGift                 ChatMessageType = "gift"
 	ChatMessageTypeSuperChat            ChatMessageType = "superchat"
 	ChatMessageAuthenticationInfo       ChatMessageType = "auth"
+	ChatMessageTypePolicyWarning        ChatMessageType = "policy_warning"
 )
 
 type ChatMessage struct {
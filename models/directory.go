@@ -0,0 +1,220 @@
+package models
+
+import (
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DirectorySort string
+
+const (
+	DirectorySortViewers DirectorySort = "viewers"
+	DirectorySortRecent  DirectorySort = "recent"
+	// only if searching.
+	DirectorySortRelevance DirectorySort = "relevance"
+)
+
+type DirectoryFilter struct {
+	CategoryID *uint
+	Tag        *string
+	Status     *RoomStatus
+	// full-text searched over title and description, or
+	// the owner name.
+	Query string
+	Sort  DirectorySort
+}
+
+// the document rooms are searched by, the `simple`
+// config does not stem, so it works for any language. It
+// must match the expression of roomSearchIndex to use it.
+const roomSearchDocument = "to_tsvector('simple', coalesce(rooms.title, '') || ' ' || coalesce(rooms.description, ''))"
+
+const roomSearchIndex = "CREATE INDEX IF NOT EXISTS idx_rooms_search ON rooms USING gin (to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(description, '')))"
+
+// owners are matched apart, an index on rooms can not
+// cover their names, the array is evaluated once, so
+// both sides are index scans on rooms.
+const roomSearchCondition = "(" + roomSearchDocument + " @@ plainto_tsquery('simple', ?) OR " +
+	"rooms.owner_id = ANY(ARRAY(SELECT id FROM users WHERE to_tsvector('simple', username) @@ plainto_tsquery('simple', ?))))"
+
+// ListDirectoryRooms lists rooms anyone may find, with
+// owners, categories and tags.
+func ListDirectoryRooms(filter *DirectoryFilter, limit uint, page uint) (uint, []*Room, cerrors.ChocolateError) {
+	scope := func(tx *gorm.DB) *gorm.DB {
+		tx = tx.
+			Joins("JOIN users ON users.id = rooms.owner_id AND users.deleted_at IS NULL").
+			Where("rooms.permission_type = ?", RoomPermissionBlacklist).
+			Where("rooms.visibility = ?", RoomVisibilityPublic)
+		if filter.CategoryID != nil {
+			tx = tx.Where("rooms.category_id = ?", *filter.CategoryID)
+		}
+		if filter.Tag != nil {
+			tx = tx.Where("EXISTS (SELECT 1 FROM room_tags WHERE room_tags.room_id = rooms.id AND room_tags.name = ?)", *filter.Tag)
+		}
+		if filter.Status != nil {
+			tx = tx.Where("rooms.status = ?", *filter.Status)
+		}
+		if filter.Query != "" {
+			tx = tx.Where(roomSearchCondition, filter.Query, filter.Query)
+		}
+		return tx
+	}
+
+	var count int64
+	c := db.Model(&Room{}).Scopes(scope).Count(&count)
+	if c.Error != nil {
+		return 0, nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomsError,
+			Message:    "error on counting directory rooms",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+		}
+	}
+
+	statement := db.Model(&Room{}).
+		Scopes(scope).
+		Preload("Owner").
+		Preload("Category").
+		Preload("Tags")
+	switch filter.Sort {
+	case DirectorySortRecent:
+		statement = statement.Order("rooms.status DESC, rooms.last_streaming_at DESC")
+	case DirectorySortRelevance:
+		if filter.Query != "" {
+			statement = statement.Order(clause.OrderBy{Expression: clause.Expr{
+				SQL:                "ts_rank(" + roomSearchDocument + ", plainto_tsquery('simple', ?)) DESC",
+				Vars:               []interface{}{filter.Query},
+				WithoutParentheses: true,
+			}})
+		}
+		fallthrough
+	default:
+		statement = statement.Order("rooms.viewers DESC, rooms.last_streaming_at DESC")
+	}
+
+	var result []*Room
+	c = statement.Order("rooms.id").Limit(int(limit)).Offset(int((page - 1) * limit)).Find(&result)
+	if c.Error != nil {
+		return 0, nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomsError,
+			Message:    "error on listing directory rooms",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+		}
+	}
+	return uint(count), result, nil
+}
+
+func SetRoomDescription(roomId uint, description string) cerrors.ChocolateError {
+	c := db.Model(&Room{}).Where("id = ?", roomId).Update("description", description)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateRoomDescriptionError,
+			Message:    "error on updating room description",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return nil
+}
+
+func SetRoomVisibility(roomId uint, visibility RoomVisibility) cerrors.ChocolateError {
+	c := db.Model(&Room{}).Where("id = ?", roomId).Update("visibility", visibility)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateRoomVisibilityError,
+			Message:    "error on updating room visibility",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id":    roomId,
+				"visibility": visibility,
+			},
+		}
+	}
+	return nil
+}
+
+// SetRoomAccessPolicy clears the policy if `policy` is
+// empty.
+func SetRoomAccessPolicy(roomId uint, policy string) cerrors.ChocolateError {
+	c := db.Model(&Room{}).Where("id = ?", roomId).Update("access_policy", policy)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateRoomAccessPolicyError,
+			Message:    "error on updating room access policy",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+				"policy":  policy,
+			},
+		}
+	}
+	return nil
+}
+
+// SetRoomPassword removes the password if `hash` is empty.
+func SetRoomPassword(roomId uint, hash string) cerrors.ChocolateError {
+	c := db.Model(&Room{}).Where("id = ?", roomId).Update("password", hash)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateRoomPasswordError,
+			Message:    "error on updating room password",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return nil
+}
+
+func SetRoomMaxViewers(roomId uint, maxViewers uint) cerrors.ChocolateError {
+	c := db.Model(&Room{}).Where("id = ?", roomId).Update("max_viewers", maxViewers)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateRoomMaxViewersError,
+			Message:    "error on updating room max viewers",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id":     roomId,
+				"max_viewers": maxViewers,
+			},
+		}
+	}
+	return nil
+}
+
+// SetRoomCategory uncategorizes the room if `categoryId`
+// is nil.
+func SetRoomCategory(roomId uint, categoryId *uint) cerrors.ChocolateError {
+	c := db.Model(&Room{}).Where("id = ?", roomId).Update("category_id", categoryId)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateRoomCategoryError,
+			Message:    "error on updating room category",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id":     roomId,
+				"category_id": categoryId,
+			},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm"
+)
+
+// ForwardDestination is an external rtmp server
+// which the stream of a room is relayed to once
+// the room starts publishing, e.g. other platforms
+// the streamer also broadcasts to.
+type ForwardDestination struct {
+	gorm.Model
+	RoomID  uint   `gorm:"index;not null"`
+	Name    string `gorm:"type:varchar(32)"`
+	Url     string `gorm:"type:varchar(256);not null"`
+	Key     string `gorm:"type:varchar(256)"`
+	Enabled bool   `gorm:"not null;default:true"`
+}
+
+// the full rtmp url that the relay should push to,
+// the key is treated as the last path segment.
+func (d *ForwardDestination) GetTargetUrl() string {
+	if d.Key == "" {
+		return d.Url
+	}
+	return strings.TrimSuffix(d.Url, "/") + "/" + d.Key
+}
+
+func ListForwardDestinations(roomId uint, onlyEnabled bool) ([]*ForwardDestination, cerrors.ChocolateError) {
+	var result []*ForwardDestination
+	statement := db.Model(&ForwardDestination{}).Where("room_id = ?", roomId)
+	if onlyEnabled {
+		statement = statement.Where("enabled = ?", true)
+	}
+	c := statement.Order("id").Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListForwardDestinationsError,
+			Message:    "error on listing room forward destinations",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return result, nil
+}
+
+func CountForwardDestinations(roomId uint) (uint, cerrors.ChocolateError) {
+	var count int64
+	c := db.Model(&ForwardDestination{}).Where("room_id = ?", roomId).Count(&count)
+	if c.Error != nil {
+		return 0, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListForwardDestinationsError,
+			Message:    "error on counting room forward destinations",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return uint(count), nil
+}
+
+func GetForwardDestination(roomId uint, id uint) (*ForwardDestination, cerrors.ChocolateError) {
+	dest := ForwardDestination{}
+	c := db.First(&dest, "id = ? AND room_id = ?", id, roomId)
+	if c.Error != nil {
+		if errors.Is(c.Error, gorm.ErrRecordNotFound) {
+			return nil, cerrors.RequestError{
+				ID:      cerrors.RequestForwardDestinationNotFound,
+				Message: "forward destination not found",
+			}
+		} else {
+			return nil, cerrors.DatabaseError{
+				ID:         cerrors.DatabaseListForwardDestinationsError,
+				Message:    "error on lookup forward destination",
+				InnerError: c.Error,
+				Sql:        c.Statement.SQL.String(),
+				StackTrace: cerrors.GetStackTrace(),
+				Context: map[string]interface{}{
+					"room_id": roomId,
+					"id":      id,
+				},
+			}
+		}
+	}
+	return &dest, nil
+}
+
+func CreateForwardDestination(dest *ForwardDestination) cerrors.ChocolateError {
+	c := db.Create(dest)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreateForwardDestinationError,
+			Message:    "error on creating forward destination",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": dest.RoomID,
+			},
+		}
+	}
+	return nil
+}
+
+func UpdateForwardDestination(dest *ForwardDestination) cerrors.ChocolateError {
+	c := db.Model(dest).Select("name", "url", "key", "enabled").Updates(dest)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateForwardDestinationError,
+			Message:    "error on updating forward destination",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": dest.RoomID,
+				"id":      dest.ID,
+			},
+		}
+	}
+	return nil
+}
+
+func DeleteForwardDestination(roomId uint, id uint) cerrors.ChocolateError {
+	c := db.Delete(&ForwardDestination{}, "id = ? AND room_id = ?", id, roomId)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseDeleteForwardDestinationError,
+			Message:    "error on deleting forward destination",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+				"id":      id,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestForwardDestinationNotFound,
+			Message: "forward destination not found",
+		}
+	}
+	return nil
+}
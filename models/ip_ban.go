@@ -0,0 +1,148 @@
+package models
+
+import (
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm"
+)
+
+// IPBan keeps clients in a network out of a room, or out
+// of all rooms if RoomID is nil.
+type IPBan struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	RoomID    *uint     `gorm:"index;default:null" json:"room_id"`
+	Room      *Room     `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	// in cidr notation, single addresses are /32 or /128.
+	CIDR      string     `gorm:"type:varchar(64);not null" json:"cidr"`
+	Reason    string     `gorm:"type:varchar(256);not null;default:''" json:"reason"`
+	ExpiresAt *time.Time `gorm:"index;default:null" json:"expires_at"`
+	CreatedBy *uint      `gorm:"default:null" json:"created_by"`
+	Hits      uint64     `gorm:"not null;default:0" json:"hits"`
+	LastHitAt *time.Time `gorm:"default:null" json:"last_hit_at"`
+}
+
+func activeIPBans(db *gorm.DB) *gorm.DB {
+	return db.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
+
+func CreateIPBan(ban *IPBan) cerrors.ChocolateError {
+	c := db.Create(ban)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreateIPBanError,
+			Message:    "error on creating ip ban",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": ban.RoomID,
+				"cidr":    ban.CIDR,
+			},
+		}
+	}
+	return nil
+}
+
+// ListIPBans lists bans of the room, global ones if
+// `roomId` is nil, or all if `all` is set.
+func ListIPBans(roomId *uint, all bool) ([]*IPBan, cerrors.ChocolateError) {
+	var result []*IPBan
+	statement := db.Scopes(activeIPBans).Order("id DESC")
+	if !all {
+		if roomId == nil {
+			statement = statement.Where("room_id IS NULL")
+		} else {
+			statement = statement.Where("room_id = ?", *roomId)
+		}
+	}
+	c := statement.Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListIPBansError,
+			Message:    "error on listing ip bans",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return result, nil
+}
+
+func DeleteIPBan(id uint) cerrors.ChocolateError {
+	c := db.Delete(&IPBan{}, id)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseDeleteIPBanError,
+			Message:    "error on deleting ip ban",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"ban_id": id,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestIPBanNotFound,
+			Message: "ip ban not found",
+		}
+	}
+	return nil
+}
+
+func DeleteExpiredIPBans() (int64, cerrors.ChocolateError) {
+	c := db.Where("expires_at <= ?", time.Now()).Delete(&IPBan{})
+	if c.Error != nil {
+		return 0, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseDeleteIPBanError,
+			Message:    "error on deleting expired ip bans",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+		}
+	}
+	return c.RowsAffected, nil
+}
+
+// AddIPBanHits adds up the hits counted since the last
+// call, `hits` maps ban ids to counts.
+func AddIPBanHits(hits map[uint]uint64, at time.Time) cerrors.ChocolateError {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	for id, count := range hits {
+		c := tx.Model(&IPBan{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"hits":        gorm.Expr("hits + ?", count),
+			"last_hit_at": at,
+		})
+		if c.Error != nil {
+			return cerrors.DatabaseError{
+				ID:         cerrors.DatabaseUpdateIPBanHitsError,
+				Message:    "error on updating ip ban hits",
+				InnerError: c.Error,
+				Sql:        c.Statement.SQL.String(),
+				StackTrace: cerrors.GetStackTrace(),
+				Context: map[string]interface{}{
+					"ban_id": id,
+					"hits":   count,
+				},
+			}
+		}
+	}
+
+	c := tx.Commit()
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCommitTransactionError,
+			Message:    "error on committing ip ban hits",
+			InnerError: c.Error,
+			StackTrace: cerrors.GetStackTrace(),
+		}
+	}
+	return nil
+}
@@ -18,6 +18,8 @@ const (
 	LogTypePublish LogType = iota
 	LogTypeUnpublish
 	LogTypeCutOff
+	// corrections made when reconciling rooms with srs.
+	LogTypeReconcile
 )
 
 type Log struct {
@@ -134,7 +136,7 @@ func RetriveLogsForRoom(roomid uint) ([]*Log, cerrors.ChocolateError) {
 	var result []*Log
 	c := db.
 		Where("subject = ?", strconv.FormatUint(uint64(roomid), 10)).
-		Where("type in ?", []LogType{LogTypePublish, LogTypeUnpublish, LogTypeCutOff}). // check here
+		Where("type in ?", []LogType{LogTypePublish, LogTypeUnpublish, LogTypeCutOff, LogTypeReconcile}). // check here
 		Order("created_at DESC").
 		Limit(12).
 		Find(&result)
@@ -51,10 +51,24 @@ func Migrate(connStr string) error {
 		&Log{},
 		&ChatMessage{},
 		&UserWatchingSession{},
+		&ForwardDestination{},
+		&Broadcast{},
+		&Schedule{},
+		&RoomFollow{},
+		&Category{},
+		&RoomTag{},
+		&RoomInvite{},
+		&RoomMember{},
+		&IPBan{},
+		&RoomTransfer{},
+		&RoomTemplate{},
 	)
 	if err != nil {
 		return err
 	}
+	if err := db.Exec(roomSearchIndex).Error; err != nil {
+		return err
+	}
 
 	return db.Save(defaultRoles).Error
 }
@@ -2,10 +2,12 @@ package models
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/samber/lo"
 	cerrors "github.com/sheey11/chocolate/errors"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 type PermissionSubjectType string
@@ -13,6 +15,7 @@ type PermissionSubjectType string
 const (
 	PermissionSubjectTypeLabel PermissionSubjectType = "label"
 	PermissionSubjectTypeUser  PermissionSubjectType = "user"
+	PermissionSubjectTypeRole  PermissionSubjectType = "role"
 )
 
 type PermissionItem struct {
@@ -23,6 +26,62 @@ type PermissionItem struct {
 	SubjectLabelName *string               `gorm:"uniqueIndex:idx_label" json:"label"`
 	SubjectUser      *User                 `json:"-"`
 	SubjectUserID    *uint                 `gorm:"uniqueIndex:idx_user" json:"user_id"`
+	SubjectRole      *Role                 `json:"-"`
+	SubjectRoleName  *string               `gorm:"type:varchar(32)" json:"role"`
+	// nil for never, e.g. temporary bans and items
+	// granted by invites expire.
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"`
+	Reason    string     `gorm:"type:varchar(256);not null;default:''" json:"reason"`
+	// id of the user added the item.
+	CreatedBy *uint     `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PermissionItemMeta documents why, by whom and until
+// when an item is added.
+type PermissionItemMeta struct {
+	ExpiresAt *time.Time
+	Reason    string
+	CreatedBy *uint
+}
+
+func (m *PermissionItemMeta) apply(item *PermissionItem) {
+	item.ExpiresAt = m.ExpiresAt
+	item.Reason = m.Reason
+	item.CreatedBy = m.CreatedBy
+}
+
+// activePermissionItems filters out expired items.
+func activePermissionItems(tx *gorm.DB) *gorm.DB {
+	return tx.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
+
+// countRolePermissionItems counts active items of the
+// room subjecting the role of the user.
+func countRolePermissionItems(room *Room, user *User) int64 {
+	var count int64
+	c := db.
+		Model(&PermissionItem{}).
+		Scopes(activePermissionItems).
+		Where("room_id = ? AND subject_type = ? AND subject_role_name = ?", room.ID, PermissionSubjectTypeRole, user.RoleName).
+		Count(&count)
+	if c.Error != nil {
+		err := cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCountPermissionItemError,
+			Message:    "error when looking up for permission_item",
+			Sql:        c.Statement.SQL.String(),
+			InnerError: c.Error,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_permission_type": room.PermissionType,
+				"room_id":              room.ID,
+				"user_id":              user.ID,
+				"role":                 user.RoleName,
+			},
+		}
+		logrus.WithError(err).Error("error when check user watching room permission")
+	}
+	return count
 }
 
 func IsUserAllowedForRoom(room *Room, user *User) bool {
@@ -36,6 +95,7 @@ func IsUserAllowedForRoom(room *Room, user *User) bool {
 		// checking users
 		c := db.
 			Model(&PermissionItem{}).
+			Scopes(activePermissionItems).
 			Where("room_id = ? AND subject_type = ? AND subject_user_id = ?", room.ID, PermissionSubjectTypeUser, user.ID).
 			Count(&count)
 		if c.Error != nil {
@@ -57,6 +117,11 @@ func IsUserAllowedForRoom(room *Room, user *User) bool {
 			return true
 		}
 
+		// checking roles
+		if countRolePermissionItems(room, user) != 0 {
+			return true
+		}
+
 		// checking labels
 		if user.Labels == nil {
 			c := db.Preload("Labels").First(user, "id = ?", user.ID)
@@ -67,6 +132,7 @@ func IsUserAllowedForRoom(room *Room, user *User) bool {
 
 		c = db.
 			Model(&PermissionItem{}).
+			Scopes(activePermissionItems).
 			Where(
 				"room_id = ? AND subject_type = ? AND subject_label_name IN ?",
 				room.ID,
@@ -99,6 +165,7 @@ func IsUserAllowedForRoom(room *Room, user *User) bool {
 		// checking users
 		c := db.
 			Model(&PermissionItem{}).
+			Scopes(activePermissionItems).
 			Where("room_id = ? AND subject_type = ? AND subject_user_id = ?", room.ID, PermissionSubjectTypeUser, user.ID).
 			Count(&count)
 		if c.Error != nil {
@@ -120,6 +187,11 @@ func IsUserAllowedForRoom(room *Room, user *User) bool {
 			return false
 		}
 
+		// checking roles
+		if countRolePermissionItems(room, user) != 0 {
+			return false
+		}
+
 		// checking labels
 		if user.Labels == nil {
 			c := db.Preload("Labels").First(user, "id = ?", user.ID)
@@ -130,6 +202,7 @@ func IsUserAllowedForRoom(room *Room, user *User) bool {
 
 		c = db.
 			Model(&PermissionItem{}).
+			Scopes(activePermissionItems).
 			Where(
 				"room_id = ? AND subject_type = ? AND subject_label_name IN ?",
 				room.ID,
@@ -220,6 +293,28 @@ func RermItemAutoComplete(roomId uint, permType PermissionSubjectType, prefix st
 			}
 		}
 		return result, nil
+	case PermissionSubjectTypeRole:
+		var result []*PermItemAutoCompeleteItem
+		c := db.
+			Model(&Role{}).
+			Where("name LIKE ?", fmt.Sprintf("%%%s%%", prefix)).
+			Select("name, 'role' as type").
+			Limit(10).
+			Find(&result)
+		if c.Error != nil {
+			return nil, cerrors.DatabaseError{
+				ID:         cerrors.DatabasePermItemAutoComepelteLookupError,
+				Sql:        c.Statement.SQL.String(),
+				InnerError: c.Error,
+				StackTrace: cerrors.GetStackTrace(),
+				Context: map[string]interface{}{
+					"room_id": roomId,
+					"type":    permType,
+					"prefix":  prefix,
+				},
+			}
+		}
+		return result, nil
 	}
 	return nil, cerrors.LogicError{
 		ID:      cerrors.LogicUnknownPermItemSubjectType,
@@ -229,3 +324,53 @@ func RermItemAutoComplete(roomId uint, permType PermissionSubjectType, prefix st
 		},
 	}
 }
+
+// ListUserBans lists active items banning the user from
+// the blacklist room, directly, by labels or the role.
+func ListUserBans(room *Room, user *User) ([]*PermissionItem, cerrors.ChocolateError) {
+	if user.Labels == nil {
+		c := db.Preload("Labels").First(user, "id = ?", user.ID)
+		if c.Error != nil {
+			logrus.WithError(c.Error).WithField("stack_trace", cerrors.GetStackTrace()).Error("error when quering user labels")
+		}
+	}
+
+	var result []*PermissionItem
+	c := db.
+		Scopes(activePermissionItems).
+		Where("room_id = ?", room.ID).
+		Where(
+			db.Where("subject_type = ? AND subject_user_id = ?", PermissionSubjectTypeUser, user.ID).
+				Or("subject_type = ? AND subject_label_name IN ?", PermissionSubjectTypeLabel, lo.Map(user.Labels, func(l Label, _ int) string { return l.Name })).
+				Or("subject_type = ? AND subject_role_name = ?", PermissionSubjectTypeRole, user.RoleName),
+		).
+		Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCountPermissionItemError,
+			Message:    "error on listing bans of user",
+			Sql:        c.Statement.SQL.String(),
+			InnerError: c.Error,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": room.ID,
+				"user_id": user.ID,
+			},
+		}
+	}
+	return result, nil
+}
+
+func DeleteExpiredPermissionItems() (int64, cerrors.ChocolateError) {
+	c := db.Delete(&PermissionItem{}, "expires_at <= ?", time.Now())
+	if c.Error != nil {
+		return 0, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseClearRoomPermissionItemError,
+			Message:    "error on deleting expired permission items",
+			Sql:        c.Statement.SQL.String(),
+			InnerError: c.Error,
+			StackTrace: cerrors.GetStackTrace(),
+		}
+	}
+	return c.RowsAffected, nil
+}
@@ -12,25 +12,32 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RoomPermissionType string
 
 const (
 	RoomPermissionBlacklist RoomPermissionType = "blacklist"
-	// No, whitelist may not be possible.
 	// Only users in the list can watch the stream,
-	// meaning it requires all connection to the server
-	// fetching that media stream to be authenticated
-	// and authorized, which would be a heavy burden of
-	// the database. Use redis may solve this problem.
-	// But I haven't tested it yet.
-	//
-	// Currently, it only checks the user who sends
-	// chat message.
+	// meaning all connections fetching that media
+	// stream are authenticated and authorized, the
+	// decisions are cached by the service to spare
+	// the database.
 	RoomPermissionWhitelist RoomPermissionType = "whitelist"
 )
 
+type RoomVisibility string
+
+const (
+	RoomVisibilityPublic RoomVisibility = "public"
+	// reachable by direct link, but not listed.
+	RoomVisibilityUnlisted RoomVisibility = "unlisted"
+	// only the owner and admins may see it, e.g. to
+	// rehearse before going public.
+	RoomVisibilityPrivate RoomVisibility = "private"
+)
+
 type RoomStatus uint8
 
 const (
@@ -59,17 +66,40 @@ type Room struct {
 	UID             string `gorm:"type:varchar(32);not null;uniqueIndex"`
 	PushKey         string `gorm:"type:varchar(64)"`
 	Owner           User
-	OwnerID         uint `gorm:"not null"`
+	OwnerID         uint `gorm:"not null;index"`
 	Viewers         uint
 	PermissionType  RoomPermissionType `gorm:"default:blacklist"`
 	PermissionItems []PermissionItem   `gorm:"constraint:OnDelete:CASCADE"`
 	LastStreamingAt time.Time
 	SrsClientID     *string `gorm:"default:null"`
 	SrsStreamID     *string `gorm:"default:null"`
+	// name of the srs node serving the stream, it is
+	// assigned when starting streaming and corrected
+	// by the node the publisher actually connects to.
+	SrsNode *string `gorm:"type:varchar(32);default:null"`
+
+	ForwardDestinations []ForwardDestination `gorm:"constraint:OnDelete:CASCADE"`
+
+	Description string `gorm:"type:varchar(512)"`
+	Category    *Category
+	CategoryID  *uint     `gorm:"index;default:null"`
+	Tags        []RoomTag `gorm:"constraint:OnDelete:CASCADE"`
+
+	Visibility RoomVisibility `gorm:"type:varchar(8);not null;default:public"`
+
+	// optional expression over user attributes, evaluated
+	// alongside permission items, see service.ParseAccessPolicy.
+	AccessPolicy string `gorm:"type:varchar(512);not null;default:''"`
+	// bcrypt hash, empty if not password protected.
+	Password string `gorm:"type:varchar(64);not null;default:''" json:"-"`
+	// concurrent viewers, 0 for unlimited.
+	MaxViewers uint `gorm:"not null;default:0"`
+
+	Members []RoomMember `gorm:"constraint:OnDelete:CASCADE"`
 }
 
 func (r *Room) LoadPermissionItems() {
-	c := db.Model(&PermissionItem{}).Where("room_id = ?", r.ID).Find(&r.PermissionItems)
+	c := db.Model(&PermissionItem{}).Scopes(activePermissionItems).Where("room_id = ?", r.ID).Find(&r.PermissionItems)
 	if c.Error != nil {
 		err := cerrors.DatabaseError{
 			ID:         cerrors.DatabaseListAccountsError,
@@ -88,6 +118,8 @@ func (r Room) GetPlaybackInfo() map[string]interface{} {
 	return map[string]interface{}{
 		"hls": fmt.Sprintf("/room/%d/playback.m3u8", r.ID),
 		"flv": fmt.Sprintf("/room/%d/playback.flv", r.ID),
+		// WHEP endpoint, post sdp offer to it.
+		"webrtc": fmt.Sprintf("/v1/playback/%d/whep", r.ID),
 	}
 }
 
@@ -160,7 +192,13 @@ func GetRoomByID(id uint, preloads []string) (*Room, cerrors.ChocolateError) {
 	statement := db
 	if len(preloads) > 0 {
 		for _, preload := range preloads {
-			statement = statement.Preload(preload)
+			if preload == "PermissionItems" {
+				// expired items are kept until the janitor
+				// comes, they no longer apply.
+				statement = statement.Preload(preload, activePermissionItems)
+			} else {
+				statement = statement.Preload(preload)
+			}
 		}
 	}
 
@@ -218,10 +256,11 @@ func ChangeRoomPermissionType(room *Room, permission RoomPermissionType, clearPe
 	return nil
 }
 
-func AddRoomPermissionItem_Label(id uint, label string) cerrors.ChocolateError {
+func AddRoomPermissionItem_Label(id uint, label string, meta PermissionItemMeta) cerrors.ChocolateError {
 	var count int64
 	c := db.
 		Model(&PermissionItem{}).
+		Scopes(activePermissionItems).
 		Where("room_id = ? AND subject_label_name = ?", id, label).
 		Count(&count)
 	if c.Error != nil {
@@ -265,11 +304,27 @@ func AddRoomPermissionItem_Label(id uint, label string) cerrors.ChocolateError {
 		}
 	}
 
+	// expired ones left
+	if c := db.Delete(&PermissionItem{}, "room_id = ? AND subject_label_name = ?", id, label); c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseClearRoomPermissionItemError,
+			Message:    "error on clearing expired room permissions",
+			Sql:        c.Statement.SQL.String(),
+			InnerError: c.Error,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": id,
+				"label":   label,
+			},
+		}
+	}
+
 	item := PermissionItem{
 		RoomID:           id,
 		SubjectType:      PermissionSubjectTypeLabel,
 		SubjectLabelName: &label,
 	}
+	meta.apply(&item)
 	if c := db.Save(&item); c.Error != nil {
 		return cerrors.DatabaseError{
 			ID:         cerrors.DatabaseCreatePermissionItemError,
@@ -286,10 +341,11 @@ func AddRoomPermissionItem_Label(id uint, label string) cerrors.ChocolateError {
 	return nil
 }
 
-func AddRoomPermissionItem_User(id uint, uid uint) cerrors.ChocolateError {
+func AddRoomPermissionItem_User(id uint, uid uint, meta PermissionItemMeta) cerrors.ChocolateError {
 	var count int64
 	tx := db.
 		Model(&PermissionItem{}).
+		Scopes(activePermissionItems).
 		Where("room_id = ? AND subject_user_id = ?", id, uid).
 		Count(&count)
 	if tx.Error != nil {
@@ -310,11 +366,27 @@ func AddRoomPermissionItem_User(id uint, uid uint) cerrors.ChocolateError {
 			Message: "permission item already exists",
 		}
 	}
+	// expired ones left
+	if tx := db.Delete(&PermissionItem{}, "room_id = ? AND subject_user_id = ?", id, uid); tx.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseClearRoomPermissionItemError,
+			Message:    "error on clearing expired room permissions",
+			Sql:        tx.Statement.SQL.String(),
+			InnerError: tx.Error,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": id,
+				"user_id": uid,
+			},
+		}
+	}
+
 	item := PermissionItem{
 		RoomID:        id,
 		SubjectType:   PermissionSubjectTypeUser,
 		SubjectUserID: &uid,
 	}
+	meta.apply(&item)
 	if tx := db.Save(&item); tx.Error != nil {
 		return cerrors.DatabaseError{
 			ID:         cerrors.DatabaseCreatePermissionItemError,
@@ -414,6 +486,92 @@ func DeleteRoomPermissionItem_User(id uint, uid uint) cerrors.ChocolateError {
 	return nil
 }
 
+func AddRoomPermissionItem_Role(id uint, role string, meta PermissionItemMeta) cerrors.ChocolateError {
+	var count int64
+	c := db.
+		Model(&PermissionItem{}).
+		Scopes(activePermissionItems).
+		Where("room_id = ? AND subject_role_name = ?", id, role).
+		Count(&count)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCountPermissionItemError,
+			Message:    "error on counting existing room permissions",
+			Sql:        c.Statement.SQL.String(),
+			InnerError: c.Error,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": id,
+				"role":    role,
+			},
+		}
+	} else if count != 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestPermissionItemAlreadyExistError,
+			Message: "permission item already exists",
+		}
+	}
+
+	// expired ones left
+	if c := db.Delete(&PermissionItem{}, "room_id = ? AND subject_role_name = ?", id, role); c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseClearRoomPermissionItemError,
+			Message:    "error on clearing expired room permissions",
+			Sql:        c.Statement.SQL.String(),
+			InnerError: c.Error,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": id,
+				"role":    role,
+			},
+		}
+	}
+
+	item := PermissionItem{
+		RoomID:          id,
+		SubjectType:     PermissionSubjectTypeRole,
+		SubjectRoleName: &role,
+	}
+	meta.apply(&item)
+	if c := db.Save(&item); c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreatePermissionItemError,
+			Message:    "error on creating room permissions",
+			Sql:        c.Statement.SQL.String(),
+			InnerError: c.Error,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": id,
+				"role":    role,
+			},
+		}
+	}
+	return nil
+}
+
+func DeleteRoomPermissionItem_Role(id uint, role string) cerrors.ChocolateError {
+	c := db.Delete(&PermissionItem{}, "room_id = ? and subject_role_name = ?", id, role)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreatePermissionItemError,
+			Message:    "error on deleting room permissions",
+			Sql:        c.Statement.SQL.String(),
+			InnerError: c.Error,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": id,
+				"role":    role,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestPermissionItemNotExistError,
+			Message: "permission item not exists",
+		}
+	}
+	return nil
+}
+
 func SetRoomStatus(id uint, status RoomStatus) cerrors.ChocolateError {
 	var c *gorm.DB
 	if status == RoomStatusStreaming {
@@ -440,8 +598,8 @@ func SetRoomStatus(id uint, status RoomStatus) cerrors.ChocolateError {
 	return nil
 }
 
-func CreateRoomForUser(user *User, title string) (*Room, cerrors.ChocolateError) {
-	room := Room{
+func newRoomForUser(user *User, title string) Room {
+	return Room{
 		Title:           title,
 		Status:          RoomStatusIdle,
 		UID:             GenerateRoomUID(),
@@ -450,20 +608,136 @@ func CreateRoomForUser(user *User, title string) (*Room, cerrors.ChocolateError)
 		OwnerID:         user.ID,
 		LastStreamingAt: time.Now(),
 	}
+}
 
-	c := db.Create(&room)
+// CreateRoomForUser creates the room unless the user owns
+// `maxRooms` rooms already, nil for no limit.
+func CreateRoomForUser(user *User, title string, maxRooms *uint) (*Room, cerrors.ChocolateError) {
+	room := newRoomForUser(user, title)
+	if err := createRoom(&room, maxRooms); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// CreateRoomFromTemplate creates the room with settings of
+// the template room copied, that are the permission type,
+// items never expiring, access policy, viewer cap,
+// description, category, tags and visibility. The
+// password and members are left out, they are not meant
+// to follow every room made from the template.
+func CreateRoomFromTemplate(user *User, title string, templateRoomId uint, maxRooms *uint) (*Room, cerrors.ChocolateError) {
+	source, err := GetRoomByID(templateRoomId, []string{"Tags"})
+	if err != nil {
+		return nil, err
+	}
+	var items []PermissionItem
+	c := db.Where("room_id = ? AND expires_at IS NULL", source.ID).Find(&items)
 	if c.Error != nil {
 		return nil, cerrors.DatabaseError{
-			ID:         cerrors.DatabaseCreateRoomError,
-			Message:    "error on creating room",
+			ID:         cerrors.DatabaseListRoomTemplatesError,
+			Message:    "error on listing permission items of template room",
 			InnerError: c.Error,
 			Sql:        c.Statement.SQL.String(),
 			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": source.ID,
+			},
 		}
 	}
+
+	room := NewRoomFromTemplate(user, title, source, items)
+	// associations are created in the same transaction.
+	if err := createRoom(&room, maxRooms); err != nil {
+		return nil, err
+	}
 	return &room, nil
 }
 
+// NewRoomFromTemplate copies the settings of `source`, see
+// CreateRoomFromTemplate, `items` are the permission items
+// of it to copy. The items are credited to `user`, who
+// creates them in the new room.
+func NewRoomFromTemplate(user *User, title string, source *Room, items []PermissionItem) Room {
+	room := newRoomForUser(user, title)
+	room.PermissionType = source.PermissionType
+	room.AccessPolicy = source.AccessPolicy
+	room.MaxViewers = source.MaxViewers
+	room.Description = source.Description
+	room.CategoryID = source.CategoryID
+	room.Visibility = source.Visibility
+	for _, item := range items {
+		room.PermissionItems = append(room.PermissionItems, PermissionItem{
+			SubjectType:      item.SubjectType,
+			SubjectLabelName: item.SubjectLabelName,
+			SubjectUserID:    item.SubjectUserID,
+			SubjectRoleName:  item.SubjectRoleName,
+			Reason:           item.Reason,
+			CreatedBy:        &user.ID,
+		})
+	}
+	for _, tag := range source.Tags {
+		room.Tags = append(room.Tags, RoomTag{Name: tag.Name})
+	}
+	return room
+}
+
+func createRoom(room *Room, maxRooms *uint) cerrors.ChocolateError {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	dbError := func(c *gorm.DB, message string) cerrors.ChocolateError {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreateRoomError,
+			Message:    message,
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"owner_id": room.OwnerID,
+			},
+		}
+	}
+
+	// serializes creations and transfers for the owner, as
+	// TransferRoom does, so the count below holds until
+	// commit.
+	c := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&User{}, "id = ?", room.OwnerID)
+	if c.Error != nil {
+		return dbError(c, "error on locking room owner")
+	}
+	if maxRooms != nil {
+		var count int64
+		c = tx.Model(&Room{}).Where("owner_id = ?", room.OwnerID).Count(&count)
+		if c.Error != nil {
+			return dbError(c, "error on counting rooms of owner")
+		}
+		if uint(count) >= *maxRooms {
+			return cerrors.RequestError{
+				ID:      cerrors.RequestRoomCountReachedMax,
+				Message: "you have created max room allowed",
+			}
+		}
+	}
+
+	if c = tx.Create(room); c.Error != nil {
+		return dbError(c, "error on creating room")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCommitTransactionError,
+			Message:    "error on committing room creation",
+			InnerError: err,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"owner_id": room.OwnerID,
+			},
+		}
+	}
+	return nil
+}
+
 func IncreaseRoomViewer(id uint) cerrors.ChocolateError {
 	c := db.Model(&Room{}).Where("id = ?", id).Update("viewers", gorm.Expr("viewers + ?", 1))
 	if c.Error != nil {
@@ -498,6 +772,43 @@ func DecreaseRoomViewer(id uint) cerrors.ChocolateError {
 	return nil
 }
 
+func SetRoomViewers(id uint, viewers uint) cerrors.ChocolateError {
+	c := db.Model(&Room{}).Where("id = ?", id).Update("viewers", viewers)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseSetRoomViewersError,
+			Message:    "error set room viewers",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": id,
+				"viewers": viewers,
+			},
+		}
+	}
+	return nil
+}
+
+// ListRoomsToReconcile lists rooms that may have a live
+// stream, according to the database.
+func ListRoomsToReconcile() ([]*Room, cerrors.ChocolateError) {
+	var result []*Room
+	c := db.Model(&Room{}).
+		Where("status = ? OR srs_client_id IS NOT NULL OR viewers > 0", RoomStatusStreaming).
+		Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomsError,
+			Message:    "error on listing rooms to reconcile",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+		}
+	}
+	return result, nil
+}
+
 // includes owner
 func ListRooms(owner *User, status *RoomStatus, filterId *uint, filterTitle *string, limit uint, page uint) (uint, []*Room, cerrors.ChocolateError) {
 	statement := db.Model(&Room{}).Preload("Owner")
@@ -624,8 +935,16 @@ func GetStreamingRoomCount() uint {
 	return uint(count)
 }
 
-func RecordRoomClientID(roomId uint, client string) cerrors.ChocolateError {
-	c := db.Model(&Room{}).Where("id = ?", roomId).Update("SrsClientID", client)
+// RecordRoomClientID keeps the node of the room if `node`
+// is nil.
+func RecordRoomClientID(roomId uint, node *string, client string) cerrors.ChocolateError {
+	updates := map[string]interface{}{
+		"SrsClientID": client,
+	}
+	if node != nil {
+		updates["SrsNode"] = *node
+	}
+	c := db.Model(&Room{}).Where("id = ?", roomId).Updates(updates)
 	if c.Error != nil {
 		return cerrors.DatabaseError{
 			ID:         cerrors.DatabaseUpdateRoomSrsClientIDError,
@@ -636,6 +955,7 @@ func RecordRoomClientID(roomId uint, client string) cerrors.ChocolateError {
 			Context: map[string]interface{}{
 				"id":        roomId,
 				"client_id": client,
+				"node":      node,
 			},
 		}
 	} else if c.RowsAffected == 0 {
@@ -676,6 +996,32 @@ func RecordRoomStreamID(roomId uint, stream string) cerrors.ChocolateError {
 	return nil
 }
 
+func RecordRoomSrsNode(roomId uint, node string) cerrors.ChocolateError {
+	c := db.Model(&Room{}).Where("id = ?", roomId).Update("SrsNode", node)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateRoomSrsNodeError,
+			Message:    "error when updating room.SrsNode field",
+			StackTrace: cerrors.GetStackTrace(),
+			Sql:        c.Statement.SQL.String(),
+			InnerError: c.Error,
+			Context: map[string]interface{}{
+				"id":   roomId,
+				"node": node,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestRoomNotFound,
+			Message: "requested room not found",
+			Context: map[string]interface{}{
+				"ID": roomId,
+			},
+		}
+	}
+	return nil
+}
+
 func ClearRoomStreamAndClientID(roomId uint) cerrors.ChocolateError {
 	c := db.Model(&Room{}).Where("id = ?", roomId).Updates(map[string]interface{}{
 		"SrsStreamID": nil,
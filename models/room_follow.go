@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm/clause"
+)
+
+type RoomFollow struct {
+	UserID    uint `gorm:"primaryKey"`
+	RoomID    uint `gorm:"primaryKey;index"`
+	CreatedAt time.Time
+}
+
+func FollowRoom(uid uint, roomId uint) cerrors.ChocolateError {
+	c := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&RoomFollow{UserID: uid, RoomID: roomId})
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseFollowRoomError,
+			Message:    "error on following room",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"user_id": uid,
+				"room_id": roomId,
+			},
+		}
+	}
+	return nil
+}
+
+func UnfollowRoom(uid uint, roomId uint) cerrors.ChocolateError {
+	c := db.Delete(&RoomFollow{}, "user_id = ? AND room_id = ?", uid, roomId)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseFollowRoomError,
+			Message:    "error on unfollowing room",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"user_id": uid,
+				"room_id": roomId,
+			},
+		}
+	}
+	return nil
+}
+
+func ListFollowedRoomIDs(uid uint) ([]uint, cerrors.ChocolateError) {
+	ids := []uint{}
+	c := db.Model(&RoomFollow{}).Where("user_id = ?", uid).Pluck("room_id", &ids)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListFollowsError,
+			Message:    "error on listing followed rooms",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"user_id": uid,
+			},
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoomInvite lets people without a permission item in
+// a whitelist room in, by a link the owner shares.
+type RoomInvite struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	RoomID    uint `gorm:"index;not null"`
+	CreatorID uint `gorm:"not null"`
+	// assigned to users redeeming the invite.
+	Label *string `gorm:"type:varchar(64)"`
+	// 0 for unlimited.
+	MaxUses   uint `gorm:"not null;default:0"`
+	Uses      uint `gorm:"not null;default:0"`
+	ExpiresAt time.Time
+	// in minutes, how long the access granted lasts
+	// after redeeming.
+	AccessDuration uint `gorm:"not null"`
+	// whether visitors without an account may redeem
+	// it for playback.
+	AllowGuests bool `gorm:"not null;default:false"`
+}
+
+func (i *RoomInvite) Usable(now time.Time) bool {
+	return now.Before(i.ExpiresAt) && (i.MaxUses == 0 || i.Uses < i.MaxUses)
+}
+
+func CreateRoomInvite(invite *RoomInvite) cerrors.ChocolateError {
+	c := db.Create(invite)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreateRoomInviteError,
+			Message:    "error on creating room invite",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": invite.RoomID,
+			},
+		}
+	}
+	return nil
+}
+
+func ListRoomInvites(roomId uint) ([]*RoomInvite, cerrors.ChocolateError) {
+	var result []*RoomInvite
+	c := db.Where("room_id = ?", roomId).Order("created_at DESC").Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomInvitesError,
+			Message:    "error on listing room invites",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return result, nil
+}
+
+func GetRoomInvite(id uint) (*RoomInvite, cerrors.ChocolateError) {
+	return getRoomInvite(db, id)
+}
+
+func getRoomInvite(tx *gorm.DB, id uint) (*RoomInvite, cerrors.ChocolateError) {
+	invite := RoomInvite{}
+	c := tx.First(&invite, "id = ?", id)
+	if c.Error != nil {
+		if errors.Is(c.Error, gorm.ErrRecordNotFound) {
+			return nil, cerrors.RequestError{
+				ID:      cerrors.RequestRoomInviteNotFound,
+				Message: "invite not found or revoked",
+			}
+		} else {
+			return nil, cerrors.DatabaseError{
+				ID:         cerrors.DatabaseListRoomInvitesError,
+				Message:    "error on lookup room invite",
+				InnerError: c.Error,
+				Sql:        c.Statement.SQL.String(),
+				StackTrace: cerrors.GetStackTrace(),
+				Context: map[string]interface{}{
+					"id": id,
+				},
+			}
+		}
+	}
+	return &invite, nil
+}
+
+func DeleteRoomInvite(roomId uint, id uint) cerrors.ChocolateError {
+	c := db.Delete(&RoomInvite{}, "id = ? AND room_id = ?", id, roomId)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseDeleteRoomInviteError,
+			Message:    "error on deleting room invite",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+				"id":      id,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestRoomInviteNotFound,
+			Message: "invite not found or revoked",
+		}
+	}
+	return nil
+}
+
+// RedeemRoomInvite takes one use of the invite, and
+// calls `grant` in the same transaction, the use is
+// given back if `grant` fails.
+func RedeemRoomInvite(id uint, now time.Time, grant func(tx *gorm.DB, invite *RoomInvite) cerrors.ChocolateError) (*RoomInvite, cerrors.ChocolateError) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	invite, err := getRoomInvite(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+	if err != nil {
+		return nil, err
+	}
+	if !invite.Usable(now) {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestRoomInviteExpired,
+			Message: "invite expired or used up",
+		}
+	}
+
+	c := tx.Model(invite).UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseRedeemRoomInviteError,
+			Message:    "error on taking a use of room invite",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"id": id,
+			},
+		}
+	}
+	invite.Uses++
+
+	if err := grant(tx, invite); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCommitTransactionError,
+			Message:    "error while redeeming room invite",
+			StackTrace: cerrors.GetStackTrace(),
+			InnerError: err,
+		}
+	}
+	return invite, nil
+}
+
+// GrantTemporaryRoomAccess adds the user to the room
+// until `meta.ExpiresAt`, an existing permanent item or
+// one lasting longer is kept as is.
+func GrantTemporaryRoomAccess(tx *gorm.DB, roomId uint, uid uint, meta PermissionItemMeta) cerrors.ChocolateError {
+	until := *meta.ExpiresAt
+	item := PermissionItem{}
+	c := tx.Where("room_id = ? AND subject_type = ? AND subject_user_id = ?", roomId, PermissionSubjectTypeUser, uid).Limit(1).Find(&item)
+	if c.Error == nil {
+		if item.ID == 0 {
+			item = PermissionItem{
+				RoomID:        roomId,
+				SubjectType:   PermissionSubjectTypeUser,
+				SubjectUserID: &uid,
+			}
+			meta.apply(&item)
+			c = tx.Create(&item)
+		} else if item.ExpiresAt != nil && item.ExpiresAt.Before(until) {
+			c = tx.Model(&item).Update("expires_at", until)
+		}
+	}
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreatePermissionItemError,
+			Message:    "error on granting temporary room access",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+				"user_id": uid,
+			},
+		}
+	}
+	return nil
+}
+
+// AssignUserLabel assigns the label to the user if not
+// yet, creating the label if needed.
+func AssignUserLabel(tx *gorm.DB, uid uint, label string) cerrors.ChocolateError {
+	c := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Label{Name: label})
+	if c.Error == nil {
+		c = tx.Table("user_labels").
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(map[string]interface{}{"label_name": label, "user_id": uid})
+	}
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreateUserLabelError,
+			Message:    "error on assigning user label",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"user_id": uid,
+				"label":   label,
+			},
+		}
+	}
+	return nil
+}
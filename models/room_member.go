@@ -0,0 +1,164 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoomAbilities are what one can do to a room, the owner
+// and admins can do all of them, plus deleting the room,
+// managing forwards and members.
+type RoomAbilities struct {
+	// title, description, category, tags, visibility
+	// and schedules.
+	AbilityEditRoom bool `json:"edit_room"`
+	// permission type, items and invites.
+	AbilityManagePermission bool `json:"manage_permission"`
+	// password, access policy and max viewers.
+	AbilityManageAccess  bool `json:"manage_access"`
+	AbilityModerateChat  bool `json:"moderate_chat"`
+	AbilityControlStream bool `json:"control_stream"`
+	// broadcasts and health.
+	AbilityViewAnalytics bool `json:"view_analytics"`
+}
+
+var FullRoomAbilities = RoomAbilities{
+	AbilityEditRoom:         true,
+	AbilityManagePermission: true,
+	AbilityManageAccess:     true,
+	AbilityModerateChat:     true,
+	AbilityControlStream:    true,
+	AbilityViewAnalytics:    true,
+}
+
+// Covers tells whether all abilities required are held.
+func (a RoomAbilities) Covers(required RoomAbilities) bool {
+	return (!required.AbilityEditRoom || a.AbilityEditRoom) &&
+		(!required.AbilityManagePermission || a.AbilityManagePermission) &&
+		(!required.AbilityManageAccess || a.AbilityManageAccess) &&
+		(!required.AbilityModerateChat || a.AbilityModerateChat) &&
+		(!required.AbilityControlStream || a.AbilityControlStream) &&
+		(!required.AbilityViewAnalytics || a.AbilityViewAnalytics)
+}
+
+type RoomMemberRole string
+
+const (
+	RoomMemberRoleModerator RoomMemberRole = "moderator"
+	RoomMemberRoleCoHost    RoomMemberRole = "co-host"
+)
+
+// Abilities returns nil for unknown roles.
+func (r RoomMemberRole) Abilities() *RoomAbilities {
+	switch r {
+	case RoomMemberRoleModerator:
+		return &RoomAbilities{
+			AbilityManagePermission: true,
+			AbilityModerateChat:     true,
+		}
+	case RoomMemberRoleCoHost:
+		abilities := FullRoomAbilities
+		return &abilities
+	}
+	return nil
+}
+
+type RoomMember struct {
+	RoomID    uint           `gorm:"primaryKey"`
+	UserID    uint           `gorm:"primaryKey;index"`
+	User      User           `gorm:"constraint:OnDelete:CASCADE"`
+	Role      RoomMemberRole `gorm:"type:varchar(16);not null"`
+	CreatedAt time.Time
+}
+
+func ListRoomMembers(roomId uint) ([]*RoomMember, cerrors.ChocolateError) {
+	var result []*RoomMember
+	c := db.Preload("User").Where("room_id = ?", roomId).Order("created_at").Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomMembersError,
+			Message:    "error on listing room members",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return result, nil
+}
+
+// GetRoomMember returns nil if the user is not a member.
+func GetRoomMember(roomId uint, uid uint) (*RoomMember, cerrors.ChocolateError) {
+	member := RoomMember{}
+	c := db.First(&member, "room_id = ? AND user_id = ?", roomId, uid)
+	if c.Error != nil {
+		if errors.Is(c.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomMembersError,
+			Message:    "error on lookup room member",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+				"user_id": uid,
+			},
+		}
+	}
+	return &member, nil
+}
+
+// SetRoomMember adds the member, or changes the role if
+// already is.
+func SetRoomMember(roomId uint, uid uint, role RoomMemberRole) cerrors.ChocolateError {
+	c := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role"}),
+	}).Create(&RoomMember{RoomID: roomId, UserID: uid, Role: role})
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateRoomMemberError,
+			Message:    "error on setting room member",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+				"user_id": uid,
+				"role":    role,
+			},
+		}
+	}
+	return nil
+}
+
+func DeleteRoomMember(roomId uint, uid uint) cerrors.ChocolateError {
+	c := db.Delete(&RoomMember{}, "room_id = ? AND user_id = ?", roomId, uid)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateRoomMemberError,
+			Message:    "error on deleting room member",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+				"user_id": uid,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestRoomMemberNotFound,
+			Message: "the user is not a member of the room",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	cerrors "github.com/sheey11/chocolate/errors"
+)
+
+type RoomTag struct {
+	RoomID uint   `gorm:"primaryKey"`
+	Name   string `gorm:"type:varchar(24);primaryKey;index"`
+}
+
+// SetRoomTags replaces all tags of the room.
+func SetRoomTags(roomId uint, tags []string) cerrors.ChocolateError {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	c := tx.Delete(&RoomTag{}, "room_id = ?", roomId)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateRoomTagsError,
+			Message:    "error on clearing room tags",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+
+	if len(tags) > 0 {
+		rows := make([]RoomTag, 0, len(tags))
+		for _, tag := range tags {
+			rows = append(rows, RoomTag{RoomID: roomId, Name: tag})
+		}
+		c = tx.Create(&rows)
+		if c.Error != nil {
+			return cerrors.DatabaseError{
+				ID:         cerrors.DatabaseUpdateRoomTagsError,
+				Message:    "error on creating room tags",
+				InnerError: c.Error,
+				Sql:        c.Statement.SQL.String(),
+				StackTrace: cerrors.GetStackTrace(),
+				Context: map[string]interface{}{
+					"room_id": roomId,
+					"tags":    tags,
+				},
+			}
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCommitTransactionError,
+			Message:    "error while setting room tags",
+			StackTrace: cerrors.GetStackTrace(),
+			InnerError: err,
+		}
+	}
+	return nil
+}
+
+type TagCount struct {
+	Name  string `json:"name"`
+	Rooms uint   `json:"rooms"`
+}
+
+// ListPopularTags lists tags used by most rooms.
+func ListPopularTags(limit uint) ([]*TagCount, cerrors.ChocolateError) {
+	var result []*TagCount
+	c := db.Model(&RoomTag{}).
+		Select("room_tags.name AS name, COUNT(*) AS rooms").
+		Joins("JOIN rooms ON rooms.id = room_tags.room_id AND rooms.deleted_at IS NULL AND rooms.visibility = ?", RoomVisibilityPublic).
+		Group("room_tags.name").
+		Order("rooms DESC, name").
+		Limit(int(limit)).
+		Scan(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomTagsError,
+			Message:    "error on listing popular tags",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm"
+)
+
+// RoomTemplate names a room of the owner whose settings
+// new rooms are created with, see CreateRoomFromTemplate.
+// Settings are read when creating, so changes made to the
+// room apply to rooms created afterwards.
+type RoomTemplate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	OwnerID   uint      `gorm:"uniqueIndex:idx_owner_template_name;not null" json:"-"`
+	Owner     User      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Name      string    `gorm:"type:varchar(32);uniqueIndex:idx_owner_template_name;not null" json:"name"`
+	RoomID    uint      `gorm:"index;not null" json:"room_id"`
+	Room      Room      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func CreateRoomTemplate(template *RoomTemplate) cerrors.ChocolateError {
+	c := db.Create(template)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreateRoomTemplateError,
+			Message:    "error on creating room template",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"owner_id": template.OwnerID,
+				"room_id":  template.RoomID,
+			},
+		}
+	}
+	return nil
+}
+
+// ListRoomTemplates leaves out templates of deleted rooms.
+func ListRoomTemplates(ownerId uint) ([]*RoomTemplate, cerrors.ChocolateError) {
+	var result []*RoomTemplate
+	c := db.Joins("JOIN rooms ON rooms.id = room_templates.room_id AND rooms.deleted_at IS NULL").
+		Where("room_templates.owner_id = ?", ownerId).
+		Order("room_templates.name").
+		Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomTemplatesError,
+			Message:    "error on listing room templates",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"owner_id": ownerId,
+			},
+		}
+	}
+	return result, nil
+}
+
+func GetRoomTemplate(ownerId uint, id uint) (*RoomTemplate, cerrors.ChocolateError) {
+	template := RoomTemplate{}
+	c := db.First(&template, "id = ? AND owner_id = ?", id, ownerId)
+	if c.Error != nil {
+		if errors.Is(c.Error, gorm.ErrRecordNotFound) {
+			return nil, cerrors.RequestError{
+				ID:      cerrors.RequestRoomTemplateNotFound,
+				Message: "template not found",
+			}
+		}
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomTemplatesError,
+			Message:    "error on lookup room template",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"owner_id": ownerId,
+				"id":       id,
+			},
+		}
+	}
+	return &template, nil
+}
+
+func DeleteRoomTemplate(ownerId uint, id uint) cerrors.ChocolateError {
+	c := db.Delete(&RoomTemplate{}, "id = ? AND owner_id = ?", id, ownerId)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseDeleteRoomTemplateError,
+			Message:    "error on deleting room template",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"owner_id": ownerId,
+				"id":       id,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestRoomTemplateNotFound,
+			Message: "template not found",
+		}
+	}
+	return nil
+}
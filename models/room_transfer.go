@@ -0,0 +1,211 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoomTransfer is an ownership transfer offered by the
+// owner, pending until the recipient accepts it. A room
+// has at most one.
+type RoomTransfer struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	RoomID    uint `gorm:"uniqueIndex;not null"`
+	Room      Room `gorm:"constraint:OnDelete:CASCADE"`
+	FromID    uint `gorm:"not null"`
+	From      User `gorm:"constraint:OnDelete:CASCADE"`
+	ToID      uint `gorm:"index;not null"`
+	To        User `gorm:"constraint:OnDelete:CASCADE"`
+	ExpiresAt time.Time
+}
+
+// CreateRoomTransfer replaces the pending transfer of
+// the room, if any.
+func CreateRoomTransfer(transfer *RoomTransfer) cerrors.ChocolateError {
+	c := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"created_at", "from_id", "to_id", "expires_at"}),
+	}).Create(transfer)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreateRoomTransferError,
+			Message:    "error on creating room transfer",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": transfer.RoomID,
+				"to_id":   transfer.ToID,
+			},
+		}
+	}
+	return nil
+}
+
+func GetRoomTransfer(roomId uint) (*RoomTransfer, cerrors.ChocolateError) {
+	transfer := RoomTransfer{}
+	c := db.Preload("Room").Preload("From").Preload("To").First(&transfer, "room_id = ?", roomId)
+	if c.Error != nil {
+		if errors.Is(c.Error, gorm.ErrRecordNotFound) {
+			return nil, cerrors.RequestError{
+				ID:      cerrors.RequestRoomTransferNotFound,
+				Message: "no pending transfer of the room",
+			}
+		}
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomTransfersError,
+			Message:    "error on lookup room transfer",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return &transfer, nil
+}
+
+// ListIncomingRoomTransfers lists transfers offered to
+// the user and not yet expired.
+func ListIncomingRoomTransfers(uid uint, now time.Time) ([]*RoomTransfer, cerrors.ChocolateError) {
+	var result []*RoomTransfer
+	c := db.Preload("Room").Preload("From").Preload("To").
+		Joins("JOIN rooms ON rooms.id = room_transfers.room_id AND rooms.deleted_at IS NULL").
+		Where("room_transfers.to_id = ? AND room_transfers.expires_at > ?", uid, now).
+		Order("room_transfers.created_at DESC").
+		Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListRoomTransfersError,
+			Message:    "error on listing room transfers",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"user_id": uid,
+			},
+		}
+	}
+	return result, nil
+}
+
+func DeleteRoomTransfer(roomId uint) cerrors.ChocolateError {
+	c := db.Delete(&RoomTransfer{}, "room_id = ?", roomId)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseDeleteRoomTransferError,
+			Message:    "error on deleting room transfer",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestRoomTransferNotFound,
+			Message: "no pending transfer of the room",
+		}
+	}
+	return nil
+}
+
+// TransferRoom hands the room to `to`, unless it owns
+// `maxRooms` rooms already, nil for no limit. The push key
+// is regenerated, so the previous owner can not stream
+// anymore, and the pending transfer, members, forward
+// destinations and templates made from the room are
+// removed, they belong to the previous owner.
+func TransferRoom(roomId uint, to *User, maxRooms *uint) cerrors.ChocolateError {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	dbError := func(c *gorm.DB, message string) cerrors.ChocolateError {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseTransferRoomError,
+			Message:    message,
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+				"to_id":   to.ID,
+			},
+		}
+	}
+
+	// serializes transfers and creations for the recipient,
+	// so the count below holds until commit.
+	c := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&User{}, "id = ?", to.ID)
+	if c.Error != nil {
+		if errors.Is(c.Error, gorm.ErrRecordNotFound) {
+			return cerrors.RequestError{
+				ID:      cerrors.RequestUserNotFound,
+				Message: "user not found",
+			}
+		}
+		return dbError(c, "error on locking recipient")
+	}
+
+	if maxRooms != nil {
+		var count int64
+		c = tx.Model(&Room{}).Where("owner_id = ?", to.ID).Count(&count)
+		if c.Error != nil {
+			return dbError(c, "error on counting rooms of recipient")
+		}
+		if uint(count) >= *maxRooms {
+			return cerrors.RequestError{
+				ID:      cerrors.RequestRoomCountReachedMax,
+				Message: "the recipient has reached the max rooms allowed",
+			}
+		}
+	}
+
+	c = tx.Model(&Room{}).Where("id = ?", roomId).Updates(map[string]interface{}{
+		"owner_id": to.ID,
+		"push_key": generateRoomPushKey(),
+	})
+	if c.Error != nil {
+		return dbError(c, "error on updating room owner")
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestRoomNotFound,
+			Message: "room not found",
+		}
+	}
+
+	if c = tx.Delete(&RoomTransfer{}, "room_id = ?", roomId); c.Error != nil {
+		return dbError(c, "error on deleting room transfer")
+	}
+	if c = tx.Delete(&RoomMember{}, "room_id = ?", roomId); c.Error != nil {
+		return dbError(c, "error on removing room members")
+	}
+	// they carry the stream keys of the previous owner.
+	if c = tx.Unscoped().Delete(&ForwardDestination{}, "room_id = ?", roomId); c.Error != nil {
+		return dbError(c, "error on deleting forward destinations")
+	}
+	if c = tx.Delete(&RoomTemplate{}, "room_id = ?", roomId); c.Error != nil {
+		return dbError(c, "error on deleting templates of the room")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCommitTransactionError,
+			Message:    "error on committing room transfer",
+			InnerError: err,
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+				"to_id":   to.ID,
+			},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"gorm.io/gorm"
+)
+
+type ScheduleRecurrence string
+
+const (
+	ScheduleRecurrenceNone   ScheduleRecurrence = ""
+	ScheduleRecurrenceDaily  ScheduleRecurrence = "daily"
+	ScheduleRecurrenceWeekly ScheduleRecurrence = "weekly"
+)
+
+// Schedule is an announced broadcast of a room, it
+// repeats at the same UTC time if it is recurrent.
+type Schedule struct {
+	gorm.Model
+	RoomID      uint `gorm:"index;not null"`
+	Room        Room
+	Title       string `gorm:"type:varchar(64);not null"`
+	Description string `gorm:"type:varchar(1024)"`
+	StartTime   time.Time
+	// minutes
+	Duration   uint
+	Recurrence ScheduleRecurrence `gorm:"type:varchar(8)"`
+	// the last occurrence starts before it, nil for
+	// forever.
+	RecurrenceEnd *time.Time `gorm:"default:null"`
+}
+
+func (s *Schedule) DurationTime() time.Duration {
+	return time.Minute * time.Duration(s.Duration)
+}
+
+func ListRoomSchedules(roomId uint) ([]*Schedule, cerrors.ChocolateError) {
+	var result []*Schedule
+	c := db.Model(&Schedule{}).Where("room_id = ?", roomId).Order("start_time").Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListSchedulesError,
+			Message:    "error on listing room schedules",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return result, nil
+}
+
+// ListActiveSchedules lists schedules that may have an
+// occurrence between `from` and `to`, of the given rooms
+// if `roomIds` is not nil. Rooms are preloaded along
+// with owners.
+func ListActiveSchedules(roomIds []uint, from time.Time, to time.Time) ([]*Schedule, cerrors.ChocolateError) {
+	statement := db.Model(&Schedule{}).
+		Preload("Room").
+		Preload("Room.Owner").
+		Where("start_time <= ?", to).
+		Where("(recurrence = ? AND start_time + duration * interval '1 minute' >= ?) OR (recurrence <> ? AND (recurrence_end IS NULL OR recurrence_end >= ?))",
+			ScheduleRecurrenceNone, from, ScheduleRecurrenceNone, from)
+	if roomIds != nil {
+		statement = statement.Where("room_id IN ?", roomIds)
+	}
+
+	var result []*Schedule
+	c := statement.Find(&result)
+	if c.Error != nil {
+		return nil, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListSchedulesError,
+			Message:    "error on listing active schedules",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+		}
+	}
+	return result, nil
+}
+
+func GetSchedule(roomId uint, id uint) (*Schedule, cerrors.ChocolateError) {
+	schedule := Schedule{}
+	c := db.First(&schedule, "id = ? AND room_id = ?", id, roomId)
+	if c.Error != nil {
+		if errors.Is(c.Error, gorm.ErrRecordNotFound) {
+			return nil, cerrors.RequestError{
+				ID:      cerrors.RequestScheduleNotFound,
+				Message: "schedule not found",
+			}
+		} else {
+			return nil, cerrors.DatabaseError{
+				ID:         cerrors.DatabaseListSchedulesError,
+				Message:    "error on lookup schedule",
+				InnerError: c.Error,
+				Sql:        c.Statement.SQL.String(),
+				StackTrace: cerrors.GetStackTrace(),
+				Context: map[string]interface{}{
+					"room_id": roomId,
+					"id":      id,
+				},
+			}
+		}
+	}
+	return &schedule, nil
+}
+
+func CountRoomSchedules(roomId uint) (uint, cerrors.ChocolateError) {
+	var count int64
+	c := db.Model(&Schedule{}).Where("room_id = ?", roomId).Count(&count)
+	if c.Error != nil {
+		return 0, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseListSchedulesError,
+			Message:    "error on counting room schedules",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+			},
+		}
+	}
+	return uint(count), nil
+}
+
+func CreateSchedule(schedule *Schedule) cerrors.ChocolateError {
+	c := db.Omit("Room").Create(schedule)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseCreateScheduleError,
+			Message:    "error on creating schedule",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": schedule.RoomID,
+			},
+		}
+	}
+	return nil
+}
+
+func UpdateSchedule(schedule *Schedule) cerrors.ChocolateError {
+	c := db.Model(schedule).
+		Select("title", "description", "start_time", "duration", "recurrence", "recurrence_end").
+		Updates(schedule)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateScheduleError,
+			Message:    "error on updating schedule",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": schedule.RoomID,
+				"id":      schedule.ID,
+			},
+		}
+	}
+	return nil
+}
+
+func DeleteSchedule(roomId uint, id uint) cerrors.ChocolateError {
+	c := db.Delete(&Schedule{}, "id = ? AND room_id = ?", id, roomId)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseDeleteScheduleError,
+			Message:    "error on deleting schedule",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"room_id": roomId,
+				"id":      id,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestScheduleNotFound,
+			Message: "schedule not found",
+		}
+	}
+	return nil
+}
+
+// LinkBroadcastSchedule records the broadcast as the
+// occurrence starts at `at` of the schedule.
+func LinkBroadcastSchedule(broadcastId uint, scheduleId uint, at time.Time) cerrors.ChocolateError {
+	c := db.Model(&Broadcast{}).
+		Where("id = ?", broadcastId).
+		Updates(map[string]interface{}{
+			"schedule_id":  scheduleId,
+			"scheduled_at": at,
+		})
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateBroadcastError,
+			Message:    "error on linking broadcast to schedule",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"broadcast_id": broadcastId,
+				"schedule_id":  scheduleId,
+			},
+		}
+	}
+	return nil
+}
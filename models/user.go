@@ -11,6 +11,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type User struct {
@@ -22,7 +23,11 @@ type User struct {
 	Password     string  `gorm:"type:varchar(256);not null" json:"-"`
 	Salt         string  `gorm:"type:varchar(8);not null" json:"-"`
 	MaxRoomCount uint    `gorm:"not null;default:0;" json:"max_room_count"`
-	Rooms        []Room  `gorm:"foreignKey:owner_id;constraint:OnDelete:CASCADE"`
+	// concurrent playback sessions, 0 for unlimited.
+	MaxPlaybackSessions uint `gorm:"not null;default:0;" json:"max_playback_sessions"`
+	// carried by calendar tokens, bumping it revokes them.
+	CalendarTokenVersion uint   `gorm:"not null;default:0;" json:"-"`
+	Rooms                []Room `gorm:"foreignKey:owner_id;constraint:OnDelete:CASCADE"`
 }
 
 func CountAdmins(tx *gorm.DB) (uint, cerrors.ChocolateError) {
@@ -136,25 +141,33 @@ func GetUserByID(id uint) *User {
 	return &user
 }
 
-func (u *User) SummaryRooms(includePermType bool) []map[string]interface{} {
+// SummaryRooms summaries rooms of the user, `detailed`
+// is for the owner and admins, otherwise only rooms
+// listed publicly are included.
+func (u *User) SummaryRooms(detailed bool) []map[string]interface{} {
 	if u.Rooms == nil {
 		logrus.Fatalf("no room preloaded")
 	}
-	result := make([]map[string]interface{}, len(u.Rooms))
-	for i, room := range u.Rooms {
-		result[i] = map[string]interface{}{
+	result := make([]map[string]interface{}, 0, len(u.Rooms))
+	for _, room := range u.Rooms {
+		if !detailed && room.Visibility != RoomVisibilityPublic {
+			continue
+		}
+		summary := map[string]interface{}{
 			"id":      room.ID,
 			"uid":     room.UID,
 			"title":   room.Title,
 			"status":  room.Status.ToString(),
 			"viewers": room.Viewers,
 		}
-		if includePermType {
+		if detailed {
 			if room.PermissionItems == nil || len(room.PermissionItems) == 0 {
 				room.LoadPermissionItems()
 			}
-			result[i]["permission_type"] = room.PermissionType
+			summary["permission_type"] = room.PermissionType
+			summary["visibility"] = room.Visibility
 		}
+		result = append(result, summary)
 	}
 	return result
 }
@@ -386,6 +399,76 @@ func ModifyUserMaxRoom(username string, maxRooms uint) cerrors.ChocolateError {
 	return nil
 }
 
+func ModifyUserMaxPlaybackSessions(username string, maxSessions uint) cerrors.ChocolateError {
+	c := db.Model(&User{}).Where("username = ?", username).Update("max_playback_sessions", maxSessions)
+	if c.Error != nil {
+		return cerrors.DatabaseError{
+			ID:         cerrors.DatabaseClearRoomPermissionItemError,
+			Message:    "error updating user's max_playback_sessions",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+		}
+	} else if c.RowsAffected == 0 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestUserNotFound,
+			Message: "user not found",
+		}
+	}
+	return nil
+}
+
+// GetUserCalendarTokenVersion returns false if the user
+// does not exist.
+func GetUserCalendarTokenVersion(uid uint) (uint, bool, cerrors.ChocolateError) {
+	var versions []uint
+	c := db.Model(&User{}).Where("id = ?", uid).Pluck("calendar_token_version", &versions)
+	if c.Error != nil {
+		return 0, false, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateCalendarTokenError,
+			Message:    "error on lookup user calendar token version",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"user_id": uid,
+			},
+		}
+	}
+	if len(versions) == 0 {
+		return 0, false, nil
+	}
+	return versions[0], true, nil
+}
+
+// RotateUserCalendarToken bumps the version, and returns
+// the new one.
+func RotateUserCalendarToken(uid uint) (uint, cerrors.ChocolateError) {
+	user := User{}
+	c := db.Model(&user).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "calendar_token_version"}}}).
+		Where("id = ?", uid).
+		Update("calendar_token_version", gorm.Expr("calendar_token_version + 1"))
+	if c.Error != nil {
+		return 0, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseUpdateCalendarTokenError,
+			Message:    "error on rotating user calendar token",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"user_id": uid,
+			},
+		}
+	} else if c.RowsAffected == 0 {
+		return 0, cerrors.RequestError{
+			ID:      cerrors.RequestUserNotFound,
+			Message: "user not found",
+		}
+	}
+	return user.CalendarTokenVersion, nil
+}
+
 // including labesl
 func ListUsers(filterRole *Role, filterId *uint, filterName *string, limit uint, page uint) (uint, []*User, cerrors.ChocolateError) {
 	statement := db.Model(&User{}).Limit(int(limit)).Offset(int((page - 1) * limit))
@@ -168,6 +168,29 @@ type PubChatInfo struct {
 	Content string          `json:"content"`
 }
 
+// GetUserTotalWatchTime sums up all watching sessions of
+// the user, unfinished ones count until now.
+func GetUserTotalWatchTime(uid uint) (time.Duration, cerrors.ChocolateError) {
+	var seconds float64
+	c := db.Model(&UserWatchingSession{}).
+		Select("COALESCE(SUM(EXTRACT(EPOCH FROM (COALESCE(end_time, NOW()) - start_time))), 0)").
+		Where("user_id = ?", uid).
+		Scan(&seconds)
+	if c.Error != nil {
+		return 0, cerrors.DatabaseError{
+			ID:         cerrors.DatabaseSumUserWatchTimeError,
+			Message:    "error on summing user watch time",
+			InnerError: c.Error,
+			Sql:        c.Statement.SQL.String(),
+			StackTrace: cerrors.GetStackTrace(),
+			Context: map[string]interface{}{
+				"user_id": uid,
+			},
+		}
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
 func GetUserWatchingHistory(uid uint, startTime time.Time, endTime time.Time) ([]*RoomWatchingReport, cerrors.ChocolateError) {
 	if startTime.Add(12 * time.Hour).Before(endTime) {
 		return nil, cerrors.RequestError{
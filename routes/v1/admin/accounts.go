@@ -32,6 +32,7 @@ func mountAccountsRoutes(r *gin.RouterGroup) {
 	g.PUT("/:username/label/:label", handleAccountLabelAppend)
 	g.DELETE("/:username/label/:label", handleAccountLabelDeletion)
 	g.PUT("/:username/max-room/:count", handleAccountMaxRoomModification)
+	g.PUT("/:username/max-playback/:count", handleAccountMaxPlaybackModification)
 }
 
 // this method is only used when the serve is backed
@@ -137,12 +138,13 @@ func handleAccountList(c *gin.Context) {
 	}
 
 	type userAdminListInfo struct {
-		ID         uint     `json:"id"`
-		Role       string   `json:"role"`
-		Labels     []string `json:"labels"`
-		Username   string   `json:"username"`
-		MaxRoom    uint     `json:"max_rooms"`
-		OwnedRooms uint     `json:"owned_rooms"`
+		ID          uint     `json:"id"`
+		Role        string   `json:"role"`
+		Labels      []string `json:"labels"`
+		Username    string   `json:"username"`
+		MaxRoom     uint     `json:"max_rooms"`
+		OwnedRooms  uint     `json:"owned_rooms"`
+		MaxPlayback uint     `json:"max_playback_sessions"`
 	}
 
 	result := lo.Map(users, func(user *models.User, _ int) userAdminListInfo {
@@ -151,11 +153,12 @@ func handleAccountList(c *gin.Context) {
 			Labels: lo.Map(user.Labels, func(l models.Label, _ int) string {
 				return l.Name
 			}),
-			ID:         user.ID,
-			Role:       user.RoleName,
-			Username:   user.Username,
-			MaxRoom:    user.MaxRoomCount,
-			OwnedRooms: roomCount,
+			ID:          user.ID,
+			Role:        user.RoleName,
+			Username:    user.Username,
+			MaxRoom:     user.MaxRoomCount,
+			OwnedRooms:  roomCount,
+			MaxPlayback: user.MaxPlaybackSessions,
 		}
 	})
 
@@ -220,12 +223,13 @@ func handleAccountInfoLookup(c *gin.Context) {
 	}
 
 	type userAdminInfo struct {
-		ID       uint                     `json:"id"`
-		Role     string                   `json:"role"`
-		Username string                   `json:"username"`
-		Labels   []string                 `json:"labels"`
-		MaxRoom  uint                     `json:"max_rooms"`
-		Rooms    []map[string]interface{} `json:"rooms"`
+		ID          uint                     `json:"id"`
+		Role        string                   `json:"role"`
+		Username    string                   `json:"username"`
+		Labels      []string                 `json:"labels"`
+		MaxRoom     uint                     `json:"max_rooms"`
+		Rooms       []map[string]interface{} `json:"rooms"`
+		MaxPlayback uint                     `json:"max_playback_sessions"`
 	}
 
 	info := userAdminInfo{
@@ -235,8 +239,9 @@ func handleAccountInfoLookup(c *gin.Context) {
 		Labels: lo.Map(user.Labels, func(l models.Label, _ int) string {
 			return l.Name
 		}),
-		MaxRoom: user.MaxRoomCount,
-		Rooms:   user.SummaryRooms(true),
+		MaxRoom:     user.MaxRoomCount,
+		Rooms:       user.SummaryRooms(true),
+		MaxPlayback: user.MaxPlaybackSessions,
 	}
 
 	c.JSON(http.StatusOK, common.Response{
@@ -443,3 +448,30 @@ func handleAccountMaxRoomModification(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, common.SampleResponse(0, "modified"))
 }
+
+// a count of 0 removes the cap.
+func handleAccountMaxPlaybackModification(c *gin.Context) {
+	username := c.Param("username")
+	countStr := c.Param("count")
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidParameter, "bad count parameter"))
+		return
+	}
+
+	cerr := service.ModifyUserMaxPlaybackSessions(username, uint(count))
+	if cerr != nil {
+		if rerr, ok := cerr.(errors.RequestError); ok {
+			c.Abort()
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+			return
+		} else {
+			c.Abort()
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, common.SampleResponse(0, "modified"))
+}
@@ -0,0 +1,92 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/middleware"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountCategoriesRoutes(r *gin.RouterGroup) {
+	g := r.Group("categories")
+	g.Use(middleware.AbilityRequired(models.Role{AbilityManageRoom: true}))
+	g.POST("/", handleCategoryCreation)
+	g.PUT("/:cid", handleCategoryRename)
+	g.DELETE("/:cid", handleCategoryDeletion)
+}
+
+func respondCategoryError(c *gin.Context, err cerrors.ChocolateError) {
+	c.Abort()
+	if rerr, ok := err.(cerrors.RequestError); ok {
+		c.JSON(http.StatusBadRequest, rerr.ToResponse())
+	} else {
+		logrus.WithError(err).Error("error when managing categories")
+		c.JSON(http.StatusInternalServerError, err.ToResponse())
+	}
+}
+
+func handleCategoryCreation(c *gin.Context) {
+	data := struct {
+		Name string `json:"name"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	category, err := service.CreateCategory(data.Name)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, common.Response{
+		"code":     0,
+		"message":  "ok",
+		"category": category,
+	})
+}
+
+func handleCategoryRename(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("cid"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid id"))
+		return
+	}
+	data := struct {
+		Name string `json:"name"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	if cerr := service.RenameCategory(uint(id), data.Name); cerr != nil {
+		respondCategoryError(c, cerr)
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
+
+func handleCategoryDeletion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("cid"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid id"))
+		return
+	}
+
+	if cerr := service.DeleteCategory(uint(id)); cerr != nil {
+		respondCategoryError(c, cerr)
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
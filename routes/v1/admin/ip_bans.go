@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/middleware"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountIPBanRoutes(r *gin.RouterGroup) {
+	g := r.Group("ip-bans")
+	g.Use(middleware.AbilityRequired(models.Role{AbilityManageRoom: true}))
+	g.GET("/", handleIPBanList)
+	g.POST("/", handleIPBanCreation)
+	g.DELETE("/:bid", handleIPBanDeletion)
+}
+
+func respondIPBanError(c *gin.Context, err cerrors.ChocolateError) {
+	c.Abort()
+	if rerr, ok := err.(cerrors.RequestError); ok {
+		c.JSON(http.StatusBadRequest, rerr.ToResponse())
+	} else {
+		logrus.WithError(err).Error("error when managing ip bans")
+		c.JSON(http.StatusInternalServerError, err.ToResponse())
+	}
+}
+
+// lists all bans, or those of the `room` query, which is
+// `global` for bans of all rooms.
+func handleIPBanList(c *gin.Context) {
+	var roomId *uint
+	all := true
+	if room := c.Query("room"); room == "global" {
+		all = false
+	} else if room != "" {
+		id, err := strconv.Atoi(room)
+		if err != nil || id < 0 {
+			c.Abort()
+			c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid room"))
+			return
+		}
+		all = false
+		roomId = new(uint)
+		*roomId = uint(id)
+	}
+
+	bans, err := service.ListIPBans(roomId, all)
+	if err != nil {
+		respondIPBanError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"bans":    bans,
+	})
+}
+
+func handleIPBanCreation(c *gin.Context) {
+	data := service.IPBanData{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	ban, err := service.CreateIPBan(service.GetUserFromContext(c), &data)
+	if err != nil {
+		respondIPBanError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, common.Response{
+		"code":    0,
+		"message": "ok",
+		"ban":     ban,
+	})
+}
+
+func handleIPBanDeletion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("bid"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid id"))
+		return
+	}
+
+	if err := service.DeleteIPBan(uint(id)); err != nil {
+		respondIPBanError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
@@ -8,4 +8,7 @@ func Mount(r *gin.RouterGroup) {
 	mountLogsRoutes(admin)
 	mountRoomRoutes(admin)
 	mountRolesRoutes(admin)
+	mountSrsRoutes(admin)
+	mountCategoriesRoutes(admin)
+	mountIPBanRoutes(admin)
 }
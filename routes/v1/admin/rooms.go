@@ -25,6 +25,10 @@ func mountRoomRoutes(r *gin.RouterGroup) {
 	g.PATCH("/:id/:action", handleRoomActions)
 	g.DELETE("/:id", handleRoomDeletion)
 	g.GET("/:id/timeline", handleRoomTimelineRetrival)
+	g.GET("/:id/broadcasts", handleRoomBroadcastList)
+	g.GET("/:id/broadcasts/:bid", handleRoomBroadcastRetrival)
+	g.POST("/:id/access-policy/dry-run", handleRoomAccessPolicyDryRun)
+	g.PUT("/:id/owner/:username", handleRoomOwnerModification)
 }
 
 func handleListRooms(c *gin.Context) {
@@ -113,6 +117,7 @@ func handleListRooms(c *gin.Context) {
 		OwnerID        uint                      `json:"owner_id"`
 		OwnerName      string                    `json:"owner_username"`
 		PermissionType models.RoomPermissionType `json:"permission_type"`
+		Visibility     models.RoomVisibility     `json:"visibility"`
 		LastStreaming  time.Time                 `json:"last_streaming"`
 	}
 
@@ -126,6 +131,7 @@ func handleListRooms(c *gin.Context) {
 			OwnerID:        room.OwnerID,
 			OwnerName:      room.Owner.Username,
 			PermissionType: room.PermissionType,
+			Visibility:     room.Visibility,
 			LastStreaming:  room.LastStreamingAt,
 		}
 	})
@@ -162,10 +168,15 @@ func handleRoomInfoRetrival(c *gin.Context) {
 	}
 
 	type permissionItemAdminInfo struct {
-		Type     models.PermissionSubjectType `json:"type"`
-		Label    *string                      `json:"label"`
-		UserID   *uint                        `json:"user_id"`
-		UserName *string                      `json:"username"`
+		Type      models.PermissionSubjectType `json:"type"`
+		Label     *string                      `json:"label"`
+		Role      *string                      `json:"role"`
+		UserID    *uint                        `json:"user_id"`
+		UserName  *string                      `json:"username"`
+		ExpiresAt *time.Time                   `json:"expires_at"`
+		Reason    string                       `json:"reason"`
+		CreatedBy *uint                        `json:"created_by"`
+		CreatedAt time.Time                    `json:"created_at"`
 	}
 
 	type roomAdminListInfo struct {
@@ -180,6 +191,13 @@ func handleRoomInfoRetrival(c *gin.Context) {
 		PermissionItems []permissionItemAdminInfo `json:"permission_items"`
 		LastStreaming   time.Time                 `json:"last_streaming"`
 		Stream          *service.SRSStreamInfo    `json:"srs_stream"`
+		Description     string                    `json:"description"`
+		Category        *models.Category          `json:"category"`
+		Tags            []string                  `json:"tags"`
+		Visibility      models.RoomVisibility     `json:"visibility"`
+		AccessPolicy    string                    `json:"access_policy"`
+		Protected       bool                      `json:"password_protected"`
+		MaxViewers      uint                      `json:"max_viewers"`
 	}
 
 	var stream *service.SRSStreamInfo
@@ -219,14 +237,26 @@ func handleRoomInfoRetrival(c *gin.Context) {
 					}
 				}
 				return permissionItemAdminInfo{
-					UserName: username,
-					UserID:   item.SubjectUserID,
-					Label:    item.SubjectLabelName,
-					Type:     item.SubjectType,
+					UserName:  username,
+					UserID:    item.SubjectUserID,
+					Label:     item.SubjectLabelName,
+					Role:      item.SubjectRoleName,
+					Type:      item.SubjectType,
+					ExpiresAt: item.ExpiresAt,
+					Reason:    item.Reason,
+					CreatedBy: item.CreatedBy,
+					CreatedAt: item.CreatedAt,
 				}
 			}),
 			LastStreaming: room.LastStreamingAt,
 			Stream:        stream,
+			Description:   room.Description,
+			Category:      room.Category,
+			Tags:          lo.Map(room.Tags, func(tag models.RoomTag, _ int) string { return tag.Name }),
+			Visibility:    room.Visibility,
+			AccessPolicy:  room.AccessPolicy,
+			Protected:     room.Password != "",
+			MaxViewers:    room.MaxViewers,
 		},
 	})
 }
@@ -397,3 +427,97 @@ func handleRoomTimelineRetrival(c *gin.Context) {
 		"logs":    logs,
 	})
 }
+
+// handleRoomAccessPolicyDryRun tells whether the user would
+// be allowed under the policy given, or the saved one if
+// empty. An empty username checks for guests.
+func handleRoomAccessPolicyDryRun(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid id"))
+		return
+	}
+
+	data := struct {
+		Username string `json:"username"`
+		Policy   string `json:"policy"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	room, cerr := service.GetRoomByID(uint(id))
+	var user *models.User
+	if cerr == nil && data.Username != "" {
+		user, cerr = service.GetUserByUsername(data.Username)
+	}
+	var result *service.AccessDryRun
+	if cerr == nil {
+		result, cerr = service.DryRunAccessPolicy(room, user, data.Policy)
+	}
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when dry running room access policy")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":           0,
+		"message":        "ok",
+		"allowed":        result.Allowed,
+		"visible":        result.Visible,
+		"privileged":     result.Privileged,
+		"items_allowed":  result.ItemsAllowed,
+		"policy_allowed": result.PolicyAllowed,
+		"attributes": common.Response{
+			"guest":       result.Subject.Guest,
+			"labels":      result.Subject.Labels,
+			"role":        result.Subject.Role,
+			"account_age": int64(result.Subject.AccountAge.Seconds()),
+			"watch_time":  int64(result.Subject.WatchTime().Seconds()),
+		},
+	})
+}
+
+// handleRoomOwnerModification transfers the room without
+// asking the recipient.
+func handleRoomOwnerModification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid id"))
+		return
+	}
+
+	operator := service.GetUserFromContext(c)
+	room, cerr := service.ForceTransferRoom(uint(id), c.Param("username"), operator.ID)
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			if rerr.ID == cerrors.RequestRoomNotFound || rerr.ID == cerrors.RequestUserNotFound {
+				c.JSON(http.StatusNotFound, rerr.ToResponse())
+			} else {
+				c.JSON(http.StatusBadRequest, rerr.ToResponse())
+			}
+		} else {
+			logrus.WithError(cerr).Error("error when transferring room")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":     0,
+		"message":  "ok",
+		"room_id":  room.ID,
+		"owner_id": room.OwnerID,
+	})
+}
@@ -1 +1,35 @@
 package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	"github.com/sheey11/chocolate/middleware"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+)
+
+func mountSrsRoutes(r *gin.RouterGroup) {
+	g := r.Group("srs")
+	g.Use(middleware.AbilityRequired(models.Role{AbilityManageRoom: true}))
+	g.GET("/reconcile", handleReconcileReportRetrival)
+	g.POST("/reconcile", handleReconcile)
+}
+
+// report is null if rooms are never reconciled.
+func handleReconcileReportRetrival(c *gin.Context) {
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"report":  service.GetLastReconcileReport(),
+	})
+}
+
+func handleReconcile(c *gin.Context) {
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"report":  service.ReconcileRooms(),
+	})
+}
@@ -0,0 +1,117 @@
+package callbacks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// used if no networks are configured.
+var defaultAllowedNetworks = []string{
+	"127.0.0.1/32",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+}
+
+const signatureHeader = "X-Chocolate-Signature"
+
+// callbacks are small json objects.
+const maxCallbackBodySize = 64 * 1024
+
+type rejectReason string
+
+const (
+	rejectReasonNetwork   rejectReason = "network"
+	rejectReasonSecret    rejectReason = "secret"
+	rejectReasonSignature rejectReason = "signature"
+)
+
+var rejections = struct {
+	sync.Mutex
+	counts map[rejectReason]uint64
+}{counts: map[rejectReason]uint64{}}
+
+func rejectCallback(c *gin.Context, reason rejectReason) {
+	rejections.Lock()
+	rejections.counts[reason]++
+	rejections.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"ip":     c.ClientIP(),
+		"path":   c.Request.URL.Path,
+		"reason": reason,
+	}).Warn("srs callback rejected")
+}
+
+// GetRejectionStats reports how many callbacks are
+// rejected, by reason.
+func GetRejectionStats() map[string]uint64 {
+	rejections.Lock()
+	defer rejections.Unlock()
+	stats := map[string]uint64{
+		string(rejectReasonNetwork):   0,
+		string(rejectReasonSecret):    0,
+		string(rejectReasonSignature): 0,
+	}
+	for reason, count := range rejections.counts {
+		stats[string(reason)] = count
+	}
+	return stats
+}
+
+func signBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// callbackSecretRequired accepts callbacks carrying the
+// secret as `token` query, which is how srs is able to
+// authenticate, or whose body is signed with the secret
+// in the signature header, e.g. by a fronting proxy.
+// Nothing is checked if the secret is empty.
+func callbackSecretRequired(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if secret == "" {
+			return
+		}
+
+		if token := c.Query("token"); token != "" {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+				rejectCallback(c, rejectReasonSecret)
+				c.AbortWithStatus(http.StatusForbidden)
+			}
+			return
+		}
+
+		signature := strings.TrimSpace(c.GetHeader(signatureHeader))
+		if signature == "" {
+			rejectCallback(c, rejectReasonSecret)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxCallbackBodySize))
+		if err != nil {
+			rejectCallback(c, rejectReasonSignature)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		if !hmac.Equal([]byte(signature), []byte(signBody(secret, body))) {
+			rejectCallback(c, rejectReasonSignature)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+}
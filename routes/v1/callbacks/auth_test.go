@@ -0,0 +1,62 @@
+package callbacks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestEngine(secret string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(callbackSecretRequired(secret))
+	engine.POST("/publish", func(c *gin.Context) {
+		data := struct {
+			Action string `json:"action"`
+		}{}
+		if err := c.Bind(&data); err != nil || data.Action != "on_publish" {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func TestCallbackSecret(t *testing.T) {
+	body := `{"action":"on_publish"}`
+	cases := []struct {
+		name      string
+		secret    string
+		query     string
+		signature string
+		expected  int
+	}{
+		{"no secret configured", "", "", "", http.StatusOK},
+		{"token", "s3cret", "?token=s3cret", "", http.StatusOK},
+		{"wrong token", "s3cret", "?token=guess", "", http.StatusForbidden},
+		{"missing credential", "s3cret", "", "", http.StatusForbidden},
+		{"signature", "s3cret", "", signBody("s3cret", []byte(body)), http.StatusOK},
+		{"wrong signature", "s3cret", "", signBody("guess", []byte(body)), http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		request := httptest.NewRequest(http.MethodPost, "/publish"+tc.query, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		if tc.signature != "" {
+			request.Header.Set(signatureHeader, tc.signature)
+		}
+		recorder := httptest.NewRecorder()
+		newTestEngine(tc.secret).ServeHTTP(recorder, request)
+		if recorder.Code != tc.expected {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.expected, recorder.Code)
+		}
+	}
+
+	if stats := GetRejectionStats(); stats["secret"] != 2 || stats["signature"] != 1 {
+		t.Errorf("unexpected rejection stats %v", stats)
+	}
+}
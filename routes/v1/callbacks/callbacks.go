@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sheey11/chocolate/chat"
+	"github.com/sheey11/chocolate/common"
 	cerrors "github.com/sheey11/chocolate/errors"
 	"github.com/sheey11/chocolate/middleware"
 	"github.com/sheey11/chocolate/models"
@@ -16,14 +17,14 @@ import (
 )
 
 func mountCallbackRoutes(r *gin.RouterGroup) {
-	r.Use(middleware.IPRestriction(
-		[]string{
-			"127.0.0.1/32",
-			"192.168.1.1/16",
-			"172.16.0.0/12",
-			"10.1.1.1/8",
-		},
-	))
+	networks := common.Config.Callbacks.AllowedNetworks
+	if len(networks) == 0 {
+		networks = defaultAllowedNetworks
+	}
+	r.Use(middleware.IPRestrictionWithRejection(networks, func(c *gin.Context) {
+		rejectCallback(c, rejectReasonNetwork)
+	}))
+	r.Use(callbackSecretRequired(common.Config.Callbacks.Secret))
 	r.POST("/publish", handlePublish)
 	r.POST("/unpublish", handleUnpublish)
 	r.POST("/play", handlePlay)
@@ -97,13 +98,19 @@ func handlePublish(c *gin.Context) {
 		respondeErr(c)
 		return
 	}
+
+	if err := service.CheckIPBan(data.IP, room.ID); err != nil {
+		respondeErr(c)
+		logrus.WithField("room_id", roomId).WithField("ip", data.IP).Debug("room publish not permitted, reason: ip banned")
+		return
+	}
 	ok := service.CheckRoomStreamPermission(room, data.Params)
 	if !ok {
 		respondeErr(c)
 		return
 	}
 
-	err = service.RecordRoomClientID(uint(roomId), data.ClientID)
+	err = service.RecordRoomClientID(uint(roomId), data.ServerID, data.ClientID)
 	if err == nil {
 		chat.SendMessage(&models.ChatMessage{
 			Room:   *room,
@@ -112,6 +119,9 @@ func handlePublish(c *gin.Context) {
 		})
 		respondeOk(c)
 		service.RecordPublishEvent(uint(roomId), MarshalJSON(data))
+		service.StartBroadcast(uint(roomId))
+		go service.StartRoomForwarding(uint(roomId))
+		go service.CheckRoomPolicy(uint(roomId))
 		return
 	} else {
 		respondeErr(c)
@@ -151,6 +161,8 @@ func handleUnpublish(c *gin.Context) {
 	}
 
 	respondeOk(c)
+	service.StopRoomForwarding(uint(roomId))
+	service.EndBroadcast(uint(roomId), models.BroadcastEndReasonUnpublish, "")
 	go service.RecordUnpublishEvent(uint(roomId), MarshalJSON(data))
 }
 func handlePlay(c *gin.Context) {
@@ -178,31 +190,58 @@ func handlePlay(c *gin.Context) {
 		return
 	}
 
+	queries, ok := parseCallbackParams(data.Params)
+	// forward relays are not viewers.
+	if service.IsForwardRelay(queries, uint(roomId)) {
+		respondeOk(c)
+		return
+	}
+
+	// players may reach srs directly, skipping the
+	// playback endpoints.
+	if cerr := service.CheckIPBan(data.IP, uint(roomId)); cerr != nil {
+		respondeErr(c)
+		return
+	}
+
 	// FIXME: use chat ping-pong heartbeat message to
 	// count active viewers instead.
-	cerr := service.DecreaseRoomViewer(uint(roomId))
+	cerr := service.IncreaseRoomViewer(uint(roomId))
 	if cerr != nil {
-		logrus.WithError(cerr).Error("failed to decrease room viewer")
+		logrus.WithError(cerr).Error("failed to increase room viewer")
 	}
 
 	respondeOk(c)
-	if data.Params != "" {
-		queries, err := url.ParseQuery(data.Params[1:])
-		if err != nil {
-			logrus.WithError(err).Error("failed to parse flv playback params, stacktrace:\n%s", cerrors.GetStackTrace())
-			return
-		}
-
-		uid, err := strconv.Atoi(queries.Get("u"))
-		if err != nil || uid <= 0 {
-			return
-		}
-		session := queries.Get("s")
-
-		go service.RecordPlayEvent(uint(uid), uint(roomId), data.ClientID, session)
-	} else {
+	if data.Params == "" {
 		logrus.WithField("client_id", data.ClientID).Warn("there's a client carries no param trying to play flv")
+		return
 	}
+	if !ok {
+		return
+	}
+
+	uid, err := strconv.Atoi(queries.Get("u"))
+	if err != nil || uid <= 0 {
+		return
+	}
+	session := queries.Get("s")
+
+	go service.RecordPlayEvent(uint(uid), uint(roomId), data.ClientID, session)
+}
+
+// parseCallbackParams parses the `?a=b` params srs
+// passes along, false if there are none or they are
+// malformed.
+func parseCallbackParams(params string) (url.Values, bool) {
+	if params == "" {
+		return url.Values{}, false
+	}
+	queries, err := url.ParseQuery(params[1:])
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to parse flv playback params, stacktrace:\n%s", cerrors.GetStackTrace())
+		return url.Values{}, false
+	}
+	return queries, true
 }
 func handleStop(c *gin.Context) {
 	data := struct {
@@ -228,28 +267,33 @@ func handleStop(c *gin.Context) {
 		return
 	}
 
+	queries, ok := parseCallbackParams(data.Params)
+	if service.IsForwardRelay(queries, uint(roomId)) {
+		respondeOk(c)
+		return
+	}
+
 	// FIXME: use chat ping-pong heartbeat message to
 	// count active viewers instead.
-	cerr := service.IncreaseRoomViewer(uint(roomId))
+	cerr := service.DecreaseRoomViewer(uint(roomId))
 	if cerr != nil {
-		logrus.WithError(cerr).Error("failed to increase room viewer")
+		logrus.WithError(cerr).Error("failed to decrease room viewer")
 	}
 	// TODO
 
 	respondeOk(c)
-	if data.Params != "" {
-		queries, err := url.ParseQuery(data.Params[1:])
-		if err != nil {
-			logrus.WithError(err).Error("failed to parse flv playback params, stacktrace:\n%s", cerrors.GetStackTrace())
-			return
-		}
-
-		uid, err := strconv.Atoi(queries.Get("u"))
-		if err != nil || uid <= 0 {
-			return
-		}
-		session := queries.Get("s")
+	if !ok {
+		return
+	}
+	session := queries.Get("s")
+	// whep sessions count against the playback caps until
+	// they stop.
+	service.EndRtcPlaybackSession(session)
 
-		go service.RecordStopPlayEvent(uint(uid), uint(roomId), session)
+	uid, err := strconv.Atoi(queries.Get("u"))
+	if err != nil || uid <= 0 {
+		return
 	}
+
+	go service.RecordStopPlayEvent(uint(uid), uint(roomId), session)
 }
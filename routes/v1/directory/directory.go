@@ -0,0 +1,147 @@
+package directory
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountDirectoryRoutes(r *gin.RouterGroup) {
+	r.GET("/rooms", handleDirectoryRoomList)
+	r.GET("/categories", handleCategoryList)
+	r.GET("/tags", handlePopularTagList)
+}
+
+type directoryRoomInfo struct {
+	ID            uint             `json:"id"`
+	Title         string           `json:"title"`
+	Description   string           `json:"description"`
+	Status        string           `json:"status"`
+	Viewers       uint             `json:"viewers"`
+	OwnerUsername string           `json:"owner_username"`
+	Category      *models.Category `json:"category"`
+	Tags          []string         `json:"tags"`
+	LastStreaming time.Time        `json:"last_streaming"`
+}
+
+func handleDirectoryRoomList(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid limit"))
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid page"))
+		return
+	}
+
+	filter := models.DirectoryFilter{
+		Query: c.Query("q"),
+		Sort:  models.DirectorySort(c.Query("sort")),
+	}
+	switch filter.Sort {
+	case "", models.DirectorySortViewers, models.DirectorySortRecent, models.DirectorySortRelevance:
+	default:
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "sort should be viewers, recent or relevance"))
+		return
+	}
+	if category := c.Query("category"); category != "" {
+		id, err := strconv.Atoi(category)
+		if err != nil || id < 0 {
+			c.Abort()
+			c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid category"))
+			return
+		}
+		filter.CategoryID = lo.ToPtr(uint(id))
+	}
+	if tag := c.Query("tag"); tag != "" {
+		filter.Tag = &tag
+	}
+	switch c.Query("status") {
+	case "":
+	case "streaming":
+		filter.Status = lo.ToPtr(models.RoomStatusStreaming)
+	case "idle":
+		filter.Status = lo.ToPtr(models.RoomStatusIdle)
+	default:
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "status should be streaming or idle"))
+		return
+	}
+
+	total, rooms, cerr := service.ListDirectoryRooms(&filter, uint(limit), uint(page))
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when listing directory rooms")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"total":   total,
+		"rooms": lo.Map(rooms, func(room *models.Room, _ int) directoryRoomInfo {
+			return directoryRoomInfo{
+				ID:            room.ID,
+				Title:         room.Title,
+				Description:   room.Description,
+				Status:        room.Status.ToString(),
+				Viewers:       room.Viewers,
+				OwnerUsername: room.Owner.Username,
+				Category:      room.Category,
+				Tags:          lo.Map(room.Tags, func(tag models.RoomTag, _ int) string { return tag.Name }),
+				LastStreaming: room.LastStreamingAt,
+			}
+		}),
+	})
+}
+
+func handleCategoryList(c *gin.Context) {
+	categories, err := service.ListCategories()
+	if err != nil {
+		logrus.WithError(err).Error("error when listing categories")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, err.ToResponse())
+		return
+	}
+	c.JSON(http.StatusOK, common.Response{
+		"code":       0,
+		"message":    "ok",
+		"categories": categories,
+	})
+}
+
+func handlePopularTagList(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid limit"))
+		return
+	}
+
+	tags, cerr := service.ListPopularTags(uint(limit))
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when listing popular tags")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"tags":    tags,
+	})
+}
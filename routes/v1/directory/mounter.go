@@ -0,0 +1,8 @@
+package directory
+
+import "github.com/gin-gonic/gin"
+
+func Mount(r *gin.RouterGroup) {
+	directory := r.Group("/directory")
+	mountDirectoryRoutes(directory)
+}
@@ -0,0 +1,75 @@
+package invites
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountInvitesRoutes(r *gin.RouterGroup) {
+	r.GET("/:token", handleInviteLookup)
+	r.POST("/:token/redeem", handleInviteRedemption)
+}
+
+func respondInviteError(c *gin.Context, err cerrors.ChocolateError) {
+	c.Abort()
+	if rerr, ok := err.(cerrors.RequestError); ok {
+		switch rerr.ID {
+		case cerrors.RequestRoomInviteNotFound, cerrors.RequestRoomNotFound:
+			c.JSON(http.StatusNotFound, rerr.ToResponse())
+		case cerrors.RequestNotLoggedIn:
+			c.JSON(http.StatusUnauthorized, rerr.ToResponse())
+		default:
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		}
+	} else {
+		logrus.WithError(err).Error("error when handling invite")
+		c.JSON(http.StatusInternalServerError, err.ToResponse())
+	}
+}
+
+func handleInviteLookup(c *gin.Context) {
+	invite, room, err := service.LookupInvite(c.Param("token"), service.TryGetUserFromContext(c))
+	if err != nil {
+		respondInviteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":            0,
+		"message":         "ok",
+		"room_id":         room.ID,
+		"room_title":      room.Title,
+		"expires_at":      invite.ExpiresAt,
+		"access_duration": invite.AccessDuration,
+		"allow_guests":    invite.AllowGuests,
+		"usable":          invite.Usable(time.Now()),
+	})
+}
+
+// handleInviteRedemption redeems for the signed in user,
+// or issues a guest token for visitors, which should be
+// passed as the `guest` query of playback urls.
+func handleInviteRedemption(c *gin.Context) {
+	room, until, guestToken, err := service.RedeemInvite(c.Param("token"), service.TryGetUserFromContext(c))
+	if err != nil {
+		respondInviteError(c, err)
+		return
+	}
+
+	response := common.Response{
+		"code":         0,
+		"message":      "ok",
+		"room_id":      room.ID,
+		"access_until": until,
+	}
+	if guestToken != "" {
+		response["guest_token"] = guestToken
+	}
+	c.JSON(http.StatusOK, response)
+}
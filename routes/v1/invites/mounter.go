@@ -0,0 +1,8 @@
+package invites
+
+import "github.com/gin-gonic/gin"
+
+func Mount(r *gin.RouterGroup) {
+	invites := r.Group("/invites")
+	mountInvitesRoutes(invites)
+}
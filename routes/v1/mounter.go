@@ -5,8 +5,11 @@ import (
 	"github.com/sheey11/chocolate/routes/v1/admin"
 	"github.com/sheey11/chocolate/routes/v1/auth"
 	"github.com/sheey11/chocolate/routes/v1/callbacks"
+	"github.com/sheey11/chocolate/routes/v1/directory"
+	"github.com/sheey11/chocolate/routes/v1/invites"
 	"github.com/sheey11/chocolate/routes/v1/playback"
 	"github.com/sheey11/chocolate/routes/v1/rooms"
+	"github.com/sheey11/chocolate/routes/v1/schedules"
 	"github.com/sheey11/chocolate/routes/v1/stats"
 	"github.com/sheey11/chocolate/routes/v1/user"
 )
@@ -20,4 +23,7 @@ func Mount(engine *gin.Engine) {
 	user.Mount(g)
 	callbacks.Mount(g)
 	playback.Mount(g)
+	schedules.Mount(g)
+	directory.Mount(g)
+	invites.Mount(g)
 }
@@ -0,0 +1,211 @@
+package playback
+
+import (
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sheey11/chocolate/common"
+	"github.com/sheey11/chocolate/srs"
+)
+
+func init() {
+	common.HookPostConfigLoad(func(cfg *common.ChocolateConfig) {
+		if cfg.Playback.Cache.BudgetMB != 0 {
+			hlsCache.budget = int64(cfg.Playback.Cache.BudgetMB) << 20
+		}
+		if cfg.Playback.Cache.PlaylistTTL != 0 {
+			playlistTTL = time.Millisecond * time.Duration(cfg.Playback.Cache.PlaylistTTL)
+		}
+		if cfg.Playback.Cache.SegmentTTL != 0 {
+			segmentTTL = time.Second * time.Duration(cfg.Playback.Cache.SegmentTTL)
+		}
+	})
+}
+
+var (
+	// playlists change every segment duration, a short ttl
+	// is enough to collapse the polling of all viewers.
+	playlistTTL = time.Second
+	// segments never change once listed.
+	segmentTTL = time.Minute
+)
+
+var hlsCache = newResponseCache(256 << 20)
+
+type cachedResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+type cacheEntry struct {
+	key      string
+	response *cachedResponse
+	expire   time.Time
+}
+
+type inflightCall struct {
+	done     chan struct{}
+	response *cachedResponse
+	err      error
+}
+
+// responseCache is a lru cache evicting by total body
+// size, concurrent misses of the same key share one
+// fetch.
+type responseCache struct {
+	mu       sync.Mutex
+	budget   int64
+	size     int64
+	entries  map[string]*list.Element
+	lru      *list.List
+	inflight map[string]*inflightCall
+
+	hits      uint64
+	misses    uint64
+	coalesced uint64
+	evictions uint64
+}
+
+func newResponseCache(budget int64) *responseCache {
+	return &responseCache{
+		budget:   budget,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		inflight: map[string]*inflightCall{},
+	}
+}
+
+// Get returns the cached response of key, or calls fetch
+// to get one. Only 200 responses are cached.
+func (c *responseCache) Get(key string, ttl time.Duration, fetch func() (*cachedResponse, error)) (*cachedResponse, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if time.Now().Before(entry.expire) {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			atomic.AddUint64(&c.hits, 1)
+			return entry.response, nil
+		}
+		c.removeElement(elem)
+	}
+
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		atomic.AddUint64(&c.coalesced, 1)
+		<-call.done
+		return call.response, call.err
+	}
+
+	call := &inflightCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+	atomic.AddUint64(&c.misses, 1)
+
+	call.response, call.err = fetch()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil && call.response.StatusCode == http.StatusOK {
+		c.add(key, call.response, ttl)
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.response, call.err
+}
+
+// must be called with lock held.
+func (c *responseCache) add(key string, response *cachedResponse, ttl time.Duration) {
+	size := int64(len(response.Body))
+	// a single huge response should not flush the cache.
+	if size > c.budget/8 {
+		return
+	}
+
+	elem := c.lru.PushFront(&cacheEntry{
+		key:      key,
+		response: response,
+		expire:   time.Now().Add(ttl),
+	})
+	c.entries[key] = elem
+	c.size += size
+
+	for c.size > c.budget {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeElement(oldest)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// must be called with lock held.
+func (c *responseCache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.response.Body))
+}
+
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Coalesced uint64 `json:"coalesced"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	Budget    int64  `json:"budget"`
+}
+
+func (c *responseCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Coalesced: atomic.LoadUint64(&c.coalesced),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Entries:   len(c.entries),
+		Bytes:     c.size,
+		Budget:    c.budget,
+	}
+}
+
+// GetCacheStats reports the hls cache metrics of
+// this chocolate instance.
+func GetCacheStats() CacheStats {
+	return hlsCache.Stats()
+}
+
+var srsClient = http.Client{
+	Timeout: 5 * time.Second,
+}
+
+func fetchFromSrs(server srs.Server, path string, rawQuery string) (*cachedResponse, error) {
+	u := server.HttpUrl(path)
+	if rawQuery != "" {
+		u += "?" + rawQuery
+	}
+	response, err := srsClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading srs response of %s: %w", path, err)
+	}
+	return &cachedResponse{
+		StatusCode:  response.StatusCode,
+		ContentType: response.Header.Get("Content-Type"),
+		Body:        body,
+	}, nil
+}
@@ -0,0 +1,60 @@
+package playback
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheCoalescing(t *testing.T) {
+	cache := newResponseCache(1 << 20)
+	var fetches int32
+	release := make(chan struct{})
+	fetch := func() (*cachedResponse, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return &cachedResponse{StatusCode: http.StatusOK, Body: []byte("segment")}, nil
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Get("/live/1-1.ts", time.Minute, fetch)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	cache.Get("/live/1-1.ts", time.Minute, fetch)
+	if fetches != 1 {
+		t.Fatalf("expect 1 fetch, got %d", fetches)
+	}
+	stats := cache.Stats()
+	if stats.Misses != 1 || stats.Hits+stats.Coalesced != 10 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	cache := newResponseCache(80)
+	body := make([]byte, 10)
+	fetch := func() (*cachedResponse, error) {
+		return &cachedResponse{StatusCode: http.StatusOK, Body: body}, nil
+	}
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"} {
+		cache.Get(key, time.Minute, fetch)
+	}
+
+	stats := cache.Stats()
+	if stats.Bytes > 80 || stats.Evictions != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if _, ok := cache.entries["a"]; ok {
+		t.Fatalf("the least recently used entry should be evicted")
+	}
+}
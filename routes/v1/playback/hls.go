@@ -0,0 +1,60 @@
+package playback
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var uriAttributeRegexp = regexp.MustCompile(`URI="([^"]*)"`)
+
+// rewritePlaylist replaces every uri in the m3u8
+// playlist, including `URI` attributes of tags such
+// as `#EXT-X-KEY`, with the result of `rewrite`.
+func rewritePlaylist(playlist []byte, rewrite func(uri string) string) []byte {
+	result := bytes.Buffer{}
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			line = uriAttributeRegexp.ReplaceAllStringFunc(line, func(attr string) string {
+				uri := uriAttributeRegexp.FindStringSubmatch(attr)[1]
+				return `URI="` + rewrite(uri) + `"`
+			})
+		default:
+			line = rewrite(line)
+		}
+		result.WriteString(line)
+		result.WriteByte('\n')
+	}
+	return result.Bytes()
+}
+
+// playbackUri points segments to the chocolate segment
+// endpoint and variant playlists back to the playlist
+// endpoint, uris are relative to `/playback/:room/hls`.
+// Variants carry `credentials` the playlist is fetched
+// with, segments carry the token instead.
+func playbackUri(uri string, token string, credentials url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	// srs may respond a master playlist to track hls
+	// sessions, the variant carries `hls_ctx` query.
+	if strings.HasSuffix(u.Path, ".m3u8") {
+		query := u.Query()
+		for key, values := range credentials {
+			query[key] = values
+		}
+		return "hls?" + query.Encode()
+	}
+	query := u.Query()
+	query.Set("token", token)
+	return "hls/" + url.PathEscape(path.Base(u.Path)) + "?" + query.Encode()
+}
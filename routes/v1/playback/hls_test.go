@@ -0,0 +1,52 @@
+package playback
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRewritePlaylist(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:12
+#EXT-X-TARGETDURATION:10
+#EXTINF:10.000, no desc
+1-12.ts?hls_ctx=abc
+#EXT-X-KEY:METHOD=AES-128,URI="/live/1-12.key"
+#EXTINF:10.000, no desc
+/live/1-13.ts
+`
+	result := string(rewritePlaylist([]byte(playlist), func(uri string) string {
+		return playbackUri(uri, "tkn", nil)
+	}))
+
+	expects := []string{
+		"#EXT-X-MEDIA-SEQUENCE:12\n",
+		"\nhls/1-12.ts?hls_ctx=abc&token=tkn\n",
+		`URI="hls/1-12.key?token=tkn"`,
+		"\nhls/1-13.ts?token=tkn\n",
+	}
+	for _, expect := range expects {
+		if !strings.Contains(result, expect) {
+			t.Fatalf("rewritten playlist should contain %q, got:\n%s", expect, result)
+		}
+	}
+}
+
+func TestRewriteMasterPlaylist(t *testing.T) {
+	playlist := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1,AVERAGE-BANDWIDTH=1\n/live/1.m3u8?hls_ctx=abc\n"
+	result := string(rewritePlaylist([]byte(playlist), func(uri string) string {
+		return playbackUri(uri, "tkn", nil)
+	}))
+	if !strings.Contains(result, "\nhls?hls_ctx=abc\n") {
+		t.Fatalf("variant playlist should point to playlist endpoint, got:\n%s", result)
+	}
+
+	result = string(rewritePlaylist([]byte(playlist), func(uri string) string {
+		return playbackUri(uri, "tkn", url.Values{"ticket": {"tck"}})
+	}))
+	if !strings.Contains(result, "\nhls?hls_ctx=abc&ticket=tck\n") {
+		t.Fatalf("variant playlist should carry the ticket, got:\n%s", result)
+	}
+}
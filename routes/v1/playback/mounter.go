@@ -5,4 +5,5 @@ import "github.com/gin-gonic/gin"
 func Mount(r *gin.RouterGroup) {
 	r = r.Group("/playback")
 	mountPlaybackRoutes(r)
+	mountRtcRoutes(r)
 }
@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,11 +16,13 @@ import (
 	"github.com/sheey11/chocolate/errors"
 	"github.com/sheey11/chocolate/models"
 	"github.com/sheey11/chocolate/service"
+	"github.com/sheey11/chocolate/srs"
 	"github.com/sirupsen/logrus"
 )
 
 func mountPlaybackRoutes(r *gin.RouterGroup) {
 	r.GET("/:room/hls", handleHlsPlayback)
+	r.GET("/:room/hls/:segment", handleHlsSegment)
 	r.GET("/:room/flv", handleFlvPlayback)
 }
 
@@ -50,50 +54,127 @@ func handleHlsPlayback(c *gin.Context) {
 		return
 	}
 
-	user := service.TryGetUserFromContext(c)
-	allowed := service.IsUserAllowedForRoom(room, user)
+	if cerr := service.CheckIPBan(c.ClientIP(), room.ID); cerr != nil {
+		respondPlaybackRefusal(c, cerr)
+		return
+	}
+
+	user, ok := service.GetPlaybackViewer(c, room)
+	if !ok {
+		c.Abort()
+		c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestPlaybackTokenInvalid, "invalid or expired playback ticket"))
+		return
+	}
+	access := service.GetRoomAccessTicket(c, room.ID)
+	allowed := service.IsViewerAllowedForRoom(room, user, access) || service.VerifyGuestPlaybackToken(c.Query("guest"), room)
 	if !allowed {
 		c.Abort()
-		c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomBanned, "you have been banned from watching this stream or login required"))
+		if service.RoomNeedsPassword(room, access) {
+			c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomPasswordRequired, "password required"))
+		} else {
+			c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomBanned, "you have been banned from watching this stream or login required"))
+		}
+		return
+	}
+
+	// keys are prefixed by node, streams of the same
+	// room on different nodes are different.
+	node := lo.FromPtr(room.SrsNode)
+	path := fmt.Sprintf("/live/%d.m3u8", id)
+	query := c.Request.URL.Query()
+	credentials := url.Values{}
+	for _, key := range []string{"ticket", "guest", "access"} {
+		if query.Has(key) {
+			credentials.Set(key, query.Get(key))
+			query.Del(key)
+		}
+	}
+	// `hls_ctx` is made up by the client, sessions are
+	// told apart by the playback ticket, or the viewer and
+	// address, so reloading the player takes the same one.
+	uid := lo.If(user == nil, uint(0)).ElseF(func() uint { return user.ID })
+	key := fmt.Sprintf("hls/%d/%d/%s", room.ID, uid, c.ClientIP())
+	if ticket := credentials.Get("ticket"); ticket != "" {
+		key = fmt.Sprintf("hls/%d/%d/t/%s", room.ID, uid, ticket)
+	}
+	if cerr = service.StartPlaybackSession(key, room, user, service.HlsPlaybackSessionTTL); cerr != nil {
+		respondPlaybackRefusal(c, cerr)
 		return
 	}
 
-	remote, err := url.Parse("http://srs:8080")
+	rawQuery := query.Encode()
+	response, err := hlsCache.Get(node+path+"?"+rawQuery, playlistTTL, func() (*cachedResponse, error) {
+		return fetchFromSrs(service.GetRoomSrsServer(room), path, rawQuery)
+	})
 	if err != nil {
-		logrus.WithError(err).Error("error handling reverse proxy of hls playback")
-		c.JSON(http.StatusInternalServerError, common.SampleResponse(errors.RequestInternalServerError, "internal server error"))
+		logrus.WithError(err).Error("error fetching hls playlist")
+		c.Abort()
+		c.Status(http.StatusBadGateway)
+		return
+	} else if response.StatusCode != http.StatusOK {
+		c.Abort()
+		c.Status(http.StatusNotFound)
+		return
 	}
 
-	func() {
-		defer func() {
-			// the most common error is that client closes the connection,
-			// and server is still tring to write the socket, that is the
-			// `net/http: abort Handler` error.
-			recover()
-		}()
+	token := service.CreateSegmentToken(room, user)
+	playlist := rewritePlaylist(response.Body, func(uri string) string {
+		return playbackUri(uri, token, credentials)
+	})
 
-		proxy := httputil.NewSingleHostReverseProxy(remote)
-		proxy.ModifyResponse = func(r *http.Response) error {
-			r.Header.Del("Server")
-			r.Header.Del("Access-Control-Allow-Headers")
-			r.Header.Del("Access-Control-Allow-Methods")
-			r.Header.Del("Access-Control-Allow-Origin")
-			r.Header.Del("Access-Control-Expose-Headers")
-			return nil
-		}
-		proxy.Director = func(r *http.Request) {
-			r.Header = c.Request.Header
-			r.Host = remote.Host
-			r.URL.Scheme = remote.Scheme
-			r.URL.Host = remote.Host
-			r.URL.Path = fmt.Sprintf("/live/%d.m3u8", id)
-		}
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			logrus.WithError(err).Error("error reverse proxying flv")
-		}
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", playlist)
+}
 
-		proxy.ServeHTTP(c.Writer, c.Request)
-	}()
+var segmentNameRegexp = regexp.MustCompile(`^[0-9A-Za-z_\-]+\.(ts|m4s|mp4|aac)$`)
+
+// handleHlsSegment serves segments listed in the
+// playlists, the token carried in the query is the
+// only credential checked here, it is issued when
+// the viewer fetches the playlist.
+func handleHlsSegment(c *gin.Context) {
+	idStr := c.Param("room")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 0 {
+		c.Abort()
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	// srs names segments as `[stream]-[seq].ts`, checking
+	// the prefix prevents fetching segments of other rooms.
+	segment := c.Param("segment")
+	if !segmentNameRegexp.MatchString(segment) || !strings.HasPrefix(segment, fmt.Sprintf("%d-", id)) {
+		c.Abort()
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	token, ok := service.VerifySegmentToken(c.Query("token"), uint(id))
+	if !ok {
+		c.Abort()
+		c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestPlaybackTokenInvalid, "invalid or expired playback token"))
+		return
+	}
+
+	// srs tracks hls sessions by `hls_ctx`, which differs
+	// between viewers, but the segment itself is the same.
+	query := c.Request.URL.Query()
+	query.Del("token")
+	var node *string
+	if token.Node != "" {
+		node = &token.Node
+	}
+	response, err := hlsCache.Get(token.Node+"/live/"+segment, segmentTTL, func() (*cachedResponse, error) {
+		return fetchFromSrs(srs.ServerOf(node), "/live/"+segment, query.Encode())
+	})
+	if err != nil {
+		logrus.WithError(err).Error("error fetching hls segment")
+		c.Abort()
+		c.Status(http.StatusBadGateway)
+		return
+	}
+	c.Data(response.StatusCode, response.ContentType, response.Body)
 }
 
 func handleFlvPlayback(c *gin.Context) {
@@ -124,20 +205,42 @@ func handleFlvPlayback(c *gin.Context) {
 		return
 	}
 
-	remote, err := url.Parse("http://srs:8080")
+	remote, err := url.Parse(service.GetRoomSrsServer(room).HttpUrl("/"))
 	if err != nil {
 		logrus.WithError(err).Error("error handling reverse proxy of flv playback")
 		c.JSON(http.StatusInternalServerError, common.SampleResponse(errors.RequestInternalServerError, "internal server error"))
 		return
 	}
 
-	user := service.GetUserFromCookie(c)
-	allowed := service.IsUserAllowedForRoom(room, user)
+	if cerr := service.CheckIPBan(c.ClientIP(), room.ID); cerr != nil {
+		respondPlaybackRefusal(c, cerr)
+		return
+	}
+
+	user, ok := service.GetPlaybackViewer(c, room)
+	if !ok {
+		c.Abort()
+		c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestPlaybackTokenInvalid, "invalid or expired playback ticket"))
+		return
+	}
+	access := service.GetRoomAccessTicket(c, room.ID)
+	allowed := service.IsViewerAllowedForRoom(room, user, access) || service.VerifyGuestPlaybackToken(c.Query("guest"), room)
 	if !allowed {
 		c.Abort()
-		c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomBanned, "you have been banned from watching this stream or login required"))
+		if service.RoomNeedsPassword(room, access) {
+			c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomPasswordRequired, "password required"))
+		} else {
+			c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomBanned, "you have been banned from watching this stream or login required"))
+		}
+		return
+	}
+
+	session := uuid.New().String()
+	if cerr := service.StartPlaybackSession("flv/"+session, room, user, 0); cerr != nil {
+		respondPlaybackRefusal(c, cerr)
 		return
 	}
+	defer service.EndPlaybackSession("flv/" + session)
 
 	func() {
 		defer func() {
@@ -164,7 +267,7 @@ func handleFlvPlayback(c *gin.Context) {
 			r.URL.Path = fmt.Sprintf("/live/%d.flv", id)
 
 			uid := lo.If(user == nil, 0).ElseF(func() int { return int(user.ID) })
-			r.URL.RawQuery = fmt.Sprintf("u=%d&s=%s", uid, uuid.New().String())
+			r.URL.RawQuery = fmt.Sprintf("u=%d&s=%s", uid, session)
 		}
 		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 			logrus.WithError(err).Error("error reverse proxying flv")
@@ -173,3 +276,13 @@ func handleFlvPlayback(c *gin.Context) {
 		proxy.ServeHTTP(c.Writer, c.Request)
 	}()
 }
+
+func respondPlaybackRefusal(c *gin.Context, cerr errors.ChocolateError) {
+	c.Abort()
+	if rerr, ok := cerr.(errors.RequestError); ok {
+		c.JSON(http.StatusForbidden, rerr.ToResponse())
+	} else {
+		logrus.WithError(cerr).Error("error checking playback refusal")
+		c.Status(http.StatusInternalServerError)
+	}
+}
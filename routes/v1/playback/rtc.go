@@ -0,0 +1,219 @@
+package playback
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	"github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sheey11/chocolate/srs"
+	"github.com/sirupsen/logrus"
+)
+
+// sdp offers are usually several kilobytes.
+const maxSdpOfferSize = 64 * 1024
+
+func mountRtcRoutes(r *gin.RouterGroup) {
+	r.POST("/:room/whep", handleWhepPlayback)
+	r.DELETE("/:room/whep", handleWhepTeardown)
+	r.POST("/:room/whip", handleWhipPublish)
+	r.DELETE("/:room/whip", handleWhipTeardown)
+}
+
+func getRoomFromParam(c *gin.Context) *models.Room {
+	id, err := strconv.Atoi(c.Param("room"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.Status(http.StatusBadRequest)
+		return nil
+	}
+
+	room, cerr := service.GetRoomByID(uint(id))
+	if cerr != nil {
+		if rerr, ok := cerr.(errors.RequestError); ok {
+			c.Abort()
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when looking up room for rtc")
+			c.Abort()
+			c.Status(http.StatusInternalServerError)
+		}
+		return nil
+	}
+	return room
+}
+
+func readSdpOffer(c *gin.Context) []byte {
+	if !strings.HasPrefix(c.ContentType(), "application/sdp") {
+		c.Abort()
+		c.Status(http.StatusUnsupportedMediaType)
+		return nil
+	}
+	offer, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSdpOfferSize))
+	if err != nil || len(offer) == 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidRequestData, "bad sdp offer"))
+		return nil
+	}
+	return offer
+}
+
+// the session resource is exposed as a relative url, so
+// it resolves to the very endpoint the offer was posted
+// to, whatever prefix the reverse proxy adds.
+func writeRtcAnswer(c *gin.Context, kind srs.RtcKind, answer *srs.RtcAnswer) {
+	if answer.StatusCode == http.StatusCreated && answer.ResourceQuery != "" {
+		c.Header("Location", string(kind)+"?"+answer.ResourceQuery)
+	}
+	contentType := answer.ContentType
+	if contentType == "" {
+		contentType = "application/sdp"
+	}
+	c.Data(answer.StatusCode, contentType, answer.Body)
+}
+
+func respondRtcTeardown(c *gin.Context, status int, err errors.ChocolateError) {
+	if err != nil {
+		c.Abort()
+		if rerr, ok := err.(errors.RequestError); ok {
+			c.JSON(http.StatusForbidden, rerr.ToResponse())
+		} else {
+			logrus.WithError(err).Error("error when tearing down rtc session")
+			c.Status(http.StatusBadGateway)
+		}
+		return
+	}
+	c.Status(status)
+}
+
+// getBearerKey reads the push key given as a bearer
+// token, as suggested by the WHIP specification.
+func getBearerKey(c *gin.Context) (string, bool) {
+	auth := c.GetHeader("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		c.Abort()
+		c.JSON(http.StatusUnauthorized, common.SampleResponse(errors.RequestNotLoggedIn, "stream key required"))
+		return "", false
+	}
+	return strings.TrimSpace(auth[7:]), true
+}
+
+func handleWhepPlayback(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	if room.Status != models.RoomStatusStreaming {
+		c.Abort()
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if cerr := service.CheckIPBan(c.ClientIP(), room.ID); cerr != nil {
+		respondPlaybackRefusal(c, cerr)
+		return
+	}
+
+	user := service.TryGetUserFromContext(c)
+	allowed := service.IsViewerAllowedForRoom(room, user, service.GetRoomAccessTicket(c, room.ID))
+	if !allowed {
+		c.Abort()
+		c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomBanned, "you have been banned from watching this stream or login required"))
+		return
+	}
+	// checked before reading the offer, the session is
+	// counted when exchanging.
+	if cerr := service.CheckPlaybackCaps(room, user); cerr != nil {
+		respondPlaybackRefusal(c, cerr)
+		return
+	}
+
+	offer := readSdpOffer(c)
+	if offer == nil {
+		return
+	}
+
+	answer, err := service.ExchangeRoomPlaySdp(room, user, offer)
+	if err != nil {
+		if _, ok := err.(errors.RequestError); ok {
+			respondPlaybackRefusal(c, err)
+			return
+		}
+		logrus.WithError(err).Error("error when handling whep playback")
+		c.Abort()
+		c.Status(http.StatusBadGateway)
+		return
+	}
+	writeRtcAnswer(c, srs.RtcKindWhep, answer)
+}
+
+// the session resource is signed when created, and the
+// viewer is checked again as when playing.
+func handleWhepTeardown(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	user := service.TryGetUserFromContext(c)
+	if !service.IsViewerAllowedForRoom(room, user, service.GetRoomAccessTicket(c, room.ID)) {
+		c.Abort()
+		c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomBanned, "you have been banned from watching this stream or login required"))
+		return
+	}
+
+	status, err := service.TeardownRoomPlaySession(room, c.Request.URL.Query())
+	respondRtcTeardown(c, status, err)
+}
+
+func handleWhipPublish(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	key, ok := getBearerKey(c)
+	if !ok {
+		return
+	}
+
+	offer := readSdpOffer(c)
+	if offer == nil {
+		return
+	}
+
+	answer, err := service.ExchangeRoomPublishSdp(room, key, offer)
+	if err != nil {
+		if rerr, ok := err.(errors.RequestError); ok {
+			c.Abort()
+			c.JSON(http.StatusForbidden, rerr.ToResponse())
+		} else {
+			logrus.WithError(err).Error("error when handling whip publish")
+			c.Abort()
+			c.Status(http.StatusBadGateway)
+		}
+		return
+	}
+	writeRtcAnswer(c, srs.RtcKindWhip, answer)
+}
+
+func handleWhipTeardown(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	key, ok := getBearerKey(c)
+	if !ok {
+		return
+	}
+
+	status, err := service.TeardownRoomPublishSession(room, key, c.Request.URL.Query())
+	respondRtcTeardown(c, status, err)
+}
@@ -0,0 +1,90 @@
+package rooms
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountBroadcastRoutes(r *gin.RouterGroup) {
+	r = r.Group("", requireRoomAbility(models.RoomAbilities{AbilityViewAnalytics: true}))
+	r.GET("/:id/broadcasts", handleBroadcastList)
+	r.GET("/:id/broadcasts/:bid", handleBroadcastInfo)
+}
+
+func handleBroadcastList(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid limit"))
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid page"))
+		return
+	}
+
+	total, broadcasts, cerr := service.ListRoomBroadcasts(uint(id), uint(limit), uint(page))
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when listing broadcasts")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":       0,
+		"message":    "ok",
+		"total":      total,
+		"broadcasts": lo.Map(broadcasts, func(b *models.Broadcast, _ int) service.BroadcastInfo { return service.NewBroadcastInfo(b) }),
+	})
+}
+
+func handleBroadcastInfo(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	bid, err := strconv.Atoi(c.Param("bid"))
+	if err != nil || bid < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	broadcast, cerr := service.GetRoomBroadcast(uint(id), uint(bid))
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusNotFound, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when retriving broadcast")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":      0,
+		"message":   "ok",
+		"broadcast": service.NewBroadcastInfo(broadcast),
+	})
+}
@@ -21,6 +21,12 @@ func mountChatRoutes(r *gin.RouterGroup) {
 	r.GET("/:id/chat", handleChatConnect)
 }
 
+func mountChatModerationRoutes(r *gin.RouterGroup) {
+	r = r.Group("", requireRoomAbility(models.RoomAbilities{AbilityModerateChat: true}))
+	r.PUT("/:id/chat/mutes/:uid", handleChatMute)
+	r.DELETE("/:id/chat/mutes/:uid", handleChatUnmute)
+}
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -71,6 +77,12 @@ func handleChatConnect(c *gin.Context) {
 		}
 	}
 
+	if cerr := service.CheckIPBan(c.ClientIP(), room.ID); cerr != nil {
+		c.Abort()
+		c.JSON(http.StatusForbidden, cerr.ToResponse())
+		return
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, c.Writer.Header())
 	if err != nil {
 		logrus.WithError(err).Infof("error establishing websocket connection")
@@ -80,6 +92,9 @@ func handleChatConnect(c *gin.Context) {
 	type auth struct {
 		Authenticated *bool  `json:"authenticated"`
 		Token         string `json:"token,omitempty"`
+		// ticket of the room password, the `access` query
+		// or cookie also works.
+		Access string `json:"access,omitempty"`
 	}
 	authInfo := auth{}
 	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
@@ -100,12 +115,15 @@ func handleChatConnect(c *gin.Context) {
 		return
 	}
 
-	allowed := service.IsUserAllowedForRoom(room, user)
+	if authInfo.Access == "" {
+		authInfo.Access = service.GetRoomAccessTicket(c, room.ID)
+	}
+	allowed := service.IsViewerAllowedForRoom(room, user, authInfo.Access)
 	if !allowed {
 		c.Abort()
 		conn.WriteJSON(WebsocketChatMessageSend{
 			MessageType:             models.ChatMessageTypeAdministration,
-			Content:                 "you have been banned from this room",
+			Content:                 service.DescribeRoomBan(room, user),
 			AdministrationMessageID: uint(cerrors.RequestRoomBanned),
 		})
 		conn.Close()
@@ -160,6 +178,9 @@ func handleChatConnect(c *gin.Context) {
 
 					switch websocketChat.MessageType {
 					case models.ChatMessageTypeMessage:
+						if chat.IsMuted(room.ID, user.ID) {
+							continue
+						}
 						message := models.ChatMessage{
 							Type:     websocketChat.MessageType,
 							Room:     *room,
@@ -214,11 +235,14 @@ func handleChatConnect(c *gin.Context) {
 				websocketChat := WebsocketChatMessageSend{
 					MessageType: message.Type,
 					Content:     message.Message,
-					SenderName:  message.Sender.Username,
-					SenderID:    message.Sender.ID,
-					SenderRole:  message.Sender.RoleName,
 					Timestamp:   time.Now(),
 				}
+				// system messages have no sender.
+				if message.Sender != nil {
+					websocketChat.SenderName = message.Sender.Username
+					websocketChat.SenderID = message.Sender.ID
+					websocketChat.SenderRole = message.Sender.RoleName
+				}
 				err := conn.SetWriteDeadline(time.Now().Add(time.Second * 5))
 				if err != nil {
 					logrus.WithField("subscribe_id", uid).WithError(err).Error("error setting websocket write deadline")
@@ -245,3 +269,58 @@ func handleChatConnect(c *gin.Context) {
 		}
 	}()
 }
+
+func handleChatMute(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	uid, err := strconv.Atoi(c.Param("uid"))
+	if err != nil || uid < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	data := struct {
+		// in minutes.
+		Duration uint `json:"duration"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	cerr := service.MuteChatUser(uint(id), uint(uid), data.Duration)
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when muting chat user")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
+
+func handleChatUnmute(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	uid, err := strconv.Atoi(c.Param("uid"))
+	if err != nil || uid < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	service.UnmuteChatUser(uint(id), uint(uid))
+	c.JSON(http.StatusOK, common.OkResponse)
+}
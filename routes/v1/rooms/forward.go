@@ -0,0 +1,194 @@
+package rooms
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/middleware"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountForwardRoutes(r *gin.RouterGroup) {
+	// forwards carry stream keys of other platforms.
+	r = r.Group("", middleware.RoomOwnershipRequired("id"))
+	r.GET("/:id/forwards", handleForwardDestinationList)
+	r.POST("/:id/forwards", handleForwardDestinationCreation)
+	r.PUT("/:id/forwards/:fid", handleForwardDestinationModification)
+	r.DELETE("/:id/forwards/:fid", handleForwardDestinationDeletion)
+}
+
+type forwardDestinationInfo struct {
+	ID      uint    `json:"id"`
+	Name    string  `json:"name"`
+	Url     string  `json:"url"`
+	HasKey  bool    `json:"has_key"`
+	Enabled bool    `json:"enabled"`
+	Status  string  `json:"status"`
+	Error   *string `json:"error"`
+}
+
+func toForwardDestinationInfo(dest *models.ForwardDestination) forwardDestinationInfo {
+	status := service.GetForwardDestinationStatus(dest.RoomID, dest.ID)
+	return forwardDestinationInfo{
+		ID:      dest.ID,
+		Name:    dest.Name,
+		Url:     dest.Url,
+		HasKey:  dest.Key != "",
+		Enabled: dest.Enabled,
+		Status:  string(status.Status),
+		Error:   status.Error,
+	}
+}
+
+func handleForwardDestinationList(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	dests, cerr := service.ListRoomForwardDestinations(uint(id))
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when listing forward destinations")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":         0,
+		"message":      "ok",
+		"destinations": lo.Map(dests, func(d *models.ForwardDestination, _ int) forwardDestinationInfo { return toForwardDestinationInfo(d) }),
+	})
+}
+
+func handleForwardDestinationCreation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	data := struct {
+		Name    string `json:"name"`
+		Url     string `json:"url"`
+		Key     string `json:"key"`
+		Enabled *bool  `json:"enabled"`
+	}{}
+	err = c.Bind(&data)
+	if err != nil || data.Url == "" {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	room, cerr := service.GetRoomByID(uint(id))
+	if cerr == nil {
+		var dest *models.ForwardDestination
+		dest, cerr = service.CreateRoomForwardDestination(room, data.Name, data.Url, data.Key, data.Enabled == nil || *data.Enabled)
+		if cerr == nil {
+			c.JSON(http.StatusCreated, common.Response{
+				"code":        0,
+				"message":     "ok",
+				"destination": toForwardDestinationInfo(dest),
+			})
+			return
+		}
+	}
+
+	if rerr, ok := cerr.(cerrors.RequestError); ok {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, rerr.ToResponse())
+	} else {
+		logrus.WithError(cerr).Error("error when creating forward destination")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+	}
+}
+
+func handleForwardDestinationModification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	fid, err := strconv.Atoi(c.Param("fid"))
+	if err != nil || fid < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	data := struct {
+		Name    *string `json:"name"`
+		Url     *string `json:"url"`
+		Key     *string `json:"key"`
+		Enabled *bool   `json:"enabled"`
+	}{}
+	if err := c.Bind(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	room, cerr := service.GetRoomByID(uint(id))
+	if cerr == nil {
+		var dest *models.ForwardDestination
+		dest, cerr = service.UpdateRoomForwardDestination(room, uint(fid), data.Name, data.Url, data.Key, data.Enabled)
+		if cerr == nil {
+			c.JSON(http.StatusOK, common.Response{
+				"code":        0,
+				"message":     "ok",
+				"destination": toForwardDestinationInfo(dest),
+			})
+			return
+		}
+	}
+
+	if rerr, ok := cerr.(cerrors.RequestError); ok {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, rerr.ToResponse())
+	} else {
+		logrus.WithError(cerr).Error("error when modifying forward destination")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+	}
+}
+
+func handleForwardDestinationDeletion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	fid, err := strconv.Atoi(c.Param("fid"))
+	if err != nil || fid < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	cerr := service.DeleteRoomForwardDestination(uint(id), uint(fid))
+	if cerr != nil {
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.Abort()
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when deleting forward destination")
+			c.Abort()
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
@@ -0,0 +1,53 @@
+package rooms
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountHealthRoutes(r *gin.RouterGroup) {
+	r = r.Group("", requireRoomAbility(models.RoomAbilities{AbilityViewAnalytics: true}))
+	r.GET("/:id/health", handleRoomHealthRetrival)
+}
+
+func handleRoomHealthRetrival(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	room, cerr := service.GetRoomByID(uint(id))
+	if cerr != nil {
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.Abort()
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when looking up room for health")
+			c.Abort()
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+
+	series := service.GetRoomStreamHealth(room)
+	var latest *service.StreamHealth
+	if len(series) > 0 {
+		latest = &series[len(series)-1]
+	}
+	c.JSON(http.StatusOK, common.Response{
+		"code":       0,
+		"message":    "ok",
+		"publishing": room.Status == models.RoomStatusStreaming && room.SrsClientID != nil,
+		"health":     latest,
+		"series":     series,
+	})
+}
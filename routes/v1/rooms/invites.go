@@ -0,0 +1,104 @@
+package rooms
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountInviteRoutes(r *gin.RouterGroup) {
+	r = r.Group("", requireRoomAbility(models.RoomAbilities{AbilityManagePermission: true}))
+	r.GET("/:id/invites", handleInviteList)
+	r.POST("/:id/invites", handleInviteCreation)
+	r.DELETE("/:id/invites/:iid", handleInviteRevocation)
+}
+
+func handleInviteList(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	invites, cerr := service.ListRoomInvites(uint(id))
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when listing invites")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"invites": lo.Map(invites, func(invite *models.RoomInvite, _ int) service.InviteInfo { return service.NewInviteInfo(invite) }),
+	})
+}
+
+func handleInviteCreation(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	data := service.InviteData{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	invite, cerr := service.CreateRoomInvite(room, service.GetUserFromContext(c), &data)
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when creating invite")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, common.Response{
+		"code":    0,
+		"message": "ok",
+		"invite":  service.NewInviteInfo(invite),
+	})
+}
+
+func handleInviteRevocation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	iid, err := strconv.Atoi(c.Param("iid"))
+	if err != nil || iid < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	cerr := service.RevokeRoomInvite(uint(id), uint(iid))
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusNotFound, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when revoking invite")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
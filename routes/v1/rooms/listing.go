@@ -0,0 +1,122 @@
+package rooms
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountListingRoutes(r *gin.RouterGroup) {
+	r = r.Group("", requireRoomAbility(models.RoomAbilities{AbilityEditRoom: true}))
+	r.PUT("/:id/description", handleRoomDescriptionModification)
+	r.PUT("/:id/category", handleRoomCategoryModification)
+	r.PUT("/:id/tags", handleRoomTagsModification)
+	r.PUT("/:id/visibility/:visibility", handleRoomVisibilityModification)
+}
+
+func respondListingError(c *gin.Context, err cerrors.ChocolateError) {
+	c.Abort()
+	if rerr, ok := err.(cerrors.RequestError); ok {
+		c.JSON(http.StatusBadRequest, rerr.ToResponse())
+	} else {
+		logrus.WithError(err).Error("error when modifying room listing")
+		c.JSON(http.StatusInternalServerError, err.ToResponse())
+	}
+}
+
+func handleRoomDescriptionModification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	data := struct {
+		Description string `json:"description"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	if cerr := service.SetRoomDescription(uint(id), data.Description); cerr != nil {
+		respondListingError(c, cerr)
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
+
+func handleRoomCategoryModification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	data := struct {
+		// null to uncategorize
+		CategoryID *uint `json:"category_id"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	if cerr := service.SetRoomCategory(uint(id), data.CategoryID); cerr != nil {
+		respondListingError(c, cerr)
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
+
+func handleRoomTagsModification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	data := struct {
+		Tags []string `json:"tags"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	tags, cerr := service.SetRoomTags(uint(id), data.Tags)
+	if cerr != nil {
+		respondListingError(c, cerr)
+		return
+	}
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"tags":    tags,
+	})
+}
+
+func handleRoomVisibilityModification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	visibility := models.RoomVisibility(c.Param("visibility"))
+	if cerr := service.ChangeRoomVisibility(uint(id), visibility); cerr != nil {
+		respondListingError(c, cerr)
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
@@ -0,0 +1,111 @@
+package rooms
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/middleware"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountMemberRoutes(r *gin.RouterGroup) {
+	r = r.Group("", middleware.RoomOwnershipRequired("id"))
+	r.GET("/:id/members", handleMemberList)
+	r.PUT("/:id/members/:username/:role", handleMemberGrant)
+	r.DELETE("/:id/members/:username", handleMemberRevocation)
+}
+
+type roomMemberInfo struct {
+	UserID    uint                  `json:"user_id"`
+	Username  string                `json:"username"`
+	Role      models.RoomMemberRole `json:"role"`
+	Abilities *models.RoomAbilities `json:"abilities"`
+	CreatedAt time.Time             `json:"created_at"`
+}
+
+func handleMemberList(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	members, cerr := service.ListRoomMembers(room.ID)
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when listing room members")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"members": lo.Map(members, func(member *models.RoomMember, _ int) roomMemberInfo {
+			return roomMemberInfo{
+				UserID:    member.UserID,
+				Username:  member.User.Username,
+				Role:      member.Role,
+				Abilities: member.Role.Abilities(),
+				CreatedAt: member.CreatedAt,
+			}
+		}),
+	})
+}
+
+func handleMemberGrant(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	role := models.RoomMemberRole(c.Param("role"))
+	user, cerr := service.SetRoomMember(room, c.Param("username"), role)
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when granting room member")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"member": roomMemberInfo{
+			UserID:    user.ID,
+			Username:  user.Username,
+			Role:      role,
+			Abilities: role.Abilities(),
+			CreatedAt: time.Now(),
+		},
+	})
+}
+
+func handleMemberRevocation(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	cerr := service.RemoveRoomMember(room.ID, c.Param("username"))
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when revoking room member")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
@@ -2,10 +2,24 @@ package rooms
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/middleware"
 )
 
 func Mount(r *gin.RouterGroup) {
 	rooms := r.Group("rooms")
+	authed := rooms.Group("", middleware.AuthRequired())
+
 	mountChatRoutes(rooms)
-	mountRoomsRoutes(rooms)
+	mountSchedulePublicRoutes(rooms)
+	mountRoomsRoutes(rooms, authed)
+	mountPlaybackTicketRoutes(authed)
+	mountForwardRoutes(authed)
+	mountHealthRoutes(authed)
+	mountBroadcastRoutes(authed)
+	mountScheduleRoutes(authed)
+	mountListingRoutes(authed)
+	mountInviteRoutes(authed)
+	mountMemberRoutes(authed)
+	mountTransferRoutes(authed)
+	mountChatModerationRoutes(authed)
 }
@@ -0,0 +1,51 @@
+package rooms
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/service"
+)
+
+func mountPlaybackTicketRoutes(r *gin.RouterGroup) {
+	r.POST("/:id/playback-ticket", handlePlaybackTicketIssue)
+}
+
+// handlePlaybackTicketIssue gives urls that play the
+// room as the user, for players unable to send the
+// authorization header or cookies.
+func handlePlaybackTicketIssue(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	user := service.GetUserFromContext(c)
+	access := service.GetRoomAccessTicket(c, room.ID)
+	if !service.IsViewerAllowedForRoom(room, user, access) {
+		c.Abort()
+		c.JSON(http.StatusForbidden, common.SampleResponse(cerrors.RequestRoomBanned, service.DescribeRoomBan(room, user)))
+		return
+	}
+
+	ticket, expire := service.CreatePlaybackTicket(room, user, time.Now())
+	query := url.Values{"ticket": {ticket}}
+	if service.VerifyRoomAccessTicket(access, room, time.Now()) {
+		query.Set("access", access)
+	}
+	c.JSON(http.StatusOK, common.Response{
+		"code":       0,
+		"message":    "ok",
+		"ticket":     ticket,
+		"expires_at": expire,
+		"playback": common.Response{
+			"hls": fmt.Sprintf("/v1/playback/%d/hls?%s", room.ID, query.Encode()),
+			"flv": fmt.Sprintf("/v1/playback/%d/flv?%s", room.ID, query.Encode()),
+		},
+	})
+}
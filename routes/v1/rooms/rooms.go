@@ -15,24 +15,36 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func mountRoomsRoutes(r *gin.RouterGroup) {
-	r.GET("/:id", handleRoomInfoRetrievel)
+func mountRoomsRoutes(public *gin.RouterGroup, r *gin.RouterGroup) {
+	public.GET("/:id", handleRoomInfoRetrievel)
+	public.POST("/:id/unlock", handleRoomUnlock)
 
-	r.Use(middleware.AuthRequired())
 	r.GET("/", handleListRooms)
 	r.POST("/create", middleware.AbilityRequired(models.Role{AbilityCreateRoom: true}), handleRoomCreation)
 
-	r.Use(middleware.RoomOwnershipRequired("id"))
-	r.PATCH("/:id/:action", handleRoomAction)
-	r.DELETE("/:id", handleRoomDeletion)
-	r.PUT("/:id/title/:title", handleRoomTitleModification)
+	r.DELETE("/:id", middleware.RoomOwnershipRequired("id"), handleRoomDeletion)
+	r.PATCH("/:id/:action", requireRoomAbility(models.RoomAbilities{AbilityControlStream: true}), handleRoomAction)
+	r.PUT("/:id/title/:title", requireRoomAbility(models.RoomAbilities{AbilityEditRoom: true}), handleRoomTitleModification)
 
-	r.PUT("/:id/permission-type/:type", handleRoomPermissionModification)
+	// moderators do not hold it, only the owner and co-hosts.
+	access := r.Group("", requireRoomAbility(models.RoomAbilities{AbilityManageAccess: true}))
+	access.PUT("/:id/access-policy", handleRoomAccessPolicyModification)
+	access.PUT("/:id/password", handleRoomPasswordModification)
+	access.PUT("/:id/max-viewers/:count", handleRoomMaxViewersModification)
 
-	r.PUT("/:id/permission/:subtype/:subject", handleRoomPermissionSubjectAppend)
-	r.DELETE("/:id/permission/:subtype/:subject", handleRoomPermissionSubjectDelete)
+	permission := r.Group("", requireRoomAbility(models.RoomAbilities{AbilityManagePermission: true}))
+	permission.PUT("/:id/permission-type/:type", handleRoomPermissionModification)
 
-	r.GET("/:id/permission/:subtype/auto-complete", handlePermissionAutoComplete)
+	permission.PUT("/:id/permission/:subtype/:subject", handleRoomPermissionSubjectAppend)
+	permission.DELETE("/:id/permission/:subtype/:subject", handleRoomPermissionSubjectDelete)
+
+	permission.GET("/:id/permission/:subtype/auto-complete", handlePermissionAutoComplete)
+}
+
+// requireRoomAbility authorizes the room in the `id`
+// param by room roles, see models.RoomAbilities.
+func requireRoomAbility(ability models.RoomAbilities) gin.HandlerFunc {
+	return middleware.RoomAbilityRequired("id", ability)
 }
 
 func handleRoomCreation(c *gin.Context) {
@@ -44,6 +56,8 @@ func handleRoomCreation(c *gin.Context) {
 	}
 	data := struct {
 		Title string `json:"title"`
+		// optional, see service.SaveRoomTemplate.
+		TemplateID *uint `json:"template_id"`
 	}{}
 	err := c.Bind(&data)
 	if err != nil || data.Title == "" {
@@ -52,7 +66,7 @@ func handleRoomCreation(c *gin.Context) {
 		return
 	}
 
-	room, err := service.CreateRoomForUser(user, data.Title)
+	room, err := service.CreateRoomForUser(user, data.Title, data.TemplateID)
 	if err != nil {
 		if rerr, ok := err.(errors.RequestError); ok {
 			c.Abort()
@@ -214,6 +228,7 @@ func handleListRooms(c *gin.Context) {
 		Title          string                    `json:"title"`
 		Status         string                    `json:"status"`
 		PermissionType models.RoomPermissionType `json:"permission_type"`
+		Visibility     models.RoomVisibility     `json:"visibility"`
 	}
 
 	result := lo.Map(rooms, func(room *models.Room, _ int) roomListInfo {
@@ -224,6 +239,7 @@ func handleListRooms(c *gin.Context) {
 			Title:          room.Title,
 			Status:         room.Status.ToString(),
 			PermissionType: room.PermissionType,
+			Visibility:     room.Visibility,
 		}
 	})
 
@@ -244,7 +260,7 @@ func handleRoomInfoRetrievel(c *gin.Context) {
 		return
 	}
 
-	room, cerr := service.GetRoomByID(uint(id))
+	room, cerr := service.GetRoomByIDWithListing(uint(id))
 	if cerr != nil {
 		if rerr, ok := cerr.(errors.RequestError); ok {
 			c.Abort()
@@ -256,10 +272,20 @@ func handleRoomInfoRetrievel(c *gin.Context) {
 		return
 	}
 	user := service.TryGetUserFromContext(c)
-	allowed := service.IsUserAllowedForRoom(room, user)
+	if !service.IsRoomVisibleToUser(room, user) {
+		c.Abort()
+		c.JSON(http.StatusNotFound, common.SampleResponse(errors.RequestRoomNotFound, "room not found"))
+		return
+	}
+	access := service.GetRoomAccessTicket(c, room.ID)
+	allowed := service.IsViewerAllowedForRoom(room, user, access)
 	if !allowed {
 		c.Abort()
-		c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomBanned, "banned user"))
+		if service.RoomNeedsPassword(room, access) {
+			c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomPasswordRequired, "password required"))
+		} else {
+			c.JSON(http.StatusForbidden, common.SampleResponse(errors.RequestRoomBanned, "banned user"))
+		}
 		return
 	}
 
@@ -272,11 +298,30 @@ func handleRoomInfoRetrievel(c *gin.Context) {
 		"playback":       room.GetPlaybackInfo(),
 		"viewers":        room.Viewers,
 		"last_streaming": room.LastStreamingAt,
+		"description":    room.Description,
+		"category":       room.Category,
+		"tags":           lo.Map(room.Tags, func(tag models.RoomTag, _ int) string { return tag.Name }),
+		"visibility":     room.Visibility,
+
+		"password_protected": room.Password != "",
+		"max_viewers":        room.MaxViewers,
 	}
 
-	if user != nil && room.OwnerID == user.ID {
-		response["permission_type"] = room.PermissionType
+	abilities, cerr := service.GetRoomAbilities(room, user)
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error retriving room abilities")
+	}
+	if abilities != (models.RoomAbilities{}) {
+		response["abilities"] = abilities
+	}
+	if abilities.AbilityControlStream {
 		response["uid"] = room.UID
+	}
+	if abilities.AbilityManageAccess {
+		response["access_policy"] = room.AccessPolicy
+	}
+	if abilities.AbilityManagePermission {
+		response["permission_type"] = room.PermissionType
 		response["permission_items"] = lo.Map(room.PermissionItems, func(item models.PermissionItem, _ int) map[string]interface{} {
 			var username *string = nil
 			if item.SubjectType == models.PermissionSubjectTypeUser {
@@ -295,10 +340,15 @@ func handleRoomInfoRetrievel(c *gin.Context) {
 				}
 			}
 			return map[string]interface{}{
-				"username": username,
-				"user_id":  item.SubjectUserID,
-				"label":    item.SubjectLabelName,
-				"type":     item.SubjectType,
+				"username":   username,
+				"user_id":    item.SubjectUserID,
+				"label":      item.SubjectLabelName,
+				"role":       item.SubjectRoleName,
+				"type":       item.SubjectType,
+				"expires_at": item.ExpiresAt,
+				"reason":     item.Reason,
+				"created_by": item.CreatedBy,
+				"created_at": item.CreatedAt,
 			}
 		})
 	}
@@ -365,6 +415,8 @@ func handleRoomAction(c *gin.Context) {
 			"code":      0,
 			"message":   "ok",
 			"streamkey": room.GetStreamKey(),
+			// empty if the client should guess it.
+			"server": service.GetRoomPublishServer(room),
 		})
 	} else {
 		c.JSON(http.StatusOK, common.Response{
@@ -412,6 +464,145 @@ func handleRoomPermissionModification(c *gin.Context) {
 	c.JSON(http.StatusOK, common.OkResponse)
 }
 
+// an empty policy clears it.
+func handleRoomAccessPolicyModification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	data := struct {
+		Policy string `json:"policy"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	policy, cerr := service.ChangeRoomAccessPolicy(uint(id), data.Policy)
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(errors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when handling room access policy modify")
+			c.JSON(http.StatusInternalServerError, common.SampleResponse(errors.RequestInternalServerError, "internal server error"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":          0,
+		"message":       "ok",
+		"access_policy": lo.If(policy == nil, "").ElseF(func() string { return policy.String() }),
+	})
+}
+
+// an empty password removes it.
+func handleRoomPasswordModification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	data := struct {
+		Password string `json:"password"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	cerr := service.ChangeRoomPassword(uint(id), data.Password)
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(errors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when handling room password modify")
+			c.JSON(http.StatusInternalServerError, common.SampleResponse(errors.RequestInternalServerError, "internal server error"))
+		}
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
+
+// a count of 0 removes the cap.
+func handleRoomMaxViewersModification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	count, err := strconv.Atoi(c.Param("count"))
+	if err != nil || count < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidParameter, "bad count parameter"))
+		return
+	}
+
+	cerr := service.ChangeRoomMaxViewers(uint(id), uint(count))
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when handling room max viewers modify")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, common.SampleResponse(errors.RequestInternalServerError, "internal server error"))
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
+
+// handleRoomUnlock exchanges the room password for an
+// access ticket, which is also set as a cookie.
+func handleRoomUnlock(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	data := struct {
+		Password string `json:"password"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	ticket, expire, cerr := service.UnlockRoom(room, data.Password, c.ClientIP())
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(errors.RequestError); ok {
+			status := http.StatusBadRequest
+			switch rerr.ID {
+			case errors.RequestWrongRoomPassword:
+				status = http.StatusForbidden
+			case errors.RequestTooManyPasswordAttempts:
+				status = http.StatusTooManyRequests
+			}
+			c.JSON(status, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when unlocking room")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+
+	service.SetRoomAccessCookie(c, room.ID, ticket)
+	c.JSON(http.StatusOK, common.Response{
+		"code":       0,
+		"message":    "ok",
+		"access":     ticket,
+		"expires_at": expire,
+	})
+}
+
 func handleRoomPermissionSubjectAppend(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil || id < 0 {
@@ -422,12 +613,27 @@ func handleRoomPermissionSubjectAppend(c *gin.Context) {
 	permissionType := models.PermissionSubjectType(c.Param("subtype"))
 	subject := c.Param("subject")
 
+	// the body is optional, items are permanent and
+	// undocumented without it.
+	data := service.PermissionItemData{}
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&data); err != nil {
+			c.Abort()
+			c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidRequestData, "bad request payload"))
+			return
+		}
+	}
+	creator := service.GetUserFromContext(c)
+
 	if permissionType == models.PermissionSubjectTypeLabel {
 		label := string(subject)
-		err = service.AddRoomPermissionItem_Label(uint(id), label)
+		err = service.AddRoomPermissionItem_Label(uint(id), label, creator, &data)
 	} else if permissionType == models.PermissionSubjectTypeUser {
 		username := string(subject)
-		err = service.AddRoomPermissionItem_User(uint(id), username)
+		err = service.AddRoomPermissionItem_User(uint(id), username, creator, &data)
+	} else if permissionType == models.PermissionSubjectTypeRole {
+		role := string(subject)
+		err = service.AddRoomPermissionItem_Role(uint(id), role, creator, &data)
 	} else {
 		c.Abort()
 		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestUnknownRoomPermissionItemType, "unknown permission item type"))
@@ -466,6 +672,9 @@ func handleRoomPermissionSubjectDelete(c *gin.Context) {
 	} else if permissionType == models.PermissionSubjectTypeUser {
 		username := string(subject)
 		err = service.DeleteRoomPermissionItem_User(uint(id), username)
+	} else if permissionType == models.PermissionSubjectTypeRole {
+		role := string(subject)
+		err = service.DeleteRoomPermissionItem_Role(uint(id), role)
 	} else {
 		c.Abort()
 		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestUnknownRoomPermissionItemType, "unknown permission item type"))
@@ -497,7 +706,7 @@ func handlePermissionAutoComplete(c *gin.Context) {
 	permissionType := models.PermissionSubjectType(c.Param("subtype"))
 	prefix := c.Query("prefix")
 
-	if permissionType != models.PermissionSubjectTypeLabel && permissionType != models.PermissionSubjectTypeUser {
+	if permissionType != models.PermissionSubjectTypeLabel && permissionType != models.PermissionSubjectTypeUser && permissionType != models.PermissionSubjectTypeRole {
 		c.Abort()
 		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidParameter, "bad request data"))
 		return
@@ -0,0 +1,233 @@
+package rooms
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/middleware"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountSchedulePublicRoutes(r *gin.RouterGroup) {
+	r.GET("/:id/schedules", handleScheduleList)
+	r.GET("/:id/schedule.ics", handleScheduleCalendar)
+	r.PUT("/:id/follow", middleware.AuthRequired(), handleRoomFollow)
+	r.DELETE("/:id/follow", middleware.AuthRequired(), handleRoomUnfollow)
+}
+
+func mountScheduleRoutes(r *gin.RouterGroup) {
+	r = r.Group("", requireRoomAbility(models.RoomAbilities{AbilityEditRoom: true}))
+	r.POST("/:id/schedules", handleScheduleCreation)
+	r.PUT("/:id/schedules/:sid", handleScheduleModification)
+	r.DELETE("/:id/schedules/:sid", handleScheduleDeletion)
+}
+
+// getRoomFromParam responds the error itself if the
+// room is not found or not visible to the user.
+func getRoomFromParam(c *gin.Context) *models.Room {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return nil
+	}
+
+	room, cerr := service.GetRoomByID(uint(id))
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusNotFound, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error retriving room")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return nil
+	}
+	if !service.IsRoomVisibleToUser(room, service.TryGetUserFromContext(c)) {
+		c.Abort()
+		c.JSON(http.StatusNotFound, common.SampleResponse(cerrors.RequestRoomNotFound, "room not found"))
+		return nil
+	}
+	return room
+}
+
+func handleScheduleList(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	schedules, cerr := service.ListRoomSchedules(room.ID)
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when listing schedules")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+
+	now := time.Now()
+	c.JSON(http.StatusOK, common.Response{
+		"code":      0,
+		"message":   "ok",
+		"schedules": lo.Map(schedules, func(s *models.Schedule, _ int) service.ScheduleInfo { return service.NewScheduleInfo(s, now) }),
+	})
+}
+
+func handleScheduleCalendar(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	calendar, cerr := service.RenderRoomCalendar(room)
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when rendering room calendar")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar))
+}
+
+func handleRoomFollow(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+	user := service.GetUserFromContext(c)
+
+	cerr := service.FollowRoom(user.ID, room.ID)
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when following room")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
+
+func handleRoomUnfollow(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+	user := service.GetUserFromContext(c)
+
+	cerr := service.UnfollowRoom(user.ID, room.ID)
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when unfollowing room")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
+
+func handleScheduleCreation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	data := service.ScheduleData{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	schedule, cerr := service.CreateRoomSchedule(uint(id), &data)
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when creating schedule")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, common.Response{
+		"code":     0,
+		"message":  "ok",
+		"schedule": service.NewScheduleInfo(schedule, time.Now()),
+	})
+}
+
+func handleScheduleModification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	sid, err := strconv.Atoi(c.Param("sid"))
+	if err != nil || sid < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	data := service.ScheduleData{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	schedule, cerr := service.UpdateRoomSchedule(uint(id), uint(sid), &data)
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when updating schedule")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":     0,
+		"message":  "ok",
+		"schedule": service.NewScheduleInfo(schedule, time.Now()),
+	})
+}
+
+func handleScheduleDeletion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+	sid, err := strconv.Atoi(c.Param("sid"))
+	if err != nil || sid < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	cerr := service.DeleteRoomSchedule(uint(id), uint(sid))
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when deleting schedule")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
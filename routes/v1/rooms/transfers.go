@@ -0,0 +1,170 @@
+package rooms
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/middleware"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountTransferRoutes(r *gin.RouterGroup) {
+	// the recipient does not own the room yet.
+	r.POST("/:id/transfer/accept", handleRoomTransferAcceptance)
+	r.POST("/:id/transfer/decline", handleRoomTransferDeclination)
+
+	owner := r.Group("", middleware.RoomOwnershipRequired("id"))
+	owner.GET("/:id/transfer", handleRoomTransferRetrieval)
+	owner.POST("/:id/transfer", handleRoomTransferOffer)
+	owner.DELETE("/:id/transfer", handleRoomTransferCancellation)
+	owner.POST("/:id/templates", handleRoomTemplateCreation)
+}
+
+func respondTransferError(c *gin.Context, cerr cerrors.ChocolateError, message string) {
+	c.Abort()
+	if rerr, ok := cerr.(cerrors.RequestError); ok {
+		if rerr.ID == cerrors.RequestRoomTransferNotFound || rerr.ID == cerrors.RequestRoomNotFound {
+			c.JSON(http.StatusNotFound, rerr.ToResponse())
+		} else {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		}
+	} else {
+		logrus.WithError(cerr).Error(message)
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+	}
+}
+
+func handleRoomTransferRetrieval(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	transfer, cerr := service.GetRoomTransfer(room.ID)
+	if cerr != nil {
+		respondTransferError(c, cerr, "error when retriving room transfer")
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":     0,
+		"message":  "ok",
+		"transfer": service.NewRoomTransferInfo(transfer),
+	})
+}
+
+func handleRoomTransferOffer(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	data := struct {
+		Username string `json:"username"`
+	}{}
+	if err := c.BindJSON(&data); err != nil || data.Username == "" {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	transfer, cerr := service.OfferRoomTransfer(room, data.Username)
+	if cerr != nil {
+		respondTransferError(c, cerr, "error when offering room transfer")
+		return
+	}
+
+	c.JSON(http.StatusCreated, common.Response{
+		"code":     0,
+		"message":  "ok",
+		"transfer": service.NewRoomTransferInfo(transfer),
+	})
+}
+
+func handleRoomTransferCancellation(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	if cerr := service.CancelRoomTransfer(room.ID); cerr != nil {
+		respondTransferError(c, cerr, "error when cancelling room transfer")
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
+
+func handleRoomTransferAcceptance(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	room, cerr := service.AcceptRoomTransfer(uint(id), service.GetUserFromContext(c))
+	if cerr != nil {
+		respondTransferError(c, cerr, "error when accepting room transfer")
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"room_id": room.ID,
+		"title":   room.Title,
+	})
+}
+
+func handleRoomTransferDeclination(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	if cerr := service.DeclineRoomTransfer(uint(id), service.GetUserFromContext(c)); cerr != nil {
+		respondTransferError(c, cerr, "error when declining room transfer")
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
+
+func handleRoomTemplateCreation(c *gin.Context) {
+	room := getRoomFromParam(c)
+	if room == nil {
+		return
+	}
+
+	data := struct {
+		Name string `json:"name"`
+	}{}
+	if err := c.BindJSON(&data); err != nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidRequestData, "bad request payload"))
+		return
+	}
+
+	template, cerr := service.SaveRoomTemplate(room, service.GetUserFromContext(c), data.Name)
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusBadRequest, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when saving room template")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, common.Response{
+		"code":     0,
+		"message":  "ok",
+		"template": template,
+	})
+}
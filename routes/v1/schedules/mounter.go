@@ -0,0 +1,8 @@
+package schedules
+
+import "github.com/gin-gonic/gin"
+
+func Mount(r *gin.RouterGroup) {
+	schedules := r.Group("/schedules")
+	mountSchedulesRoutes(schedules)
+}
@@ -0,0 +1,128 @@
+package schedules
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/middleware"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+const maxUpcomingDays = 31
+
+func mountSchedulesRoutes(r *gin.RouterGroup) {
+	r.GET("/", handleUpcomingList)
+	r.GET("/following.ics", handleFollowingCalendar)
+	r.GET("/following", middleware.AuthRequired(), handleFollowingList)
+	r.POST("/following/calendar-token", middleware.AuthRequired(), handleCalendarTokenRotation)
+}
+
+// parseUpcomingQuery parses `days` and `limit`, responds
+// the error itself if invalid.
+func parseUpcomingQuery(c *gin.Context) (time.Duration, uint, bool) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days <= 0 || days > maxUpcomingDays {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "days should be 1 to 31"))
+		return 0, 0, false
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "invalid limit"))
+		return 0, 0, false
+	}
+	return time.Duration(days) * 24 * time.Hour, uint(limit), true
+}
+
+func handleUpcomingList(c *gin.Context) {
+	window, limit, ok := parseUpcomingQuery(c)
+	if !ok {
+		return
+	}
+
+	upcoming, err := service.ListUpcomingStreams(nil, window, limit)
+	if err != nil {
+		logrus.WithError(err).Error("error when listing upcoming streams")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, err.ToResponse())
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":     0,
+		"message":  "ok",
+		"upcoming": upcoming,
+	})
+}
+
+func handleFollowingList(c *gin.Context) {
+	window, limit, ok := parseUpcomingQuery(c)
+	if !ok {
+		return
+	}
+	user := service.GetUserFromContext(c)
+
+	upcoming, err := service.ListFollowedUpcomingStreams(user, window, limit)
+	if err != nil {
+		logrus.WithError(err).Error("error when listing followed upcoming streams")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, err.ToResponse())
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":     0,
+		"message":  "ok",
+		"upcoming": upcoming,
+		// subscribe `following.ics?token=` in calendar apps.
+		"calendar_token": service.CreateCalendarToken(user),
+	})
+}
+
+func handleFollowingCalendar(c *gin.Context) {
+	uid, ok, err := service.VerifyCalendarToken(c.Query("token"))
+	if err != nil {
+		logrus.WithError(err).Error("error when verifying calendar token")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, err.ToResponse())
+		return
+	}
+	if !ok {
+		c.Abort()
+		c.JSON(http.StatusForbidden, common.SampleResponse(cerrors.RequestCalendarTokenInvalid, "invalid calendar token"))
+		return
+	}
+
+	calendar, err := service.RenderFollowedCalendar(uid)
+	if err != nil {
+		logrus.WithError(err).Error("error when rendering followed calendar")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, err.ToResponse())
+		return
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar))
+}
+
+// handleCalendarTokenRotation revokes the feed urls given
+// out, e.g. a leaked one.
+func handleCalendarTokenRotation(c *gin.Context) {
+	token, err := service.RotateCalendarToken(service.GetUserFromContext(c))
+	if err != nil {
+		logrus.WithError(err).Error("error when rotating calendar token")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, err.ToResponse())
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":           0,
+		"message":        "ok",
+		"calendar_token": token,
+	})
+}
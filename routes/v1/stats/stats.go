@@ -4,11 +4,14 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
 	"github.com/sheey11/chocolate/chat"
 	"github.com/sheey11/chocolate/common"
 	"github.com/sheey11/chocolate/errors"
 	"github.com/sheey11/chocolate/middleware"
 	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/routes/v1/callbacks"
+	"github.com/sheey11/chocolate/routes/v1/playback"
 	"github.com/sheey11/chocolate/service"
 	"github.com/sheey11/chocolate/srs"
 	"github.com/sirupsen/logrus"
@@ -25,10 +28,34 @@ func mountStatsRoutes(r *gin.RouterGroup) {
 	r.GET("/users", handleUsers)
 	r.GET("/chats", handleChats)
 	r.GET("/rooms", handleRooms)
+	r.GET("/hls-cache", handleHlsCache)
+	r.GET("/permission-cache", handlePermissionCache)
+	r.GET("/nodes", handleNodes)
+	r.GET("/callbacks", handleCallbacks)
+}
+
+// stats of srs are per node, the `node` query selects
+// one, defaults to the first configured node.
+func getNodeFromQuery(c *gin.Context) (string, bool) {
+	node := c.Query("node")
+	if node != "" && srs.GetNode(node) == nil {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(errors.RequestInvalidParameter, "unknown srs node"))
+		return "", false
+	}
+	return node, true
 }
 
 func handleVersion(c *gin.Context) {
-	version, err := srs.GetVersion()
+	node, ok := getNodeFromQuery(c)
+	if !ok {
+		return
+	}
+	var nodeName *string
+	if node != "" {
+		nodeName = &node
+	}
+	version, err := srs.ServerOf(nodeName).GetVersion()
 
 	if err != nil {
 		logrus.WithError(err).Error("error while requesting srs for stats")
@@ -55,38 +82,58 @@ func handleVersion(c *gin.Context) {
 	})
 }
 func handleSummaries(c *gin.Context) {
+	node, ok := getNodeFromQuery(c)
+	if !ok {
+		return
+	}
 	c.JSON(http.StatusOK, common.Response{
 		"code":    0,
 		"message": "ok",
-		"summary": srs.GetSummariesHistory(),
+		"summary": srs.GetSummariesHistory(node),
 	})
 }
 func handleMemInfo(c *gin.Context) {
+	node, ok := getNodeFromQuery(c)
+	if !ok {
+		return
+	}
 	c.JSON(http.StatusOK, common.Response{
 		"code":    0,
 		"message": "ok",
-		"meminfo": srs.GetMemInfoHistory(),
+		"meminfo": srs.GetMemInfoHistory(node),
 	})
 }
 func handleVHosts(c *gin.Context) {
+	node, ok := getNodeFromQuery(c)
+	if !ok {
+		return
+	}
 	c.JSON(http.StatusOK, common.Response{
 		"code":    0,
 		"message": "ok",
-		"vhosts":  srs.GetVHostsHistory(),
+		"vhosts":  srs.GetVHostsHistory(node),
 	})
 }
 func handleStreams(c *gin.Context) {
+	node, ok := getNodeFromQuery(c)
+	if !ok {
+		return
+	}
 	c.JSON(http.StatusOK, common.Response{
 		"code":    0,
 		"message": "ok",
-		"streams": srs.GetStreamsHistory(),
+		"streams": srs.GetStreamsHistory(node),
 	})
 }
 func handleClients(c *gin.Context) {
+	node, ok := getNodeFromQuery(c)
+	if !ok {
+		return
+	}
 	c.JSON(http.StatusOK, common.Response{
 		"code":    0,
 		"message": "ok",
-		"clients": srs.GetClientsHistory(),
+		"clients": srs.GetClientsHistory(node),
 	})
 }
 
@@ -114,3 +161,44 @@ func handleRooms(c *gin.Context) {
 		"streaming": service.GetStreamingRoomCount(),
 	})
 }
+
+func handleHlsCache(c *gin.Context) {
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"cache":   playback.GetCacheStats(),
+	})
+}
+
+func handlePermissionCache(c *gin.Context) {
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"cache":   service.GetDecisionCacheStats(),
+	})
+}
+
+func handleNodes(c *gin.Context) {
+	type nodeInfo struct {
+		Name     string `json:"name"`
+		ServerID string `json:"server_id"`
+	}
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"nodes": lo.Map(srs.Nodes(), func(node *srs.Node, _ int) nodeInfo {
+			return nodeInfo{
+				Name:     node.Name,
+				ServerID: node.ServerID(),
+			}
+		}),
+	})
+}
+
+func handleCallbacks(c *gin.Context) {
+	c.JSON(http.StatusOK, common.Response{
+		"code":     0,
+		"message":  "ok",
+		"rejected": callbacks.GetRejectionStats(),
+	})
+}
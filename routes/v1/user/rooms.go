@@ -0,0 +1,78 @@
+package user
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/middleware"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/service"
+	"github.com/sirupsen/logrus"
+)
+
+func mountUserRoomRoutes(r *gin.RouterGroup) {
+	r = r.Group("", middleware.AuthRequired())
+	r.GET("/room-transfers", handleIncomingRoomTransferList)
+	r.GET("/room-templates", handleRoomTemplateList)
+	r.DELETE("/room-templates/:tid", handleRoomTemplateDeletion)
+}
+
+func handleIncomingRoomTransferList(c *gin.Context) {
+	transfers, cerr := service.ListIncomingRoomTransfers(service.GetUserFromContext(c))
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when listing incoming room transfers")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":    0,
+		"message": "ok",
+		"transfers": lo.Map(transfers, func(transfer *models.RoomTransfer, _ int) service.RoomTransferInfo {
+			return service.NewRoomTransferInfo(transfer)
+		}),
+	})
+}
+
+func handleRoomTemplateList(c *gin.Context) {
+	templates, cerr := service.ListRoomTemplates(service.GetUserFromContext(c))
+	if cerr != nil {
+		logrus.WithError(cerr).Error("error when listing room templates")
+		c.Abort()
+		c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		"code":      0,
+		"message":   "ok",
+		"templates": templates,
+	})
+}
+
+func handleRoomTemplateDeletion(c *gin.Context) {
+	tid, err := strconv.Atoi(c.Param("tid"))
+	if err != nil || tid < 0 {
+		c.Abort()
+		c.JSON(http.StatusBadRequest, common.SampleResponse(cerrors.RequestInvalidParameter, "bad request parameter"))
+		return
+	}
+
+	cerr := service.DeleteRoomTemplate(service.GetUserFromContext(c), uint(tid))
+	if cerr != nil {
+		c.Abort()
+		if rerr, ok := cerr.(cerrors.RequestError); ok {
+			c.JSON(http.StatusNotFound, rerr.ToResponse())
+		} else {
+			logrus.WithError(cerr).Error("error when deleting room template")
+			c.JSON(http.StatusInternalServerError, cerr.ToResponse())
+		}
+		return
+	}
+	c.JSON(http.StatusOK, common.OkResponse)
+}
@@ -0,0 +1,487 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/samber/lo"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sirupsen/logrus"
+)
+
+// an access policy is a boolean expression over the user
+// attributes, for example:
+//
+//	label("vip") or (role("user") and account_age >= 30d)
+//	not guest && watch_time > 10h
+//
+// supported are `and`/`&&`, `or`/`||`, `not`/`!`, the
+// `guest`, `true` and `false` literals, `label("name")`,
+// `role("name")`, and comparing `account_age` or
+// `watch_time` against durations in d, h or m.
+const (
+	maxAccessPolicyLen   = 512
+	maxAccessPolicyDepth = 16
+)
+
+// AccessSubject holds the attributes a policy evaluates.
+type AccessSubject struct {
+	Guest      bool
+	Labels     []string
+	Role       string
+	AccountAge time.Duration
+
+	uid uint
+	// loaded on first use, it takes a query.
+	watchTime *time.Duration
+}
+
+func NewAccessSubject(user *models.User, now time.Time) *AccessSubject {
+	if user == nil {
+		return &AccessSubject{Guest: true}
+	}
+	return &AccessSubject{
+		Labels:     lo.Map(user.Labels, func(label models.Label, _ int) string { return label.Name }),
+		Role:       user.RoleName,
+		AccountAge: now.Sub(user.CreatedAt),
+		uid:        user.ID,
+	}
+}
+
+// WatchTime is zero for guests, and on database errors.
+func (s *AccessSubject) WatchTime() time.Duration {
+	if s.watchTime == nil {
+		var watched time.Duration
+		if !s.Guest {
+			var err cerrors.ChocolateError
+			watched, err = models.GetUserTotalWatchTime(s.uid)
+			if err != nil {
+				logrus.WithError(err).Error("error summing watch time of user")
+			}
+		}
+		s.watchTime = &watched
+	}
+	return *s.watchTime
+}
+
+type accessNode interface {
+	eval(s *AccessSubject) bool
+}
+
+type accessLiteral bool
+
+func (n accessLiteral) eval(*AccessSubject) bool { return bool(n) }
+
+type accessGuest struct{}
+
+func (accessGuest) eval(s *AccessSubject) bool { return s.Guest }
+
+type accessNot struct{ operand accessNode }
+
+func (n accessNot) eval(s *AccessSubject) bool { return !n.operand.eval(s) }
+
+type accessAnd struct{ left, right accessNode }
+
+func (n accessAnd) eval(s *AccessSubject) bool { return n.left.eval(s) && n.right.eval(s) }
+
+type accessOr struct{ left, right accessNode }
+
+func (n accessOr) eval(s *AccessSubject) bool { return n.left.eval(s) || n.right.eval(s) }
+
+type accessLabel string
+
+func (n accessLabel) eval(s *AccessSubject) bool { return lo.Contains(s.Labels, string(n)) }
+
+type accessRole string
+
+func (n accessRole) eval(s *AccessSubject) bool { return !s.Guest && s.Role == string(n) }
+
+type accessCompare struct {
+	attribute string
+	operator  string
+	value     time.Duration
+}
+
+func (n accessCompare) eval(s *AccessSubject) bool {
+	if s.Guest {
+		return false
+	}
+	var actual time.Duration
+	switch n.attribute {
+	case "account_age":
+		actual = s.AccountAge
+	case "watch_time":
+		actual = s.WatchTime()
+	}
+	switch n.operator {
+	case "<":
+		return actual < n.value
+	case "<=":
+		return actual <= n.value
+	case ">":
+		return actual > n.value
+	case ">=":
+		return actual >= n.value
+	case "==":
+		return actual == n.value
+	case "!=":
+		return actual != n.value
+	}
+	return false
+}
+
+// AccessPolicy is a parsed policy expression.
+type AccessPolicy struct {
+	source string
+	root   accessNode
+}
+
+func (p *AccessPolicy) String() string {
+	return p.source
+}
+
+func (p *AccessPolicy) Allows(s *AccessSubject) bool {
+	return p.root.eval(s)
+}
+
+type accessToken struct {
+	kind  string // ident, string, duration, op, eof
+	text  string
+	value time.Duration
+	pos   int
+}
+
+func tokenizeAccessPolicy(source string) ([]accessToken, error) {
+	tokens := []accessToken{}
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, accessToken{kind: "string", text: string(runes[i+1 : j]), pos: i})
+			i = j + 1
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			n, err := strconv.Atoi(string(runes[i:j]))
+			if err != nil || n > 100000 {
+				return nil, fmt.Errorf("number too large at %d", i)
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("missing duration unit at %d", j)
+			}
+			var unit time.Duration
+			switch runes[j] {
+			case 'd':
+				unit = 24 * time.Hour
+			case 'h':
+				unit = time.Hour
+			case 'm':
+				unit = time.Minute
+			default:
+				return nil, fmt.Errorf("unknown duration unit at %d, expecting d, h or m", j)
+			}
+			tokens = append(tokens, accessToken{kind: "duration", text: string(runes[i : j+1]), value: time.Duration(n) * unit, pos: i})
+			i = j + 1
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, accessToken{kind: "ident", text: string(runes[i:j]), pos: i})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">", "!", "(", ")"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", r, i)
+			}
+			tokens = append(tokens, accessToken{kind: "op", text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, accessToken{kind: "eof", pos: len(runes)}), nil
+}
+
+type accessParser struct {
+	tokens []accessToken
+	pos    int
+	depth  int
+}
+
+func (p *accessParser) peek() accessToken {
+	return p.tokens[p.pos]
+}
+
+func (p *accessParser) next() accessToken {
+	token := p.tokens[p.pos]
+	if token.kind != "eof" {
+		p.pos++
+	}
+	return token
+}
+
+// accept consumes the next token if it is one of `texts`.
+func (p *accessParser) accept(texts ...string) bool {
+	token := p.peek()
+	if (token.kind == "op" || token.kind == "ident") && lo.Contains(texts, token.text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *accessParser) expect(text string) error {
+	if !p.accept(text) {
+		return p.unexpected(fmt.Sprintf("expecting %q", text))
+	}
+	return nil
+}
+
+func (p *accessParser) unexpected(hint string) error {
+	token := p.peek()
+	if token.kind == "eof" {
+		return fmt.Errorf("unexpected end of policy, %s", hint)
+	}
+	return fmt.Errorf("unexpected %q at %d, %s", token.text, token.pos, hint)
+}
+
+func (p *accessParser) parseOr() (accessNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = accessOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *accessParser) parseAnd() (accessNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and", "&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = accessAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *accessParser) parseUnary() (accessNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxAccessPolicyDepth {
+		return nil, fmt.Errorf("policy nested too deep")
+	}
+
+	if p.accept("not", "!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return accessNot{operand}, nil
+	}
+	if p.accept("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+	}
+
+	token := p.peek()
+	if token.kind != "ident" {
+		return nil, p.unexpected("expecting a condition")
+	}
+	p.next()
+	switch token.text {
+	case "true", "false":
+		return accessLiteral(token.text == "true"), nil
+	case "guest":
+		return accessGuest{}, nil
+	case "label", "role":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		arg := p.peek()
+		if arg.kind != "string" || arg.text == "" {
+			return nil, p.unexpected("expecting a quoted name")
+		}
+		p.next()
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if token.text == "label" {
+			return accessLabel(arg.text), nil
+		}
+		return accessRole(arg.text), nil
+	case "account_age", "watch_time":
+		op := p.peek()
+		if op.kind != "op" || !lo.Contains([]string{"<", "<=", ">", ">=", "==", "!="}, op.text) {
+			return nil, p.unexpected("expecting a comparison")
+		}
+		p.next()
+		value := p.peek()
+		if value.kind != "duration" {
+			return nil, p.unexpected("expecting a duration like 30d, 12h or 5m")
+		}
+		p.next()
+		return accessCompare{attribute: token.text, operator: op.text, value: value.value}, nil
+	}
+	return nil, fmt.Errorf("unknown attribute %q at %d", token.text, token.pos)
+}
+
+// ParseAccessPolicy returns nil for an empty policy.
+func ParseAccessPolicy(source string) (*AccessPolicy, cerrors.ChocolateError) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return nil, nil
+	}
+	if len(source) > maxAccessPolicyLen {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidAccessPolicy,
+			Message: fmt.Sprintf("policy should be at most %d characters", maxAccessPolicyLen),
+		}
+	}
+
+	tokens, err := tokenizeAccessPolicy(source)
+	if err == nil {
+		parser := &accessParser{tokens: tokens}
+		var root accessNode
+		root, err = parser.parseOr()
+		if err == nil && parser.peek().kind != "eof" {
+			err = parser.unexpected("expecting `and` or `or`")
+		}
+		if err == nil {
+			return &AccessPolicy{source: source, root: root}, nil
+		}
+	}
+	return nil, cerrors.RequestError{
+		ID:      cerrors.RequestInvalidAccessPolicy,
+		Message: "invalid policy: " + err.Error(),
+	}
+}
+
+func ChangeRoomAccessPolicy(id uint, source string) (*AccessPolicy, cerrors.ChocolateError) {
+	policy, err := ParseAccessPolicy(source)
+	if err != nil {
+		return nil, err
+	}
+	saved := ""
+	if policy != nil {
+		saved = policy.String()
+	}
+	if err := models.SetRoomAccessPolicy(id, saved); err != nil {
+		return nil, err
+	}
+	decisionCache.ForgetRoom(id)
+	return policy, nil
+}
+
+// roomAccessPolicy fails closed on a broken policy, which
+// only happens if the database is edited by hand.
+func roomAccessPolicy(room *models.Room) *AccessPolicy {
+	policy, err := ParseAccessPolicy(room.AccessPolicy)
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", room.ID).Error("error parsing saved access policy")
+		return &AccessPolicy{source: room.AccessPolicy, root: accessLiteral(false)}
+	}
+	return policy
+}
+
+// decideRoomAccess combines the items with the policy and
+// the room password, in a whitelist room matching either
+// is enough, in a blacklist room the user must also match
+// the policy and give the password.
+func decideRoomAccess(room *models.Room, user *models.User, policy *AccessPolicy, subject *AccessSubject, unlocked bool) (items bool, allowed bool) {
+	if user != nil || room.PermissionType != models.RoomPermissionWhitelist {
+		items = models.IsUserAllowedForRoom(room, user)
+	}
+
+	whitelist := room.PermissionType == models.RoomPermissionWhitelist
+	allowed = items
+	if policy != nil {
+		if whitelist {
+			allowed = allowed || policy.Allows(subject)
+		} else {
+			allowed = allowed && policy.Allows(subject)
+		}
+	}
+	if room.Password != "" {
+		if whitelist {
+			allowed = allowed || unlocked
+		} else {
+			allowed = allowed && unlocked
+		}
+	}
+	return items, allowed
+}
+
+// AccessDryRun explains the decision on a user.
+type AccessDryRun struct {
+	Subject *AccessSubject
+	Visible bool
+	// the owner, admins and members are always allowed.
+	Privileged   bool
+	ItemsAllowed bool
+	// nil without a policy.
+	PolicyAllowed *bool
+	Allowed       bool
+}
+
+// DryRunAccessPolicy decides as IsUserAllowedForRoom does,
+// but with `source` as the policy, or the saved one if
+// empty. A nil user is a guest, without the room password.
+func DryRunAccessPolicy(room *models.Room, user *models.User, source string) (*AccessDryRun, cerrors.ChocolateError) {
+	policy := roomAccessPolicy(room)
+	if source != "" {
+		var err cerrors.ChocolateError
+		policy, err = ParseAccessPolicy(source)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &AccessDryRun{
+		Subject:    NewAccessSubject(user, time.Now()),
+		Visible:    IsRoomVisibleToUser(room, user),
+		Privileged: isRoomInsider(room, user, isRoomMember),
+	}
+	result.ItemsAllowed, result.Allowed = decideRoomAccess(room, user, policy, result.Subject, false)
+	if policy != nil {
+		allowed := policy.Allows(result.Subject)
+		result.PolicyAllowed = &allowed
+	}
+	result.Subject.WatchTime()
+	result.Allowed = result.Visible && (result.Privileged || result.Allowed)
+	return result, nil
+}
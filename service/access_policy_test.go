@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sheey11/chocolate/models"
+)
+
+func TestAccessPolicy(t *testing.T) {
+	watched := 12 * time.Hour
+	member := &AccessSubject{Labels: []string{"vip"}, Role: "user", AccountAge: 40 * 24 * time.Hour, watchTime: &watched}
+	newcomer := &AccessSubject{Role: "user", AccountAge: time.Hour, watchTime: new(time.Duration)}
+	guest := NewAccessSubject(nil, time.Now())
+
+	cases := []struct {
+		policy string
+		member bool
+		newbie bool
+		guest  bool
+	}{
+		{`label("vip")`, true, false, false},
+		{`role("user") and account_age >= 30d`, true, false, false},
+		{`watch_time > 10h || guest`, true, false, true},
+		{`not guest && !(account_age < 2h)`, true, false, false},
+		{`label("vip") or role("user") and false`, true, false, false},
+		{`account_age < 90m`, false, true, false},
+		{`true`, true, true, true},
+	}
+	for _, c := range cases {
+		policy, err := ParseAccessPolicy(c.policy)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", c.policy, err)
+		}
+		if policy.Allows(member) != c.member || policy.Allows(newcomer) != c.newbie || policy.Allows(guest) != c.guest {
+			t.Fatalf("%s: wrong decision", c.policy)
+		}
+	}
+
+	for _, source := range []string{
+		`label(vip)`,
+		`label("vip"`,
+		`account_age >= 30`,
+		`account_age >= 30y`,
+		`watch_time`,
+		`age > 1d`,
+		`guest guest`,
+		`label("vip") and`,
+		`"vip`,
+		`guest; true`,
+		`((((((((((((((((((guest))))))))))))))))))`,
+	} {
+		if _, err := ParseAccessPolicy(source); err == nil {
+			t.Fatalf("%s: should be rejected", source)
+		}
+	}
+
+	if policy, err := ParseAccessPolicy("  "); policy != nil || err != nil {
+		t.Fatal("empty policies should be nil")
+	}
+}
+
+func TestDecideRoomAccessWithPolicy(t *testing.T) {
+	guest := NewAccessSubject(nil, time.Now())
+	room := &models.Room{PermissionType: models.RoomPermissionWhitelist}
+
+	if _, allowed := decideRoomAccess(room, nil, nil, guest, false); allowed {
+		t.Fatal("guests should not enter whitelist rooms without a policy")
+	}
+	policy, _ := ParseAccessPolicy("guest")
+	if items, allowed := decideRoomAccess(room, nil, policy, guest, false); items || !allowed {
+		t.Fatal("a policy should admit guests into whitelist rooms")
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sirupsen/logrus"
+)
+
+// StartBroadcast opens a broadcast for the publisher
+// recorded on the room, a live broadcast of another
+// publisher is closed as lost.
+func StartBroadcast(roomId uint) {
+	room, err := models.GetRoomByID(roomId, []string{})
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", roomId).Error("error looking up room to start broadcast")
+		return
+	}
+	if room.SrsClientID == nil {
+		return
+	}
+
+	live, err := models.GetLiveBroadcast(roomId)
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", roomId).Error("error looking up live broadcast")
+		return
+	}
+	if live != nil {
+		if live.ClientID == *room.SrsClientID {
+			return
+		}
+		EndBroadcast(roomId, models.BroadcastEndReasonLost, "")
+	}
+
+	broadcast, err := models.CreateBroadcast(room, room.SrsNode, *room.SrsClientID)
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", roomId).Error("error creating broadcast")
+		return
+	}
+	linkBroadcastSchedule(broadcast)
+}
+
+// publishStartedAt is when the current publish of the
+// room started, srs does not report it, `live_ms` of a
+// stream is when the stats are dumped. It falls back to
+// when the room is set streaming, if no broadcast is open.
+func publishStartedAt(room *models.Room) time.Time {
+	live, err := models.GetLiveBroadcast(room.ID)
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", room.ID).Warn("error looking up live broadcast")
+	}
+	if live != nil {
+		return live.StartTime
+	}
+	return room.LastStreamingAt
+}
+
+func EndBroadcast(roomId uint, reason models.BroadcastEndReason, detail string) {
+	err := models.CloseBroadcast(roomId, reason, detail)
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", roomId).Error("error closing broadcast")
+	}
+}
+
+func sampleBroadcastViewers(roomId uint) {
+	err := models.SampleBroadcastViewers(roomId)
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", roomId).Error("error sampling broadcast viewers")
+	}
+}
+
+func ListRoomBroadcasts(roomId uint, limit uint, page uint) (uint, []*models.Broadcast, cerrors.ChocolateError) {
+	return models.ListBroadcasts(roomId, limit, page)
+}
+
+func GetRoomBroadcast(roomId uint, id uint) (*models.Broadcast, cerrors.ChocolateError) {
+	return models.GetBroadcast(roomId, id)
+}
+
+type BroadcastInfo struct {
+	ID        uint       `json:"id"`
+	RoomID    uint       `json:"room_id"`
+	Title     string     `json:"title"`
+	Node      *string    `json:"node"`
+	Live      bool       `json:"live"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+	// seconds, till now for live broadcasts.
+	Duration       int64   `json:"duration"`
+	EndReason      string  `json:"end_reason"`
+	EndDetail      string  `json:"end_detail"`
+	PeakViewers    uint    `json:"peak_viewers"`
+	AverageViewers float64 `json:"average_viewers"`
+	UniqueViewers  uint    `json:"unique_viewers"`
+	Chats          uint    `json:"chats"`
+	// the scheduled occurrence it is linked to.
+	ScheduleID  *uint      `json:"schedule_id"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+func NewBroadcastInfo(b *models.Broadcast) BroadcastInfo {
+	return BroadcastInfo{
+		ID:             b.ID,
+		RoomID:         b.RoomID,
+		Title:          b.Title,
+		Node:           b.SrsNode,
+		Live:           b.Live(),
+		StartTime:      b.StartTime,
+		EndTime:        b.EndTime,
+		Duration:       int64(b.Duration().Seconds()),
+		EndReason:      string(b.EndReason),
+		EndDetail:      b.EndDetail,
+		PeakViewers:    b.PeakViewers,
+		AverageViewers: b.AverageViewers(),
+		UniqueViewers:  b.UniqueViewers,
+		Chats:          b.Chats,
+		ScheduleID:     b.ScheduleID,
+		ScheduledAt:    b.ScheduledAt,
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sheey11/chocolate/models"
+)
+
+func TestBroadcastViewerSampling(t *testing.T) {
+	start := time.Unix(1000, 0)
+	type sample struct {
+		viewers uint
+		at      int64
+	}
+	cases := []struct {
+		name    string
+		initial uint
+		samples []sample
+		seconds float64
+		peak    uint
+		last    uint
+	}{
+		{"no samples", 3, nil, 0, 3, 3},
+		{"steady", 0, []sample{{4, 0}, {4, 60}}, 240, 4, 4},
+		{"rise and fall", 0, []sample{{2, 10}, {10, 20}, {1, 50}}, 20 + 300, 10, 1},
+		{"initial viewers count", 5, []sample{{0, 30}}, 150, 5, 0},
+		// the late sample holds from the latest sample time.
+		{"out of order adds nothing", 0, []sample{{3, 60}, {8, 30}, {0, 90}}, 8 * 30, 8, 0},
+		{"simultaneous samples", 0, []sample{{3, 10}, {6, 10}, {0, 20}}, 60, 6, 0},
+	}
+
+	for _, c := range cases {
+		b := &models.Broadcast{StartTime: start, LastViewers: c.initial, LastViewersAt: start, PeakViewers: c.initial}
+		for _, s := range c.samples {
+			b.SampleViewers(s.viewers, start.Add(time.Duration(s.at)*time.Second))
+		}
+		if b.ViewerSeconds != c.seconds || b.PeakViewers != c.peak || b.LastViewers != c.last {
+			t.Errorf("%s: expected %v viewer seconds, peak %d, last %d, got %v, %d, %d",
+				c.name, c.seconds, c.peak, c.last, b.ViewerSeconds, b.PeakViewers, b.LastViewers)
+		}
+	}
+}
+
+func TestBroadcastAverageViewers(t *testing.T) {
+	start := time.Unix(1000, 0)
+	end := start.Add(100 * time.Second)
+	cases := []struct {
+		name      string
+		broadcast models.Broadcast
+		now       time.Time
+		average   float64
+	}{
+		{"ended", models.Broadcast{StartTime: start, EndTime: &end, ViewerSeconds: 500, LastViewers: 9, LastViewersAt: start}, end.Add(time.Hour), 5},
+		{"live counts the open segment", models.Broadcast{StartTime: start, ViewerSeconds: 200, LastViewers: 6, LastViewersAt: start.Add(50 * time.Second)}, end, 5},
+		{"just started", models.Broadcast{StartTime: start, LastViewers: 3, LastViewersAt: start}, start, 0},
+		{"clock behind last sample", models.Broadcast{StartTime: start, ViewerSeconds: 400, LastViewers: 6, LastViewersAt: end.Add(time.Second)}, end, 4},
+	}
+
+	for _, c := range cases {
+		if average := c.broadcast.AverageViewersAt(c.now); average != c.average {
+			t.Errorf("%s: expected %v average viewers, got %v", c.name, c.average, average)
+		}
+	}
+}
+
+func TestBroadcastUniqueViewers(t *testing.T) {
+	start := time.Unix(1000, 0)
+	until := start.Add(time.Hour)
+	at := func(seconds int64) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	ended := func(seconds int64) *time.Time { t := at(seconds); return &t }
+	b := &models.Broadcast{StartTime: start}
+
+	cases := []struct {
+		name     string
+		sessions []*models.UserWatchingSession
+		unique   uint
+	}{
+		{"none", nil, 0},
+		{"watching", []*models.UserWatchingSession{{UserID: 1, StartTime: at(10)}}, 1},
+		{"joined before start", []*models.UserWatchingSession{{UserID: 1, StartTime: at(-60), EndTime: ended(10)}}, 1},
+		{"left before start", []*models.UserWatchingSession{{UserID: 1, StartTime: at(-60), EndTime: ended(-1)}}, 0},
+		{"left at start", []*models.UserWatchingSession{{UserID: 1, StartTime: at(-60), EndTime: ended(0)}}, 1},
+		{"joined after until", []*models.UserWatchingSession{{UserID: 1, StartTime: until.Add(time.Second)}}, 0},
+		{"sessions of a user count once", []*models.UserWatchingSession{
+			{UserID: 1, StartTime: at(10), EndTime: ended(20)},
+			{UserID: 1, StartTime: at(30)},
+			{UserID: 2, StartTime: at(40)},
+		}, 2},
+	}
+
+	for _, c := range cases {
+		if unique := b.CountUniqueViewers(c.sessions, until); unique != c.unique {
+			t.Errorf("%s: expected %d unique viewers, got %d", c.name, c.unique, unique)
+		}
+	}
+}
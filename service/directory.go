@@ -0,0 +1,129 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+)
+
+const (
+	maxRoomTags           = 10
+	maxRoomDescriptionLen = 512
+)
+
+var tagRegex = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,24}$`)
+
+// normalizeTags lowercases, trims and dedupes tags,
+// keeping their order.
+func normalizeTags(tags []string) ([]string, cerrors.ChocolateError) {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#")))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if !tagRegex.MatchString(tag) {
+			return nil, cerrors.RequestError{
+				ID:      cerrors.RequestInvalidRoomTags,
+				Message: "tags should be 1 to 24 letters, digits, `-` or `_`",
+			}
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	if len(result) > maxRoomTags {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidRoomTags,
+			Message: "too many tags",
+		}
+	}
+	return result, nil
+}
+
+func SetRoomTags(roomId uint, tags []string) ([]string, cerrors.ChocolateError) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	return tags, models.SetRoomTags(roomId, tags)
+}
+
+func SetRoomDescription(roomId uint, description string) cerrors.ChocolateError {
+	description = strings.TrimSpace(description)
+	if utf8.RuneCountInString(description) > maxRoomDescriptionLen {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestRoomDescriptionTooLong,
+			Message: "description is too long",
+		}
+	}
+	return models.SetRoomDescription(roomId, description)
+}
+
+func SetRoomCategory(roomId uint, categoryId *uint) cerrors.ChocolateError {
+	if categoryId != nil {
+		if _, err := models.GetCategory(*categoryId); err != nil {
+			return err
+		}
+	}
+	return models.SetRoomCategory(roomId, categoryId)
+}
+
+func validateCategoryName(name string) (string, cerrors.ChocolateError) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 32 {
+		return "", cerrors.RequestError{
+			ID:      cerrors.RequestInvalidCategoryName,
+			Message: "category name should be 1 to 32 characters",
+		}
+	}
+	return name, nil
+}
+
+func ListCategories() ([]*models.Category, cerrors.ChocolateError) {
+	return models.ListCategories()
+}
+
+func CreateCategory(name string) (*models.Category, cerrors.ChocolateError) {
+	name, err := validateCategoryName(name)
+	if err != nil {
+		return nil, err
+	}
+	return models.CreateCategory(name)
+}
+
+func RenameCategory(id uint, name string) cerrors.ChocolateError {
+	name, err := validateCategoryName(name)
+	if err != nil {
+		return err
+	}
+	return models.RenameCategory(id, name)
+}
+
+func DeleteCategory(id uint) cerrors.ChocolateError {
+	return models.DeleteCategory(id)
+}
+
+func ListPopularTags(limit uint) ([]*models.TagCount, cerrors.ChocolateError) {
+	return models.ListPopularTags(limit)
+}
+
+// ListDirectoryRooms searches rooms listed publicly, an
+// invalid tag matches nothing rather than erroring.
+func ListDirectoryRooms(filter *models.DirectoryFilter, limit uint, page uint) (uint, []*models.Room, cerrors.ChocolateError) {
+	if filter.Tag != nil {
+		tags, err := normalizeTags([]string{*filter.Tag})
+		if err != nil || len(tags) == 0 {
+			return 0, []*models.Room{}, nil
+		}
+		filter.Tag = &tags[0]
+	}
+	filter.Query = strings.TrimSpace(filter.Query)
+	if filter.Sort == "" && filter.Query != "" {
+		filter.Sort = models.DirectorySortRelevance
+	}
+	return models.ListDirectoryRooms(filter, limit, page)
+}
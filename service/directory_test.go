@@ -0,0 +1,31 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{" #Speedrun ", "speedrun", "", "日本語", "co-op"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, []string{"speedrun", "日本語", "co-op"}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	if _, err := normalizeTags([]string{"two words"}); err == nil {
+		t.Fatal("tags with spaces should be rejected")
+	}
+	if _, err := normalizeTags([]string{strings.Repeat("a", 25)}); err == nil {
+		t.Fatal("long tags should be rejected")
+	}
+	many := []string{}
+	for i := 0; i <= maxRoomTags; i++ {
+		many = append(many, strings.Repeat("a", i+1))
+	}
+	if _, err := normalizeTags(many); err == nil {
+		t.Fatal("too many tags should be rejected")
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/forward"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultMaxForwardDestinations = 5
+
+func checkForwardDestination(name string, rawUrl string, key string) cerrors.ChocolateError {
+	if len(name) > 32 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestInvalidForwardDestination,
+			Message: "name is too long",
+		}
+	}
+	if len(rawUrl) > 256 || len(key) > 256 {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestInvalidForwardDestination,
+			Message: "url or key is too long",
+		}
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "rtmp" && u.Scheme != "rtmps") || u.Host == "" {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestInvalidForwardDestination,
+			Message: "only rtmp:// or rtmps:// urls are supported",
+		}
+	}
+	return nil
+}
+
+// a room is considered publishing only when
+// srs has accepted the stream.
+func isRoomPublishing(room *models.Room) bool {
+	return room.Status == models.RoomStatusStreaming && room.SrsClientID != nil
+}
+
+func ListRoomForwardDestinations(roomId uint) ([]*models.ForwardDestination, cerrors.ChocolateError) {
+	return models.ListForwardDestinations(roomId, false)
+}
+
+func GetForwardDestinationStatus(roomId uint, destId uint) forward.DestinationStatus {
+	return forward.GetStatus(roomId, destId)
+}
+
+func CreateRoomForwardDestination(room *models.Room, name string, url string, key string, enabled bool) (*models.ForwardDestination, cerrors.ChocolateError) {
+	if err := checkForwardDestination(name, url, key); err != nil {
+		return nil, err
+	}
+
+	max := common.Config.Forward.MaxPerRoom
+	if max == 0 {
+		max = defaultMaxForwardDestinations
+	}
+	count, err := models.CountForwardDestinations(room.ID)
+	if err != nil {
+		return nil, err
+	} else if count >= max {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestForwardDestinationCountReachedMax,
+			Message: "forward destinations reached max",
+		}
+	}
+
+	dest := &models.ForwardDestination{
+		RoomID:  room.ID,
+		Name:    name,
+		Url:     url,
+		Key:     key,
+		Enabled: enabled,
+	}
+	if err := models.CreateForwardDestination(dest); err != nil {
+		return nil, err
+	}
+
+	if dest.Enabled && isRoomPublishing(room) {
+		forward.Start(room.ID, dest.ID, roomSourceUrl(room), dest.GetTargetUrl())
+	}
+	return dest, nil
+}
+
+// nil fields are left unchanged.
+func UpdateRoomForwardDestination(room *models.Room, id uint, name *string, url *string, key *string, enabled *bool) (*models.ForwardDestination, cerrors.ChocolateError) {
+	dest, err := models.GetForwardDestination(room.ID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != nil {
+		dest.Name = *name
+	}
+	if url != nil {
+		dest.Url = *url
+	}
+	if key != nil {
+		dest.Key = *key
+	}
+	if enabled != nil {
+		dest.Enabled = *enabled
+	}
+
+	if err := checkForwardDestination(dest.Name, dest.Url, dest.Key); err != nil {
+		return nil, err
+	}
+	if err := models.UpdateForwardDestination(dest); err != nil {
+		return nil, err
+	}
+
+	if isRoomPublishing(room) {
+		if dest.Enabled {
+			forward.Start(room.ID, dest.ID, roomSourceUrl(room), dest.GetTargetUrl())
+		} else {
+			forward.Stop(room.ID, dest.ID)
+		}
+	}
+	return dest, nil
+}
+
+func DeleteRoomForwardDestination(roomId uint, id uint) cerrors.ChocolateError {
+	err := models.DeleteForwardDestination(roomId, id)
+	if err != nil {
+		return err
+	}
+	forward.Stop(roomId, id)
+	return nil
+}
+
+// StartRoomForwarding starts relays to every enabled
+// destination of the room, errors are only logged
+// since forwarding must never block publishing.
+func StartRoomForwarding(roomId uint) {
+	room, err := models.GetRoomByID(roomId, []string{})
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", roomId).Error("error looking up room for forwarding")
+		return
+	}
+	dests, err := models.ListForwardDestinations(roomId, true)
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", roomId).Error("error listing forward destinations")
+		return
+	}
+	for _, dest := range dests {
+		forward.Start(roomId, dest.ID, roomSourceUrl(room), dest.GetTargetUrl())
+	}
+}
+
+// relays pull the stream like players do, the param
+// tells on_play and on_stop they are not viewers.
+const forwardRelayParam = "relay"
+
+func forwardRelayPayload(roomId uint) string {
+	return fmt.Sprintf("fwd=%d", roomId)
+}
+
+// the stream is pulled from the node serving the room.
+func roomSourceUrl(room *models.Room) string {
+	token := common.CreateSignedToken(forwardRelayPayload(room.ID))
+	return GetRoomSrsServer(room).RtmpUrl(fmt.Sprintf("/live/%d?%s=%s", room.ID, forwardRelayParam, token))
+}
+
+// IsForwardRelay tells whether the params of a play
+// callback are of a relay of the room, the marker is
+// signed so viewers can not skip being counted.
+func IsForwardRelay(queries url.Values, roomId uint) bool {
+	payload, ok := common.VerifySignedToken(queries.Get(forwardRelayParam))
+	return ok && payload == forwardRelayPayload(roomId)
+}
+
+func StopRoomForwarding(roomId uint) {
+	forward.StopRoom(roomId)
+}
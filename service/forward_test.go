@@ -0,0 +1,26 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/sheey11/chocolate/common"
+)
+
+func TestIsForwardRelay(t *testing.T) {
+	queries := url.Values{}
+	queries.Set(forwardRelayParam, common.CreateSignedToken(forwardRelayPayload(3)))
+	if !IsForwardRelay(queries, 3) {
+		t.Fatal("relays of the room should be told apart")
+	}
+	if IsForwardRelay(queries, 4) {
+		t.Fatal("relays of other rooms should not be accepted")
+	}
+	queries.Set(forwardRelayParam, "fwd=3")
+	if IsForwardRelay(queries, 3) {
+		t.Fatal("unsigned markers should not be accepted")
+	}
+	if IsForwardRelay(url.Values{}, 3) {
+		t.Fatal("players should not be taken as relays")
+	}
+}
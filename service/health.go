@@ -0,0 +1,112 @@
+package service
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/srs"
+)
+
+// the collector samples every `srs.stats.collect-interval`
+// seconds, 20 samples are 10 minutes by default.
+const streamHealthSeriesLength = 20
+
+// StreamHealth is the ingest health of a room at a
+// sample time.
+//
+// The keyframe interval is not part of it: srs reports
+// neither the gop nor keyframe counts, and frame counts
+// do not tell keyframes apart. Telling it would take
+// decoding the stream, which the health endpoint does
+// not do.
+type StreamHealth struct {
+	Time         time.Time `json:"time"`
+	VideoCodec   string    `json:"video_codec"`
+	VideoProfile string    `json:"video_profile"`
+	Width        uint      `json:"width"`
+	Height       uint      `json:"height"`
+	// derived from the frames between two samples, nil
+	// for the first sample of a publish.
+	FPS             *float64 `json:"fps"`
+	AudioCodec      string   `json:"audio_codec"`
+	AudioSampleRate uint     `json:"audio_sample_rate"`
+	AudioChannels   uint     `json:"audio_channels"`
+	KbpsIn          uint     `json:"kbps_in"`
+	KbpsOut         uint     `json:"kbps_out"`
+	Clients         uint     `json:"clients"`
+	// seconds since the current publish started, samples
+	// of earlier publishes are 0.
+	PublishDuration int64 `json:"publish_duration"`
+}
+
+func findRoomStream(roomId uint, streams *srs.Streams) *srs.Stream {
+	if streams == nil {
+		return nil
+	}
+	name := strconv.FormatUint(uint64(roomId), 10)
+	for i := range streams.StreamList {
+		stream := &streams.StreamList[i]
+		if stream.Name == name && stream.App == "live" && stream.Publish.Active {
+			return stream
+		}
+	}
+	return nil
+}
+
+// deriveStreamHealth builds the health series of a room
+// from the collected samples, oldest first. Samples the
+// room is not publishing in are skipped. `started` is
+// when the current publish started, see publishStartedAt.
+func deriveStreamHealth(roomId uint, samples []*srs.Streams, started time.Time) []StreamHealth {
+	series := []StreamHealth{}
+	var prev *srs.Stream
+	var prevTime time.Time
+	for _, sample := range samples {
+		stream := findRoomStream(roomId, sample)
+		if stream == nil {
+			prev = nil
+			continue
+		}
+
+		t := time.Unix(int64(sample.SampleTime), 0)
+		health := StreamHealth{
+			Time:            t,
+			VideoCodec:      stream.Video.Codec,
+			VideoProfile:    stream.Video.Profile,
+			Width:           stream.Video.Width,
+			Height:          stream.Video.Height,
+			AudioCodec:      stream.Audio.Codec,
+			AudioSampleRate: stream.Audio.SampleRate,
+			AudioChannels:   stream.Audio.Channel,
+			KbpsIn:          stream.Stat.Recv30sKBytes,
+			KbpsOut:         stream.Stat.Send30sKBytes,
+			Clients:         stream.ClientCount,
+		}
+		if !started.IsZero() {
+			health.PublishDuration = lo.Max([]int64{0, int64(t.Sub(started).Seconds())})
+		}
+		// a republish gets a new stream id and resets frames.
+		if prev != nil && prev.ID == stream.ID && stream.FrameCount >= prev.FrameCount && t.After(prevTime) {
+			fps := float64(stream.FrameCount-prev.FrameCount) / t.Sub(prevTime).Seconds()
+			health.FPS = &fps
+		}
+
+		series = append(series, health)
+		prev = stream
+		prevTime = t
+	}
+
+	if len(series) > streamHealthSeriesLength {
+		series = series[len(series)-streamHealthSeriesLength:]
+	}
+	return series
+}
+
+// GetRoomStreamHealth returns the health series of the
+// room on its node, oldest first, empty if the room is
+// not publishing.
+func GetRoomStreamHealth(room *models.Room) []StreamHealth {
+	return deriveStreamHealth(room.ID, srs.GetStreamsHistory(lo.FromPtr(room.SrsNode)), publishStartedAt(room))
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sheey11/chocolate/srs"
+)
+
+func newStreamsSample(sampleTime uint, streams ...srs.Stream) *srs.Streams {
+	return &srs.Streams{SampleTime: sampleTime, StreamList: streams}
+}
+
+func newRoomStream(id string, frames uint) srs.Stream {
+	// live_ms is when srs dumps the stats, it must not be
+	// taken as the publish start.
+	stream := srs.Stream{ID: id, Name: "7", App: "live", FrameCount: frames, LiveTimestamp: 1000 * 1000}
+	stream.Publish.Active = true
+	stream.Video.Width = 1920
+	stream.Stat.Recv30sKBytes = 4000
+	stream.Stat.Send30sKBytes = 12000
+	return stream
+}
+
+func TestDeriveStreamHealth(t *testing.T) {
+	other := newRoomStream("st-other", 10)
+	other.Name = "8"
+
+	samples := []*srs.Streams{
+		newStreamsSample(1030, newRoomStream("st1", 0), other),
+		newStreamsSample(1060, newRoomStream("st1", 900)),
+		// not publishing
+		newStreamsSample(1090),
+		// republished
+		newStreamsSample(1120, newRoomStream("st2", 30)),
+	}
+
+	// the current publish started 10 seconds before the
+	// first sample.
+	series := deriveStreamHealth(7, samples, time.Unix(1020, 0))
+	if len(series) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(series))
+	}
+	if series[0].FPS != nil || series[2].FPS != nil {
+		t.Fatal("fps of first sample of a publish should be nil")
+	}
+	if series[1].FPS == nil || *series[1].FPS != 30 {
+		t.Fatalf("expected 30 fps, got %v", series[1].FPS)
+	}
+	if series[0].PublishDuration != 10 || series[2].PublishDuration != 100 {
+		t.Fatalf("publish durations should count from the publish start, got %d and %d", series[0].PublishDuration, series[2].PublishDuration)
+	}
+	if series[1].PublishDuration != 40 || series[1].KbpsIn != 4000 || series[1].KbpsOut != 12000 || series[1].Width != 1920 {
+		t.Fatalf("unexpected health %+v", series[1])
+	}
+
+	if series := deriveStreamHealth(7, samples, time.Time{}); series[1].PublishDuration != 0 {
+		t.Fatal("publish duration should be unknown without a publish start")
+	}
+	if len(deriveStreamHealth(9, samples, time.Unix(1020, 0))) != 0 {
+		t.Fatal("expected no points for a room not publishing")
+	}
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxInvitesPerRoom = 20
+	maxInviteLifetime = 90 * 24 * time.Hour
+	// a year, in minutes.
+	maxInviteAccessDuration = 365 * 24 * 60
+)
+
+type InviteData struct {
+	// 0 for unlimited.
+	MaxUses   uint      `json:"max_uses"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// in minutes.
+	AccessDuration uint    `json:"access_duration"`
+	Label          *string `json:"label"`
+	AllowGuests    bool    `json:"allow_guests"`
+}
+
+func (d *InviteData) validate(creator *models.User, now time.Time) cerrors.ChocolateError {
+	invalid := func(message string) cerrors.ChocolateError {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestInvalidRoomInvite,
+			Message: message,
+		}
+	}
+
+	if !d.ExpiresAt.After(now) || d.ExpiresAt.Sub(now) > maxInviteLifetime {
+		return invalid("invite should expire in 90 days")
+	}
+	if d.AccessDuration == 0 || d.AccessDuration > maxInviteAccessDuration {
+		return invalid("access duration should be 1 minute to a year")
+	}
+	if d.Label != nil {
+		label := strings.TrimSpace(*d.Label)
+		if label == "" {
+			d.Label = nil
+		} else if !creator.Role.AbilityManageAccount {
+			// labels are shared by rooms, assigning one
+			// may let the user in other rooms as well.
+			return cerrors.RequestError{
+				ID:      cerrors.RequestPermissionDenied,
+				Message: "only account managers can create invites assigning labels",
+			}
+		} else if len(label) > 64 {
+			return invalid("label should be at most 64 characters")
+		} else {
+			d.Label = &label
+		}
+	}
+	return nil
+}
+
+func CreateRoomInvite(room *models.Room, creator *models.User, data *InviteData) (*models.RoomInvite, cerrors.ChocolateError) {
+	if room.PermissionType != models.RoomPermissionWhitelist {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidRoomInvite,
+			Message: "invites are only for whitelist rooms",
+		}
+	}
+	if err := data.validate(creator, time.Now()); err != nil {
+		return nil, err
+	}
+
+	invites, err := models.ListRoomInvites(room.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(invites) >= maxInvitesPerRoom {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestRoomInviteCountReachedMax,
+			Message: "invite count reached max, revoke some first",
+		}
+	}
+
+	invite := &models.RoomInvite{
+		RoomID:         room.ID,
+		CreatorID:      creator.ID,
+		Label:          data.Label,
+		MaxUses:        data.MaxUses,
+		ExpiresAt:      data.ExpiresAt,
+		AccessDuration: data.AccessDuration,
+		AllowGuests:    data.AllowGuests,
+	}
+	if err := models.CreateRoomInvite(invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+func ListRoomInvites(roomId uint) ([]*models.RoomInvite, cerrors.ChocolateError) {
+	return models.ListRoomInvites(roomId)
+}
+
+func RevokeRoomInvite(roomId uint, id uint) cerrors.ChocolateError {
+	return models.DeleteRoomInvite(roomId, id)
+}
+
+// CreateInviteToken issues the token shared in invite
+// links, revoking is done by deleting the invite.
+func CreateInviteToken(invite *models.RoomInvite) string {
+	return common.CreateSignedToken("inv=" + strconv.FormatUint(uint64(invite.ID), 10))
+}
+
+func verifyInviteToken(token string) (uint, cerrors.ChocolateError) {
+	payload, ok := common.VerifySignedToken(token)
+	if ok && strings.HasPrefix(payload, "inv=") {
+		id, err := strconv.ParseUint(strings.TrimPrefix(payload, "inv="), 10, 64)
+		if err == nil {
+			return uint(id), nil
+		}
+	}
+	return 0, cerrors.RequestError{
+		ID:      cerrors.RequestRoomInviteNotFound,
+		Message: "invite not found or revoked",
+	}
+}
+
+type InviteInfo struct {
+	ID             uint      `json:"id"`
+	Token          string    `json:"token"`
+	Label          *string   `json:"label"`
+	MaxUses        uint      `json:"max_uses"`
+	Uses           uint      `json:"uses"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	AccessDuration uint      `json:"access_duration"`
+	AllowGuests    bool      `json:"allow_guests"`
+	Usable         bool      `json:"usable"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func NewInviteInfo(invite *models.RoomInvite) InviteInfo {
+	return InviteInfo{
+		ID:             invite.ID,
+		Token:          CreateInviteToken(invite),
+		Label:          invite.Label,
+		MaxUses:        invite.MaxUses,
+		Uses:           invite.Uses,
+		ExpiresAt:      invite.ExpiresAt,
+		AccessDuration: invite.AccessDuration,
+		AllowGuests:    invite.AllowGuests,
+		Usable:         invite.Usable(time.Now()),
+		CreatedAt:      invite.CreatedAt,
+	}
+}
+
+// LookupInvite returns the invite and its room, for
+// showing the invite before redeeming. Invites of rooms
+// invisible to the user are treated as not found.
+func LookupInvite(token string, user *models.User) (*models.RoomInvite, *models.Room, cerrors.ChocolateError) {
+	id, err := verifyInviteToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	invite, err := models.GetRoomInvite(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	room, err := GetRoomByID(invite.RoomID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !IsRoomVisibleToUser(room, user) {
+		return nil, nil, cerrors.RequestError{
+			ID:      cerrors.RequestRoomInviteNotFound,
+			Message: "invite not found or revoked",
+		}
+	}
+	return invite, room, nil
+}
+
+func inviteAccessUntil(invite *models.RoomInvite, now time.Time) time.Time {
+	return now.Add(time.Duration(invite.AccessDuration) * time.Minute)
+}
+
+// RedeemInvite grants the user access to the room of
+// the invite, or a guest playback token if `user` is
+// nil and the invite allows guests.
+func RedeemInvite(token string, user *models.User) (*models.Room, time.Time, string, cerrors.ChocolateError) {
+	invite, room, err := LookupInvite(token, user)
+	if err != nil {
+		return nil, time.Time{}, "", err
+	}
+	if room.PermissionType != models.RoomPermissionWhitelist {
+		return nil, time.Time{}, "", cerrors.RequestError{
+			ID:      cerrors.RequestInvalidRoomInvite,
+			Message: "the room is not whitelisting anymore",
+		}
+	}
+	if user == nil && !invite.AllowGuests {
+		return nil, time.Time{}, "", cerrors.RequestError{
+			ID:      cerrors.RequestNotLoggedIn,
+			Message: "login required to redeem the invite",
+		}
+	}
+
+	now := time.Now()
+	until := inviteAccessUntil(invite, now)
+	_, err = models.RedeemRoomInvite(invite.ID, now, func(tx *gorm.DB, invite *models.RoomInvite) cerrors.ChocolateError {
+		if user == nil {
+			return nil
+		}
+		meta := models.PermissionItemMeta{
+			ExpiresAt: &until,
+			Reason:    fmt.Sprintf("redeemed invite #%d", invite.ID),
+			CreatedBy: &invite.CreatorID,
+		}
+		if err := models.GrantTemporaryRoomAccess(tx, room.ID, user.ID, meta); err != nil {
+			return err
+		}
+		if invite.Label != nil {
+			return models.AssignUserLabel(tx, user.ID, *invite.Label)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, time.Time{}, "", err
+	}
+
+	var guestToken string
+	if user != nil {
+		decisionCache.ForgetUser(user.ID)
+	}
+	if user == nil {
+		guestToken = createGuestPlaybackToken(room.ID, invite.ID, until)
+	}
+	return room, until, guestToken, nil
+}
+
+func createGuestPlaybackToken(roomId uint, inviteId uint, until time.Time) string {
+	return common.CreateSignedToken(fmt.Sprintf("g=%d,i=%d,e=%d", roomId, inviteId, until.Unix()))
+}
+
+func parseGuestPlaybackToken(token string) (roomId uint, inviteId uint, until time.Time, ok bool) {
+	payload, ok := common.VerifySignedToken(token)
+	if !ok {
+		return 0, 0, time.Time{}, false
+	}
+	var expire int64
+	_, err := fmt.Sscanf(payload, "g=%d,i=%d,e=%d", &roomId, &inviteId, &expire)
+	if err != nil {
+		return 0, 0, time.Time{}, false
+	}
+	return roomId, inviteId, time.Unix(expire, 0), true
+}
+
+// VerifyGuestPlaybackToken checks the guest token
+// issued by redeeming an invite, it stops working once
+// the invite is revoked.
+func VerifyGuestPlaybackToken(token string, room *models.Room) bool {
+	if token == "" || !IsRoomVisibleToUser(room, nil) {
+		return false
+	}
+	roomId, inviteId, until, ok := parseGuestPlaybackToken(token)
+	if !ok || roomId != room.ID || time.Now().After(until) {
+		return false
+	}
+	invite, err := models.GetRoomInvite(inviteId)
+	return err == nil && invite.RoomID == room.ID
+}
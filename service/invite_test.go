@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/models"
+)
+
+func TestInviteDataValidation(t *testing.T) {
+	now := time.Now()
+	owner := &models.User{}
+	admin := &models.User{Role: models.Role{AbilityManageAccount: true}}
+
+	data := InviteData{ExpiresAt: now.Add(time.Hour), AccessDuration: 60}
+	if err := data.validate(owner, now); err != nil {
+		t.Fatalf("valid invite rejected: %v", err)
+	}
+
+	data.ExpiresAt = now.Add(maxInviteLifetime + time.Hour)
+	if err := data.validate(owner, now); err == nil {
+		t.Fatal("invites lasting too long should be rejected")
+	}
+
+	data = InviteData{ExpiresAt: now.Add(time.Hour), AccessDuration: 60, Label: lo.ToPtr(" vip ")}
+	if err := data.validate(owner, now); err == nil {
+		t.Fatal("only account managers should assign labels")
+	}
+	if err := data.validate(admin, now); err != nil || *data.Label != "vip" {
+		t.Fatalf("label should be trimmed, got %v, %v", data.Label, err)
+	}
+}
+
+func TestGuestPlaybackToken(t *testing.T) {
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := createGuestPlaybackToken(3, 7, until)
+
+	roomId, inviteId, expire, ok := parseGuestPlaybackToken(token)
+	if !ok || roomId != 3 || inviteId != 7 || !expire.Equal(until) {
+		t.Fatalf("unexpected token content: %d %d %v %v", roomId, inviteId, expire, ok)
+	}
+	if _, _, _, ok := parseGuestPlaybackToken(token[:len(token)-2]); ok {
+		t.Fatal("tampered token should not be accepted")
+	}
+}
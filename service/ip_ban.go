@@ -0,0 +1,243 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sirupsen/logrus"
+)
+
+// bans are checked on every playback request, they are
+// kept in memory and reloaded on changes, and every
+// interval to pick up changes of other instances. Hits
+// are written back at the same time.
+const ipBanRefreshInterval = time.Minute
+
+type ipBanEntry struct {
+	id uint
+	// nil for global bans.
+	roomId    *uint
+	network   *net.IPNet
+	expiresAt *time.Time
+	reason    string
+}
+
+type ipBanList struct {
+	mu   sync.RWMutex
+	bans []*ipBanEntry
+
+	hitsMu sync.Mutex
+	hits   map[uint]uint64
+}
+
+var ipBans = &ipBanList{hits: map[uint]uint64{}}
+
+func newIPBanEntry(ban *models.IPBan) (*ipBanEntry, error) {
+	_, network, err := net.ParseCIDR(ban.CIDR)
+	if err != nil {
+		return nil, err
+	}
+	return &ipBanEntry{
+		id:        ban.ID,
+		roomId:    ban.RoomID,
+		network:   network,
+		expiresAt: ban.ExpiresAt,
+		reason:    ban.Reason,
+	}, nil
+}
+
+func (l *ipBanList) set(bans []*models.IPBan) {
+	entries := make([]*ipBanEntry, 0, len(bans))
+	for _, ban := range bans {
+		entry, err := newIPBanEntry(ban)
+		if err != nil {
+			logrus.WithError(err).WithField("ban_id", ban.ID).Error("error parsing saved ip ban")
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bans = entries
+}
+
+// match returns the ban on `ip` in the room, or the
+// global one, and counts the hit.
+func (l *ipBanList) match(ip net.IP, roomId uint, now time.Time) *ipBanEntry {
+	l.mu.RLock()
+	var matched *ipBanEntry
+	for _, ban := range l.bans {
+		if ban.expiresAt != nil && !now.Before(*ban.expiresAt) {
+			continue
+		}
+		if ban.roomId != nil && *ban.roomId != roomId {
+			continue
+		}
+		if ban.network.Contains(ip) {
+			matched = ban
+			break
+		}
+	}
+	l.mu.RUnlock()
+
+	if matched != nil {
+		l.hitsMu.Lock()
+		l.hits[matched.id]++
+		l.hitsMu.Unlock()
+	}
+	return matched
+}
+
+// takeHits returns the hits counted so far and resets
+// the counters.
+func (l *ipBanList) takeHits() map[uint]uint64 {
+	l.hitsMu.Lock()
+	defer l.hitsMu.Unlock()
+	hits := l.hits
+	l.hits = map[uint]uint64{}
+	return hits
+}
+
+func reloadIPBans() {
+	bans, err := models.ListIPBans(nil, true)
+	if err != nil {
+		logrus.WithError(err).Error("error loading ip bans")
+		return
+	}
+	ipBans.set(bans)
+}
+
+func flushIPBanHits() {
+	hits := ipBans.takeHits()
+	if len(hits) == 0 {
+		return
+	}
+	if err := models.AddIPBanHits(hits, time.Now()); err != nil {
+		logrus.WithError(err).Error("error saving ip ban hits")
+	}
+}
+
+// StartIPBanRefresher loads the bans right away, then
+// periodically.
+func StartIPBanRefresher() {
+	reloadIPBans()
+	go func() {
+		ticker := time.NewTicker(ipBanRefreshInterval)
+		for range ticker.C {
+			flushIPBanHits()
+			reloadIPBans()
+		}
+	}()
+}
+
+// CheckIPBan tells whether the client is banned from the
+// room, `roomId` 0 checks global bans only. Unparsable
+// addresses are never banned.
+func CheckIPBan(ip string, roomId uint) cerrors.ChocolateError {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil
+	}
+	ban := ipBans.match(parsed, roomId, time.Now())
+	if ban == nil {
+		return nil
+	}
+
+	message := "your network has been banned"
+	if ban.expiresAt != nil {
+		message += fmt.Sprintf(" until %s", ban.expiresAt.Format(time.RFC3339))
+	}
+	if ban.reason != "" {
+		message += ": " + ban.reason
+	}
+	return cerrors.RequestError{
+		ID:      cerrors.RequestIPBanned,
+		Message: message,
+	}
+}
+
+type IPBanData struct {
+	// an address, or a network in cidr notation.
+	CIDR string `json:"cidr"`
+	// nil for a global ban.
+	RoomID *uint `json:"room_id"`
+	PermissionItemData
+}
+
+// parseBanNetwork normalizes single addresses to /32 or
+// /128 networks.
+func parseBanNetwork(cidr string) (*net.IPNet, bool) {
+	cidr = strings.TrimSpace(cidr)
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, false
+		}
+		if ip.To4() != nil {
+			return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}, true
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, true
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, false
+	}
+	if ones, _ := network.Mask.Size(); ones == 0 {
+		// would ban everyone.
+		return nil, false
+	}
+	return network, true
+}
+
+func CreateIPBan(creator *models.User, data *IPBanData) (*models.IPBan, cerrors.ChocolateError) {
+	network, ok := parseBanNetwork(data.CIDR)
+	if !ok {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidIPBan,
+			Message: "invalid address or network",
+		}
+	}
+	meta, err := data.meta(creator, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if data.RoomID != nil {
+		if _, err := GetRoomByID(*data.RoomID); err != nil {
+			return nil, err
+		}
+	}
+
+	ban := &models.IPBan{
+		RoomID:    data.RoomID,
+		CIDR:      network.String(),
+		Reason:    meta.Reason,
+		ExpiresAt: meta.ExpiresAt,
+		CreatedBy: meta.CreatedBy,
+	}
+	if err := models.CreateIPBan(ban); err != nil {
+		return nil, err
+	}
+	reloadIPBans()
+	return ban, nil
+}
+
+// ListIPBans lists bans of the room, global ones if
+// `roomId` is nil, or all if `all` is set. Hits not yet
+// saved are not included.
+func ListIPBans(roomId *uint, all bool) ([]*models.IPBan, cerrors.ChocolateError) {
+	return models.ListIPBans(roomId, all)
+}
+
+func DeleteIPBan(id uint) cerrors.ChocolateError {
+	if err := models.DeleteIPBan(id); err != nil {
+		return err
+	}
+	reloadIPBans()
+	return nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/models"
+)
+
+func TestParseBanNetwork(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.1":       "10.0.0.1/32",
+		" 10.1.2.3/16 ":  "10.1.0.0/16",
+		"2001:db8::1":    "2001:db8::1/128",
+		"2001:db8::/32":  "2001:db8::/32",
+		"::ffff:1.2.3.4": "1.2.3.4/32",
+	}
+	for input, expect := range cases {
+		network, ok := parseBanNetwork(input)
+		if !ok || network.String() != expect {
+			t.Fatalf("%q: expecting %s, got %v", input, expect, network)
+		}
+	}
+	for _, input := range []string{"", "10.0.0", "0.0.0.0/0", "::/0", "10.0.0.1/33"} {
+		if _, ok := parseBanNetwork(input); ok {
+			t.Fatalf("%q should be rejected", input)
+		}
+	}
+}
+
+func TestIPBanMatch(t *testing.T) {
+	now := time.Now()
+	list := &ipBanList{hits: map[uint]uint64{}}
+	list.set([]*models.IPBan{
+		{ID: 1, CIDR: "10.0.0.0/8"},
+		{ID: 2, CIDR: "192.168.1.0/24", RoomID: lo.ToPtr(uint(3))},
+		{ID: 3, CIDR: "172.16.0.1/32", ExpiresAt: lo.ToPtr(now.Add(-time.Minute))},
+	})
+
+	if ban := list.match(net.ParseIP("10.2.3.4"), 5, now); ban == nil || ban.id != 1 {
+		t.Fatal("global bans should apply to every room")
+	}
+	if list.match(net.ParseIP("192.168.1.9"), 3, now) == nil {
+		t.Fatal("room bans should apply to the room")
+	}
+	if list.match(net.ParseIP("192.168.1.9"), 4, now) != nil || list.match(net.ParseIP("192.168.1.9"), 0, now) != nil {
+		t.Fatal("room bans should not apply to other rooms")
+	}
+	if list.match(net.ParseIP("172.16.0.1"), 3, now) != nil {
+		t.Fatal("expired bans should not apply")
+	}
+
+	hits := list.takeHits()
+	if hits[1] != 1 || hits[2] != 1 || len(list.takeHits()) != 0 {
+		t.Fatalf("unexpected hits %v", hits)
+	}
+}
@@ -23,10 +23,12 @@ func RecordUnpublishEvent(roomid uint, detail string) {
 	}
 }
 
-func RecordCutOffEvent(roomid uint, operator uint) {
+// operator is `SystemOperator` for automatic cut-offs.
+func RecordCutOffEvent(roomid uint, operator uint, reason string) {
 	detail, _ := json.Marshal(struct {
-		Operator uint `json:"operator"`
-	}{operator})
+		Operator uint   `json:"operator"`
+		Reason   string `json:"reason,omitempty"`
+	}{operator, reason})
 	err := models.RecordEventWithDetail(models.LogTypeCutOff, roomid, string(detail))
 	if err != nil {
 		logrus.WithError(err).Errorf("error when create cutoff record, room %v, operator %v", roomid, operator)
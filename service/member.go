@@ -0,0 +1,119 @@
+package service
+
+import (
+	"time"
+
+	"github.com/sheey11/chocolate/chat"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+)
+
+// GetRoomAbilities returns what the user can do to the
+// room, the owner and admins can do anything.
+func GetRoomAbilities(room *models.Room, user *models.User) (models.RoomAbilities, cerrors.ChocolateError) {
+	if user == nil {
+		return models.RoomAbilities{}, nil
+	}
+	if room.OwnerID == user.ID || user.Role.AbilityManageRoom {
+		return models.FullRoomAbilities, nil
+	}
+	member, err := models.GetRoomMember(room.ID, user.ID)
+	if err != nil || member == nil {
+		return models.RoomAbilities{}, err
+	}
+	if abilities := member.Role.Abilities(); abilities != nil {
+		return *abilities, nil
+	}
+	return models.RoomAbilities{}, nil
+}
+
+// GetRoomMemberRole returns nil if the user is not a
+// member of the room.
+func GetRoomMemberRole(roomId uint, uid uint) (*models.RoomMemberRole, cerrors.ChocolateError) {
+	member, err := models.GetRoomMember(roomId, uid)
+	if err != nil || member == nil {
+		return nil, err
+	}
+	return &member.Role, nil
+}
+
+func ListRoomMembers(roomId uint) ([]*models.RoomMember, cerrors.ChocolateError) {
+	return models.ListRoomMembers(roomId)
+}
+
+func SetRoomMember(room *models.Room, username string, role models.RoomMemberRole) (*models.User, cerrors.ChocolateError) {
+	if role.Abilities() == nil {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestUnknownRoomMemberRole,
+			Message: "role should be moderator or co-host",
+		}
+	}
+	user, err := models.GetUserByName(username, nil)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == room.OwnerID {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidRoomMember,
+			Message: "the owner can not be a member",
+		}
+	}
+	if err := models.SetRoomMember(room.ID, user.ID, role); err != nil {
+		return nil, err
+	}
+	// members are let in by the cached decisions.
+	decisionCache.ForgetRoom(room.ID)
+	return user, nil
+}
+
+func RemoveRoomMember(roomId uint, username string) cerrors.ChocolateError {
+	user, err := models.GetUserByName(username, nil)
+	if err != nil {
+		return err
+	}
+	if err := models.DeleteRoomMember(roomId, user.ID); err != nil {
+		return err
+	}
+	decisionCache.ForgetRoom(roomId)
+	return nil
+}
+
+const maxChatMuteMinutes = 7 * 24 * 60
+
+// MuteChatUser mutes the user in the room chat, those
+// able to moderate the chat can not be muted.
+func MuteChatUser(roomId uint, uid uint, minutes uint) cerrors.ChocolateError {
+	if minutes == 0 || minutes > maxChatMuteMinutes {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestInvalidChatMute,
+			Message: "mute should last 1 minute to 7 days",
+		}
+	}
+	room, err := GetRoomByID(roomId)
+	if err != nil {
+		return err
+	}
+	user := models.GetUserByID(uid)
+	if user == nil {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestUserNotFound,
+			Message: "user not found",
+		}
+	}
+	abilities, err := GetRoomAbilities(room, user)
+	if err != nil {
+		return err
+	}
+	if abilities.AbilityModerateChat {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestInvalidChatMute,
+			Message: "moderators can not be muted",
+		}
+	}
+	chat.Mute(room.ID, uid, time.Now().Add(time.Duration(minutes)*time.Minute))
+	return nil
+}
+
+func UnmuteChatUser(roomId uint, uid uint) {
+	chat.Unmute(roomId, uid)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sheey11/chocolate/models"
+	"github.com/sirupsen/logrus"
+)
+
+// expired items are already ignored when checking, the
+// cleanup only keeps the table small.
+const permissionItemCleanupInterval = 10 * time.Minute
+
+func cleanExpiredPermissionItems() {
+	count, err := models.DeleteExpiredPermissionItems()
+	if err != nil {
+		logrus.WithError(err).Error("error cleaning expired permission items")
+	} else if count != 0 {
+		logrus.WithField("count", count).Info("expired permission items cleaned")
+	}
+
+	count, err = models.DeleteExpiredIPBans()
+	if err != nil {
+		logrus.WithError(err).Error("error cleaning expired ip bans")
+	} else if count != 0 {
+		logrus.WithField("count", count).Info("expired ip bans cleaned")
+	}
+}
+
+// StartPermissionItemJanitor deletes expired permission
+// items right away, then periodically.
+func StartPermissionItemJanitor() {
+	go func() {
+		cleanExpiredPermissionItems()
+		ticker := time.NewTicker(permissionItemCleanupInterval)
+		for range ticker.C {
+			cleanExpiredPermissionItems()
+		}
+	}()
+}
+
+// DescribeRoomBan tells the user why they can not enter
+// the room, and for how long if banned temporarily.
+func DescribeRoomBan(room *models.Room, user *models.User) string {
+	if user == nil || room.PermissionType != models.RoomPermissionBlacklist {
+		return "you have been banned from this room"
+	}
+	bans, err := models.ListUserBans(room, user)
+	if err != nil {
+		logrus.WithError(err).Error("error listing bans of user")
+	}
+	return formatBanMessage(bans, time.Now())
+}
+
+// formatBanMessage describes the ban lasting longest,
+// a permanent one lasts forever.
+func formatBanMessage(bans []*models.PermissionItem, now time.Time) string {
+	var longest *models.PermissionItem
+	for _, ban := range bans {
+		if longest == nil || ban.ExpiresAt == nil || (longest.ExpiresAt != nil && ban.ExpiresAt.After(*longest.ExpiresAt)) {
+			longest = ban
+		}
+		if longest.ExpiresAt == nil {
+			break
+		}
+	}
+
+	message := "you have been banned from this room"
+	if longest == nil {
+		return message
+	}
+	if longest.ExpiresAt != nil {
+		remaining := longest.ExpiresAt.Sub(now).Round(time.Minute)
+		if remaining < time.Minute {
+			remaining = time.Minute
+		}
+		message += fmt.Sprintf(" for another %s", remaining)
+	}
+	if longest.Reason != "" {
+		message += ": " + longest.Reason
+	}
+	return message
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sheey11/chocolate/common"
+	"github.com/sheey11/chocolate/models"
+)
+
+func init() {
+	common.HookPostConfigLoad(func(cfg *common.ChocolateConfig) {
+		if cfg.Permission.DecisionCacheTTL != 0 {
+			decisionCache.ttl = time.Second * time.Duration(cfg.Permission.DecisionCacheTTL)
+		}
+	})
+}
+
+// decisions are dropped whenever permission items, labels,
+// roles or the room permission change, the ttl only bounds
+// what is not tracked, like items expiring or the account
+// age and watch time in access policies.
+const (
+	defaultDecisionCacheTTL = 30 * time.Second
+	maxCachedDecisions      = 100000
+)
+
+type decisionKey struct {
+	roomId uint
+	// 0 for guests.
+	uid uint
+	// whether the room password is given.
+	unlocked bool
+}
+
+type cachedDecision struct {
+	allowed bool
+	expire  time.Time
+}
+
+// permissionDecisionCache keeps what decideRoomAccess
+// returned, so polling playlists does not query the
+// permission items over and over.
+type permissionDecisionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[decisionKey]cachedDecision
+
+	hits          uint64
+	misses        uint64
+	invalidations uint64
+}
+
+var decisionCache = newPermissionDecisionCache(defaultDecisionCacheTTL)
+
+func newPermissionDecisionCache(ttl time.Duration) *permissionDecisionCache {
+	return &permissionDecisionCache{
+		ttl:     ttl,
+		entries: map[decisionKey]cachedDecision{},
+	}
+}
+
+// Decide returns the cached decision, or calls `decide`
+// and caches the result.
+func (c *permissionDecisionCache) Decide(key decisionKey, now time.Time, decide func() bool) bool {
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expire) {
+		c.hits++
+		c.mu.Unlock()
+		return entry.allowed
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// deciding queries the database, do not hold the lock,
+	// a concurrent invalidation may be lost in the race,
+	// which the ttl bounds.
+	allowed := decide()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedDecisions {
+		c.purgeExpired(now)
+		if len(c.entries) >= maxCachedDecisions {
+			c.entries = map[decisionKey]cachedDecision{}
+		}
+	}
+	c.entries[key] = cachedDecision{allowed: allowed, expire: now.Add(c.ttl)}
+	return allowed
+}
+
+// must be called with lock held.
+func (c *permissionDecisionCache) purgeExpired(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.expire) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// forget drops the decisions matching `match`.
+func (c *permissionDecisionCache) forget(match func(key decisionKey) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidations++
+	for key := range c.entries {
+		if match(key) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *permissionDecisionCache) ForgetRoom(roomId uint) {
+	c.forget(func(key decisionKey) bool { return key.roomId == roomId })
+}
+
+func (c *permissionDecisionCache) ForgetUser(uid uint) {
+	c.forget(func(key decisionKey) bool { return key.uid == uid })
+}
+
+func (c *permissionDecisionCache) ForgetAll() {
+	c.forget(func(decisionKey) bool { return true })
+}
+
+type DecisionCacheStats struct {
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	Invalidations uint64  `json:"invalidations"`
+	Entries       int     `json:"entries"`
+	TTL           float64 `json:"ttl"`
+}
+
+func (c *permissionDecisionCache) Stats() DecisionCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return DecisionCacheStats{
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Invalidations: c.invalidations,
+		Entries:       len(c.entries),
+		TTL:           c.ttl.Seconds(),
+	}
+}
+
+// GetDecisionCacheStats reports the permission decision
+// cache metrics of this chocolate instance.
+func GetDecisionCacheStats() DecisionCacheStats {
+	return decisionCache.Stats()
+}
+
+// forgetUserByName is for changes addressing the user by
+// name, the cache is keyed by id.
+func forgetUserByName(username string) {
+	user, _ := models.GetUserByName(username, nil)
+	if user == nil {
+		// can not tell who, be safe.
+		decisionCache.ForgetAll()
+		return
+	}
+	decisionCache.ForgetUser(user.ID)
+}
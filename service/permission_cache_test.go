@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPermissionDecisionCache(t *testing.T) {
+	cache := newPermissionDecisionCache(time.Minute)
+	now := time.Now()
+	calls := 0
+	decide := func(allowed bool) func() bool {
+		return func() bool {
+			calls++
+			return allowed
+		}
+	}
+
+	if !cache.Decide(decisionKey{1, 2, false}, now, decide(true)) || !cache.Decide(decisionKey{1, 2, false}, now, decide(false)) || calls != 1 {
+		t.Fatal("the decision should be reused within the ttl")
+	}
+	if cache.Decide(decisionKey{1, 2, false}, now.Add(time.Minute), decide(false)) || calls != 2 {
+		t.Fatal("the decision should be made again after the ttl")
+	}
+
+	cache.Decide(decisionKey{1, 3, false}, now, decide(true))
+	cache.Decide(decisionKey{4, 2, false}, now, decide(true))
+	cache.ForgetRoom(1)
+	if cache.Decide(decisionKey{4, 2, false}, now, decide(false)) != true || calls != 4 {
+		t.Fatal("forgetting a room should keep the others")
+	}
+	cache.Decide(decisionKey{1, 3, false}, now, decide(true))
+	cache.ForgetUser(2)
+	cache.Decide(decisionKey{1, 3, false}, now, decide(false))
+	if cache.Decide(decisionKey{4, 2, false}, now, decide(false)) || calls != 6 {
+		t.Fatal("forgetting a user should drop the decisions of all rooms")
+	}
+	if stats := cache.Stats(); stats.Hits != 3 || stats.Invalidations != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/models"
+)
+
+func TestFormatBanMessage(t *testing.T) {
+	now := time.Now()
+	short := &models.PermissionItem{ExpiresAt: lo.ToPtr(now.Add(10 * time.Minute)), Reason: "spam"}
+	long := &models.PermissionItem{ExpiresAt: lo.ToPtr(now.Add(2 * time.Hour)), Reason: "flooding"}
+	forever := &models.PermissionItem{}
+
+	if m := formatBanMessage([]*models.PermissionItem{short, long}, now); m != "you have been banned from this room for another 2h0m0s: flooding" {
+		t.Fatalf("the longest ban should be described, got %q", m)
+	}
+	if m := formatBanMessage([]*models.PermissionItem{short, forever, long}, now); m != "you have been banned from this room" {
+		t.Fatalf("a permanent ban should win, got %q", m)
+	}
+}
+
+func TestPermissionItemMeta(t *testing.T) {
+	now := time.Now()
+	creator := &models.User{}
+	creator.ID = 5
+
+	meta, err := (&PermissionItemData{Reason: " spam ", Duration: 30}).meta(creator, now)
+	if err != nil || meta.Reason != "spam" || !meta.ExpiresAt.Equal(now.Add(30*time.Minute)) || *meta.CreatedBy != 5 {
+		t.Fatalf("unexpected meta %+v, %v", meta, err)
+	}
+	if meta, _ := (&PermissionItemData{}).meta(nil, now); meta.ExpiresAt != nil || meta.CreatedBy != nil {
+		t.Fatalf("items should be permanent by default, got %+v", meta)
+	}
+	if _, err := (&PermissionItemData{Duration: maxPermissionItemDuration + 1}).meta(creator, now); err == nil {
+		t.Fatal("too long duration should be rejected")
+	}
+}
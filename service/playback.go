@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	"github.com/sheey11/chocolate/models"
+)
+
+const (
+	defaultSegmentTokenTTL   = 120 * time.Second
+	defaultPlaybackTicketTTL = 6 * time.Hour
+)
+
+// SegmentToken authorizes fetching hls segments of a
+// room, it is issued along with every playlist after
+// the viewer has passed the permission check.
+type SegmentToken struct {
+	RoomID uint
+	UserID uint
+	Expire time.Time
+	// srs node serving the room when the token is
+	// issued, empty for the default node.
+	Node string
+}
+
+func segmentTokenTTL() time.Duration {
+	if ttl := common.Config.Playback.SegmentTokenTTL; ttl != 0 {
+		return time.Second * time.Duration(ttl)
+	}
+	return defaultSegmentTokenTTL
+}
+
+func CreateSegmentToken(room *models.Room, user *models.User) string {
+	var uid uint = 0
+	if user != nil {
+		uid = user.ID
+	}
+	expire := time.Now().Add(segmentTokenTTL())
+	payload := fmt.Sprintf("r=%d,u=%d,e=%d", room.ID, uid, expire.Unix())
+	if room.SrsNode != nil {
+		payload += ",n=" + *room.SrsNode
+	}
+	return common.CreateSignedToken(payload)
+}
+
+// VerifySegmentToken checks the signature, expiration
+// and whether the token is issued for the given room.
+func VerifySegmentToken(token string, roomId uint) (*SegmentToken, bool) {
+	payload, ok := common.VerifySignedToken(token)
+	if !ok {
+		return nil, false
+	}
+
+	var expire int64
+	t := SegmentToken{}
+	payload, t.Node, _ = strings.Cut(payload, ",n=")
+	_, err := fmt.Sscanf(payload, "r=%d,u=%d,e=%d", &t.RoomID, &t.UserID, &expire)
+	if err != nil {
+		return nil, false
+	}
+	t.Expire = time.Unix(expire, 0)
+
+	if t.RoomID != roomId || time.Now().After(t.Expire) {
+		return nil, false
+	}
+	return &t, true
+}
+
+func playbackTicketTTL() time.Duration {
+	if ttl := common.Config.Playback.TicketTTL; ttl != 0 {
+		return time.Second * time.Duration(ttl)
+	}
+	return defaultPlaybackTicketTTL
+}
+
+// CreatePlaybackTicket identifies the user to the playback
+// endpoints of the room, for players which can send
+// neither headers nor cookies. It only tells who the
+// viewer is, the permission is still checked on use.
+func CreatePlaybackTicket(room *models.Room, user *models.User, now time.Time) (string, time.Time) {
+	expire := now.Add(playbackTicketTTL())
+	return common.CreateSignedToken(fmt.Sprintf("t=%d,u=%d,e=%d", room.ID, user.ID, expire.Unix())), expire
+}
+
+// verifyPlaybackTicket returns the user id the ticket is
+// issued to.
+func verifyPlaybackTicket(ticket string, roomId uint, now time.Time) (uint, bool) {
+	payload, ok := common.VerifySignedToken(ticket)
+	if !ok {
+		return 0, false
+	}
+
+	var rid, uid uint
+	var expire int64
+	_, err := fmt.Sscanf(payload, "t=%d,u=%d,e=%d", &rid, &uid, &expire)
+	if err != nil || rid != roomId || now.After(time.Unix(expire, 0)) {
+		return 0, false
+	}
+	return uid, true
+}
+
+// GetPlaybackViewer identifies the viewer by the `ticket`
+// query, then the bearer token or session cookie. It
+// fails only if a ticket is given but invalid.
+func GetPlaybackViewer(c *gin.Context, room *models.Room) (*models.User, bool) {
+	ticket := c.Query("ticket")
+	if ticket == "" {
+		return TryGetUserFromContext(c), true
+	}
+	uid, ok := verifyPlaybackTicket(ticket, room.ID, time.Now())
+	if !ok {
+		return nil, false
+	}
+	user := models.GetUserByID(uid)
+	return user, user != nil
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+)
+
+// hls players poll the playlist every segment duration,
+// a session without polling for this long has ended.
+const HlsPlaybackSessionTTL = 30 * time.Second
+
+// sessions are tracked in memory, caps are enforced per
+// chocolate instance.
+type playbackSession struct {
+	roomId uint
+	// 0 for guests.
+	uid uint
+	// zero for sessions ended by EndPlaybackSession.
+	expire time.Time
+}
+
+// expiring is a session to drop at `expire`, unless it
+// has been refreshed or ended since.
+type expiring struct {
+	key    string
+	expire time.Time
+}
+
+// expiryQueue is a min-heap of expiring sessions.
+type expiryQueue []expiring
+
+func (q expiryQueue) Len() int            { return len(q) }
+func (q expiryQueue) Less(i, j int) bool  { return q[i].expire.Before(q[j].expire) }
+func (q expiryQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *expiryQueue) Push(x interface{}) { *q = append(*q, x.(expiring)) }
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// playbackSessionTracker keeps the counts per room and
+// user along with the sessions, so checks do not walk
+// through all of them.
+type playbackSessionTracker struct {
+	mu       sync.Mutex
+	sessions map[string]*playbackSession
+	// room id -> sessions
+	viewers map[uint]uint
+	// user id -> sessions, guests are not counted.
+	userSessions map[uint]uint
+	expiries     expiryQueue
+}
+
+var playbackSessions = newPlaybackSessionTracker()
+
+func newPlaybackSessionTracker() *playbackSessionTracker {
+	return &playbackSessionTracker{
+		sessions:     map[string]*playbackSession{},
+		viewers:      map[uint]uint{},
+		userSessions: map[uint]uint{},
+	}
+}
+
+// Must be called with lock held.
+func (t *playbackSessionTracker) add(key string, session *playbackSession) {
+	t.sessions[key] = session
+	t.viewers[session.roomId]++
+	if session.uid != 0 {
+		t.userSessions[session.uid]++
+	}
+	if !session.expire.IsZero() {
+		heap.Push(&t.expiries, expiring{key, session.expire})
+	}
+}
+
+// Must be called with lock held.
+func (t *playbackSessionTracker) remove(key string, session *playbackSession) {
+	delete(t.sessions, key)
+	if t.viewers[session.roomId]--; t.viewers[session.roomId] == 0 {
+		delete(t.viewers, session.roomId)
+	}
+	if session.uid != 0 {
+		if t.userSessions[session.uid]--; t.userSessions[session.uid] == 0 {
+			delete(t.userSessions, session.uid)
+		}
+	}
+}
+
+// expire drops the sessions expired by `now`. Must be
+// called with lock held.
+func (t *playbackSessionTracker) expire(now time.Time) {
+	for len(t.expiries) > 0 && !now.Before(t.expiries[0].expire) {
+		e := heap.Pop(&t.expiries).(expiring)
+		// refreshed sessions have been queued again.
+		if session, ok := t.sessions[e.key]; ok && session.expire.Equal(e.expire) {
+			t.remove(e.key, session)
+		}
+	}
+}
+
+// check tells whether one more session fits the caps of
+// the room and the user. Must be called with lock held.
+func (t *playbackSessionTracker) check(room *models.Room, user *models.User, now time.Time) cerrors.ChocolateError {
+	t.expire(now)
+	viewers := t.viewers[room.ID]
+
+	// the owner and admins are never kept out of the room.
+	privileged := user != nil && (room.OwnerID == user.ID || user.Role.AbilityManageRoom)
+	if room.MaxViewers != 0 && viewers >= room.MaxViewers && !privileged {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestRoomViewerCapReached,
+			Message: fmt.Sprintf("the room is full, at most %d viewers", room.MaxViewers),
+		}
+	}
+	if user != nil && user.MaxPlaybackSessions != 0 && t.userSessions[user.ID] >= user.MaxPlaybackSessions {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestPlaybackSessionCapReached,
+			Message: fmt.Sprintf("your account is playing on %d devices already", t.userSessions[user.ID]),
+		}
+	}
+	return nil
+}
+
+func (t *playbackSessionTracker) Check(room *models.Room, user *models.User, now time.Time) cerrors.ChocolateError {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.check(room, user, now)
+}
+
+// Start refreshes the session if it is already there,
+// otherwise starts it if the caps allow. A zero `ttl`
+// keeps the session until End.
+func (t *playbackSessionTracker) Start(key string, room *models.Room, user *models.User, ttl time.Duration, now time.Time) cerrors.ChocolateError {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var expire time.Time
+	if ttl != 0 {
+		expire = now.Add(ttl)
+	}
+	t.expire(now)
+	if session, ok := t.sessions[key]; ok {
+		session.expire = expire
+		if !expire.IsZero() {
+			heap.Push(&t.expiries, expiring{key, expire})
+		}
+		return nil
+	}
+	if err := t.check(room, user, now); err != nil {
+		return err
+	}
+
+	session := &playbackSession{roomId: room.ID, expire: expire}
+	if user != nil {
+		session.uid = user.ID
+	}
+	t.add(key, session)
+	return nil
+}
+
+func (t *playbackSessionTracker) End(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if session, ok := t.sessions[key]; ok {
+		t.remove(key, session)
+	}
+}
+
+// CheckPlaybackCaps is for requests which can not be told
+// apart as sessions, it admits no one once a cap is hit.
+func CheckPlaybackCaps(room *models.Room, user *models.User) cerrors.ChocolateError {
+	return playbackSessions.Check(room, user, time.Now())
+}
+
+// StartPlaybackSession counts the session identified by
+// `key` against the room and account caps.
+func StartPlaybackSession(key string, room *models.Room, user *models.User, ttl time.Duration) cerrors.ChocolateError {
+	return playbackSessions.Start(key, room, user, ttl, time.Now())
+}
+
+func EndPlaybackSession(key string) {
+	playbackSessions.End(key)
+}
+
+func ChangeRoomMaxViewers(id uint, maxViewers uint) cerrors.ChocolateError {
+	return models.SetRoomMaxViewers(id, maxViewers)
+}
+
+func ModifyUserMaxPlaybackSessions(username string, count uint) cerrors.ChocolateError {
+	return models.ModifyUserMaxPlaybackSessions(username, count)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"gorm.io/gorm"
+)
+
+func requireCapError(t *testing.T, err cerrors.ChocolateError, id cerrors.RequestErrorCode) {
+	t.Helper()
+	if rerr, ok := err.(cerrors.RequestError); !ok || rerr.ID != id {
+		t.Fatalf("expecting error %d, got %v", id, err)
+	}
+}
+
+func TestPlaybackSessionCaps(t *testing.T) {
+	tracker := newPlaybackSessionTracker()
+	now := time.Now()
+	owner := &models.User{Model: gorm.Model{ID: 1}}
+	viewer := &models.User{Model: gorm.Model{ID: 2}, MaxPlaybackSessions: 1}
+	room := &models.Room{Model: gorm.Model{ID: 3}, OwnerID: owner.ID, MaxViewers: 2}
+
+	if err := tracker.Start("a", room, viewer, HlsPlaybackSessionTTL, now); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := tracker.Start("a", room, viewer, HlsPlaybackSessionTTL, now.Add(time.Second)); err != nil {
+		t.Fatal("refreshing a session should not count again")
+	}
+	requireCapError(t, tracker.Start("b", &models.Room{Model: gorm.Model{ID: 4}}, viewer, 0, now), cerrors.RequestPlaybackSessionCapReached)
+
+	if err := tracker.Start("c", room, nil, 0, now); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	requireCapError(t, tracker.Check(room, nil, now), cerrors.RequestRoomViewerCapReached)
+	if err := tracker.Check(room, owner, now); err != nil {
+		t.Fatal("the owner should not be kept out")
+	}
+
+	tracker.End("c")
+	if err := tracker.Check(room, nil, now); err != nil {
+		t.Fatal("ended sessions should not count")
+	}
+	if err := tracker.Start("b", room, viewer, 0, now.Add(HlsPlaybackSessionTTL+time.Second)); err != nil {
+		t.Fatal("hls sessions without polling should expire")
+	}
+}
+
+func TestPlaybackSessionRefresh(t *testing.T) {
+	tracker := newPlaybackSessionTracker()
+	now := time.Now()
+	viewer := &models.User{Model: gorm.Model{ID: 2}, MaxPlaybackSessions: 1}
+	room := &models.Room{Model: gorm.Model{ID: 3}, MaxViewers: 1}
+
+	tracker.Start("a", room, viewer, HlsPlaybackSessionTTL, now)
+	tracker.Start("a", room, viewer, HlsPlaybackSessionTTL, now.Add(HlsPlaybackSessionTTL/2))
+	requireCapError(t, tracker.Check(room, nil, now.Add(HlsPlaybackSessionTTL)), cerrors.RequestRoomViewerCapReached)
+
+	tracker.End("a")
+	if len(tracker.viewers) != 0 || len(tracker.userSessions) != 0 {
+		t.Fatalf("counts should drop with the session, got %v, %v", tracker.viewers, tracker.userSessions)
+	}
+	if err := tracker.Check(room, viewer, now.Add(HlsPlaybackSessionTTL*2)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sheey11/chocolate/models"
+	"gorm.io/gorm"
+)
+
+func TestPlaybackTicket(t *testing.T) {
+	now := time.Now()
+	room := &models.Room{Model: gorm.Model{ID: 3}}
+	ticket, expire := CreatePlaybackTicket(room, &models.User{Model: gorm.Model{ID: 7}}, now)
+
+	if uid, ok := verifyPlaybackTicket(ticket, room.ID, now); !ok || uid != 7 {
+		t.Fatalf("the ticket should identify the user, got %d, %v", uid, ok)
+	}
+	if _, ok := verifyPlaybackTicket(ticket, 4, now); ok {
+		t.Fatal("the ticket should not be accepted by other rooms")
+	}
+	if _, ok := verifyPlaybackTicket(ticket, room.ID, expire.Add(time.Second)); ok {
+		t.Fatal("expired tickets should not be accepted")
+	}
+	if _, ok := verifyPlaybackTicket(createGuestPlaybackToken(3, 7, expire), room.ID, now); ok {
+		t.Fatal("guest tokens should not pass as tickets")
+	}
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/chat"
+	"github.com/sheey11/chocolate/common"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/srs"
+	"github.com/sirupsen/logrus"
+)
+
+// StreamPolicy limits what a publish may be, zero values
+// mean no limit.
+type StreamPolicy struct {
+	// role name -> longest continuous publish
+	MaxDuration map[string]time.Duration
+	MaxKbps     uint
+	MaxWidth    uint
+	MaxHeight   uint
+	VideoCodecs []string
+	AudioCodecs []string
+	// minutes since midnight, nil if there are no quiet
+	// hours.
+	QuietStart  *int
+	QuietEnd    *int
+	ExemptRoles []string
+}
+
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func loadStreamPolicy(cfg *common.ChocolateConfig) (*StreamPolicy, error) {
+	p := cfg.Policy
+	policy := &StreamPolicy{
+		MaxDuration: map[string]time.Duration{},
+		MaxKbps:     p.MaxKbps,
+		MaxWidth:    p.MaxWidth,
+		MaxHeight:   p.MaxHeight,
+		VideoCodecs: p.VideoCodecs,
+		AudioCodecs: p.AudioCodecs,
+		ExemptRoles: p.ExemptRoles,
+	}
+	for role, seconds := range p.MaxDuration {
+		policy.MaxDuration[role] = time.Second * time.Duration(seconds)
+	}
+	if p.QuietHours.Start != "" || p.QuietHours.End != "" {
+		start, err := parseClock(p.QuietHours.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quiet hours start: %w", err)
+		}
+		end, err := parseClock(p.QuietHours.End)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quiet hours end: %w", err)
+		}
+		policy.QuietStart, policy.QuietEnd = &start, &end
+	}
+	return policy, nil
+}
+
+func (p *StreamPolicy) inQuietHours(now time.Time) bool {
+	if p.QuietStart == nil || p.QuietEnd == nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	start, end := *p.QuietStart, *p.QuietEnd
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	// spans midnight
+	return minute >= start || minute < end
+}
+
+func codecAllowed(allowed []string, codec string) bool {
+	// codecs are unknown before srs parses the stream.
+	if len(allowed) == 0 || codec == "" {
+		return true
+	}
+	return lo.ContainsBy(allowed, func(c string) bool { return strings.EqualFold(c, codec) })
+}
+
+// Violations returns what the publish violates, `stream`
+// is nil if srs has not reported the stream yet, `started`
+// is when the publish started, see publishStartedAt, zero
+// if unknown.
+func (p *StreamPolicy) Violations(role string, stream *srs.Stream, started time.Time, now time.Time) []string {
+	if lo.Contains(p.ExemptRoles, role) {
+		return nil
+	}
+
+	violations := []string{}
+	if p.inQuietHours(now) {
+		violations = append(violations, "streaming is not allowed during quiet hours")
+	}
+	if max, ok := p.MaxDuration[role]; ok && max > 0 && !started.IsZero() && now.Sub(started) > max {
+		violations = append(violations, fmt.Sprintf("stream lasts longer than %s", max))
+	}
+	if stream == nil {
+		return violations
+	}
+
+	if p.MaxKbps != 0 && stream.Stat.Recv30sKBytes > p.MaxKbps {
+		violations = append(violations, fmt.Sprintf("bitrate %dkbps exceeds %dkbps", stream.Stat.Recv30sKBytes, p.MaxKbps))
+	}
+	if (p.MaxWidth != 0 && stream.Video.Width > p.MaxWidth) || (p.MaxHeight != 0 && stream.Video.Height > p.MaxHeight) {
+		violations = append(violations, fmt.Sprintf("resolution %dx%d is too high", stream.Video.Width, stream.Video.Height))
+	}
+	if !codecAllowed(p.VideoCodecs, stream.Video.Codec) {
+		violations = append(violations, fmt.Sprintf("video codec %s is not allowed", stream.Video.Codec))
+	}
+	if !codecAllowed(p.AudioCodecs, stream.Audio.Codec) {
+		violations = append(violations, fmt.Sprintf("audio codec %s is not allowed", stream.Audio.Codec))
+	}
+	return violations
+}
+
+var policyEnforcer = struct {
+	sync.Mutex
+	policy *StreamPolicy
+	grace  time.Duration
+	// room -> when it is warned
+	warned map[uint]time.Time
+}{warned: map[uint]time.Time{}}
+
+// enforceRoomPolicy warns a room violating the policy
+// and cuts it off if it still violates after the grace
+// period. Must be called with the enforcer lock held.
+func enforceRoomPolicy(room *models.Room, stream *srs.Stream, now time.Time) {
+	policy := policyEnforcer.policy
+	if policy == nil {
+		return
+	}
+
+	violations := policy.Violations(room.Owner.RoleName, stream, publishStartedAt(room), now)
+	if len(violations) == 0 {
+		delete(policyEnforcer.warned, room.ID)
+		return
+	}
+	reason := strings.Join(violations, "; ")
+
+	warnedAt, warned := policyEnforcer.warned[room.ID]
+	if !warned {
+		policyEnforcer.warned[room.ID] = now
+		chat.SendMessage(&models.ChatMessage{
+			Room:    *room,
+			RoomID:  room.ID,
+			Type:    models.ChatMessageTypePolicyWarning,
+			Message: fmt.Sprintf("%s, the stream will be cut off in %d seconds", reason, int(policyEnforcer.grace.Seconds())),
+		})
+		logrus.WithField("room_id", room.ID).WithField("reason", reason).Info("stream policy violated, room warned")
+		return
+	}
+	if now.Sub(warnedAt) < policyEnforcer.grace {
+		return
+	}
+
+	delete(policyEnforcer.warned, room.ID)
+	if err := CutOffStreamWithReason(room, SystemOperator, reason); err != nil {
+		logrus.WithError(err).WithField("room_id", room.ID).Error("error cutting off stream violating policy")
+		return
+	}
+	logrus.WithField("room_id", room.ID).WithField("reason", reason).Info("stream cut off by policy")
+}
+
+// CheckRoomPolicy checks a room right after it publishes,
+// stats of the stream are not available yet.
+func CheckRoomPolicy(roomId uint) {
+	room, err := models.GetRoomByID(roomId, []string{"Owner"})
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", roomId).Error("error looking up room for policy check")
+		return
+	}
+
+	policyEnforcer.Lock()
+	defer policyEnforcer.Unlock()
+	enforceRoomPolicy(room, nil, time.Now())
+}
+
+// CheckStreamPolicies checks every publishing room against
+// the stats srs reports.
+func CheckStreamPolicies() {
+	rooms, err := models.ListRoomsToReconcile()
+	if err != nil {
+		logrus.WithError(err).Error("error listing rooms for policy check")
+		return
+	}
+
+	// node name -> room id -> stream
+	streams := map[string]map[uint]*srs.Stream{}
+	for _, node := range srs.Nodes() {
+		s, err := node.GetStreams()
+		if err != nil {
+			logrus.WithError(err).WithField("node", node.Name).Warn("srs node unreachable when checking policy")
+			continue
+		}
+		streams[node.Name] = map[uint]*srs.Stream{}
+		for i := range s.StreamList {
+			stream := &s.StreamList[i]
+			id, err := strconv.Atoi(stream.Name)
+			if err == nil && id > 0 && stream.App == "live" && stream.Publish.Active {
+				streams[node.Name][uint(id)] = stream
+			}
+		}
+	}
+
+	policyEnforcer.Lock()
+	defer policyEnforcer.Unlock()
+
+	now := time.Now()
+	checked := map[uint]bool{}
+	for _, room := range rooms {
+		if room.Status != models.RoomStatusStreaming || room.SrsClientID == nil {
+			continue
+		}
+		node := lo.FromPtr(room.SrsNode)
+		if node == "" {
+			node = srs.DefaultNodeName
+			if nodes := srs.Nodes(); len(nodes) > 0 {
+				node = nodes[0].Name
+			}
+		}
+		nodeStreams, ok := streams[node]
+		if !ok {
+			continue
+		}
+
+		owner := GetUserByID(room.OwnerID)
+		if owner == nil {
+			continue
+		}
+		room.Owner = *owner
+		enforceRoomPolicy(room, nodeStreams[room.ID], now)
+		checked[room.ID] = true
+	}
+
+	// forget rooms stopped publishing since warned.
+	for id := range policyEnforcer.warned {
+		if !checked[id] {
+			delete(policyEnforcer.warned, id)
+		}
+	}
+}
+
+// StartPolicyEnforcer loads the policy from config and
+// checks streams every configured interval.
+func StartPolicyEnforcer() {
+	policy, err := loadStreamPolicy(&common.Config)
+	if err != nil {
+		logrus.WithError(err).Fatal("invalid stream policy, check your config file")
+	}
+
+	policyEnforcer.Lock()
+	policyEnforcer.policy = policy
+	policyEnforcer.grace = time.Second * time.Duration(common.Config.Policy.Grace)
+	policyEnforcer.Unlock()
+
+	interval := time.Second * time.Duration(common.Config.Policy.Interval)
+	if interval == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			CheckStreamPolicies()
+		}
+	}()
+}
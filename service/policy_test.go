@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sheey11/chocolate/common"
+	"github.com/sheey11/chocolate/srs"
+)
+
+func newPolicyTestConfig() *common.ChocolateConfig {
+	cfg := &common.ChocolateConfig{}
+	cfg.Policy.MaxDuration = map[string]uint{"user": 3600}
+	cfg.Policy.MaxKbps = 6000
+	cfg.Policy.MaxWidth = 1920
+	cfg.Policy.MaxHeight = 1080
+	cfg.Policy.VideoCodecs = []string{"H264"}
+	cfg.Policy.QuietHours.Start = "23:00"
+	cfg.Policy.QuietHours.End = "06:00"
+	cfg.Policy.ExemptRoles = []string{"administrator"}
+	return cfg
+}
+
+func TestStreamPolicyViolations(t *testing.T) {
+	policy, err := loadStreamPolicy(newPolicyTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.Local)
+	started := now.Add(-30 * time.Minute)
+	// live_ms is when srs dumps the stats, about now, it
+	// must not be taken as the publish start.
+	stream := &srs.Stream{LiveTimestamp: uint(now.UnixMilli())}
+	stream.Video.Codec = "h264"
+	stream.Video.Width = 1920
+	stream.Video.Height = 1080
+	stream.Stat.Recv30sKBytes = 4000
+
+	if v := policy.Violations("user", stream, started, now); len(v) != 0 {
+		t.Fatalf("expected no violation, got %v", v)
+	}
+	if v := policy.Violations("user", nil, started, now); len(v) != 0 {
+		t.Fatalf("expected no violation without stats, got %v", v)
+	}
+
+	longAgo := now.Add(-2 * time.Hour)
+	if v := policy.Violations("user", stream, longAgo, now); len(v) != 1 {
+		t.Fatalf("expected the duration violation, got %v", v)
+	}
+	// the duration is known before srs reports the stream.
+	if v := policy.Violations("user", nil, longAgo, now); len(v) != 1 {
+		t.Fatalf("expected the duration violation without stats, got %v", v)
+	}
+	if v := policy.Violations("user", stream, time.Time{}, now); len(v) != 0 {
+		t.Fatalf("unknown publish start should not violate, got %v", v)
+	}
+
+	bad := *stream
+	bad.Video.Codec = "HEVC"
+	bad.Video.Width = 3840
+	bad.Stat.Recv30sKBytes = 8000
+	if v := policy.Violations("user", &bad, longAgo, now); len(v) != 4 {
+		t.Fatalf("expected 4 violations, got %v", v)
+	}
+	// no duration limit for roles not configured
+	if v := policy.Violations("streamer", &bad, longAgo, now); len(v) != 3 {
+		t.Fatalf("expected 3 violations, got %v", v)
+	}
+	if v := policy.Violations("administrator", &bad, longAgo, now); len(v) != 0 {
+		t.Fatalf("exempt role should not violate, got %v", v)
+	}
+
+	for _, clock := range []int{23, 0, 5} {
+		at := time.Date(2023, 6, 1, clock, 30, 0, 0, time.Local)
+		if v := policy.Violations("user", nil, time.Time{}, at); len(v) != 1 {
+			t.Fatalf("expected quiet hours violation at %02d:30, got %v", clock, v)
+		}
+	}
+	if v := policy.Violations("user", nil, time.Time{}, time.Date(2023, 6, 1, 6, 0, 0, 0, time.Local)); len(v) != 0 {
+		t.Fatalf("quiet hours should end at 06:00, got %v", v)
+	}
+}
+
+func TestLoadStreamPolicyInvalidQuietHours(t *testing.T) {
+	cfg := newPolicyTestConfig()
+	cfg.Policy.QuietHours.End = "6am"
+	if _, err := loadStreamPolicy(cfg); err == nil {
+		t.Fatal("expected error for invalid quiet hours")
+	}
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/common"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/srs"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ReconcileActionRecordPublisher = "record-publisher"
+	ReconcileActionClearClient     = "clear-dead-client"
+	ReconcileActionKickPublisher   = "kick-unauthorized-publisher"
+	ReconcileActionSetIdle         = "set-idle"
+	ReconcileActionFixViewers      = "fix-viewers"
+)
+
+type ReconcileAction struct {
+	RoomID uint   `json:"room_id"`
+	Action string `json:"action"`
+	Detail string `json:"detail"`
+	// error applying the action, nil if it succeeded.
+	Error *string `json:"error"`
+}
+
+type ReconcileReport struct {
+	StartedAt        time.Time         `json:"started_at"`
+	Duration         int64             `json:"duration_ms"`
+	RoomsChecked     int               `json:"rooms_checked"`
+	UnreachableNodes []string          `json:"unreachable_nodes"`
+	Actions          []ReconcileAction `json:"actions"`
+	// set if the reconciliation is not carried out.
+	Error *string `json:"error"`
+}
+
+type srsPublisher struct {
+	Node     string
+	ClientID string
+	StreamID string
+}
+
+// srsObservation is what all srs nodes report about
+// the rooms.
+type srsObservation struct {
+	publishers  map[uint]srsPublisher
+	viewers     map[uint]uint
+	unreachable map[string]bool
+}
+
+func observeSrs(nodes []*srs.Node) *srsObservation {
+	obs := &srsObservation{
+		publishers:  map[uint]srsPublisher{},
+		viewers:     map[uint]uint{},
+		unreachable: map[string]bool{},
+	}
+	for _, node := range nodes {
+		streams, err := node.GetStreams()
+		if err == nil && streams.ResponseCode != 0 {
+			err = errors.New("srs responded with non-zero code")
+		}
+		var clients *srs.Clients
+		if err == nil {
+			clients, err = node.GetClients()
+		}
+		if err != nil {
+			logrus.WithError(err).WithField("node", node.Name).Warn("srs node unreachable when reconciling")
+			obs.unreachable[node.Name] = true
+			continue
+		}
+
+		// stream id -> room id
+		rooms := map[string]uint{}
+		for _, stream := range streams.StreamList {
+			id, err := strconv.Atoi(stream.Name)
+			if err != nil || id <= 0 || stream.App != "live" || !stream.Publish.Active {
+				continue
+			}
+			rooms[stream.ID] = uint(id)
+			obs.publishers[uint(id)] = srsPublisher{
+				Node:     node.Name,
+				ClientID: stream.Publish.CID,
+				StreamID: stream.ID,
+			}
+		}
+		for _, client := range clients.ClientList {
+			if id, ok := rooms[client.StreamID]; ok && !client.Publish {
+				obs.viewers[id]++
+			}
+		}
+	}
+	return obs
+}
+
+// planRoomReconcile decides how to correct the room,
+// `noPublisherSince` is when the streaming room was
+// first seen without publisher, zero if not seen yet.
+func planRoomReconcile(room *models.Room, obs *srsObservation, idleTimeout time.Duration, noPublisherSince time.Time, now time.Time) []ReconcileAction {
+	actions := []ReconcileAction{}
+	add := func(action string, detail string) {
+		actions = append(actions, ReconcileAction{RoomID: room.ID, Action: action, Detail: detail})
+	}
+
+	publisher, live := obs.publishers[room.ID]
+	// the publisher may be on a node we can not reach,
+	// nothing is certain but a live stream then.
+	nodeName := lo.FromPtr(room.SrsNode)
+	if nodeName == "" {
+		if nodes := srs.Nodes(); len(nodes) > 0 {
+			nodeName = nodes[0].Name
+		}
+	}
+	if !live && obs.unreachable[nodeName] {
+		return actions
+	}
+
+	if room.Status != models.RoomStatusStreaming {
+		if live {
+			add(ReconcileActionKickPublisher, "room is not streaming but client "+publisher.ClientID+" is publishing on node "+publisher.Node)
+		}
+	} else if live {
+		if room.SrsClientID == nil || *room.SrsClientID != publisher.ClientID || nodeName != publisher.Node {
+			add(ReconcileActionRecordPublisher, "client "+publisher.ClientID+" is publishing on node "+publisher.Node)
+		}
+	} else if idleTimeout > 0 && !noPublisherSince.IsZero() && now.Sub(noPublisherSince) > idleTimeout {
+		add(ReconcileActionSetIdle, "no publisher since "+noPublisherSince.Format(time.RFC3339))
+	}
+
+	if !live && room.SrsClientID != nil {
+		add(ReconcileActionClearClient, "client "+*room.SrsClientID+" is no longer publishing")
+	}
+
+	viewers := obs.viewers[room.ID]
+	if room.Viewers != viewers {
+		add(ReconcileActionFixViewers, "viewers "+strconv.FormatUint(uint64(room.Viewers), 10)+" -> "+strconv.FormatUint(uint64(viewers), 10))
+	}
+	return actions
+}
+
+func applyReconcileAction(room *models.Room, action ReconcileAction, obs *srsObservation) error {
+	publisher := obs.publishers[room.ID]
+	switch action.Action {
+	case ReconcileActionRecordPublisher:
+		if err := models.RecordRoomClientID(room.ID, &publisher.Node, publisher.ClientID); err != nil {
+			return err
+		}
+		if err := models.RecordRoomStreamID(room.ID, publisher.StreamID); err != nil {
+			return err
+		}
+		StartBroadcast(room.ID)
+		go StartRoomForwarding(room.ID)
+	case ReconcileActionClearClient:
+		current, err := models.GetRoomByID(room.ID, []string{})
+		if err != nil {
+			return err
+		}
+		if current.SrsClientID == nil || *current.SrsClientID != *room.SrsClientID {
+			return errors.New("publisher changed during reconciliation")
+		}
+		if err := models.ClearRoomStreamAndClientID(room.ID); err != nil {
+			return err
+		}
+		StopRoomForwarding(room.ID)
+		EndBroadcast(room.ID, models.BroadcastEndReasonLost, "")
+	case ReconcileActionKickPublisher:
+		current, err := models.GetRoomByID(room.ID, []string{})
+		if err != nil {
+			return err
+		}
+		if current.Status == models.RoomStatusStreaming {
+			return errors.New("room started streaming during reconciliation")
+		}
+		node := srs.GetNode(publisher.Node)
+		if node == nil {
+			return srs.ErrNotFound
+		}
+		if err := node.KickClient(publisher.ClientID); err != nil && err != srs.ErrNotFound {
+			return err
+		}
+	case ReconcileActionSetIdle:
+		if err := models.SetRoomStatus(room.ID, models.RoomStatusIdle); err != nil {
+			return err
+		}
+	case ReconcileActionFixViewers:
+		if err := models.SetRoomViewers(room.ID, obs.viewers[room.ID]); err != nil {
+			return err
+		}
+		sampleBroadcastViewers(room.ID)
+		// viewer counts drift all the time, not worth a log.
+		return nil
+	}
+
+	detail, _ := json.Marshal(struct {
+		Action string `json:"action"`
+		Detail string `json:"detail"`
+	}{action.Action, action.Detail})
+	if err := models.RecordEventWithDetail(models.LogTypeReconcile, room.ID, string(detail)); err != nil {
+		logrus.WithError(err).WithField("room_id", room.ID).Error("error recording reconcile event")
+	}
+	return nil
+}
+
+var reconciler = struct {
+	sync.Mutex
+	// streaming room -> first seen without publisher
+	noPublisherSince map[uint]time.Time
+	lastReport       *ReconcileReport
+}{noPublisherSince: map[uint]time.Time{}}
+
+// ReconcileRooms compares rooms with what srs reports and
+// corrects the database, only one reconciliation runs
+// at a time.
+func ReconcileRooms() *ReconcileReport {
+	reconciler.Lock()
+	defer reconciler.Unlock()
+
+	report := &ReconcileReport{
+		StartedAt:        time.Now(),
+		UnreachableNodes: []string{},
+		Actions:          []ReconcileAction{},
+	}
+	defer func() {
+		report.Duration = time.Since(report.StartedAt).Milliseconds()
+		reconciler.lastReport = report
+	}()
+
+	// rooms are read before srs, so a publish callback
+	// racing with us at most leads to recording the same
+	// client again.
+	rooms, err := models.ListRoomsToReconcile()
+	if err != nil {
+		logrus.WithError(err).Error("error listing rooms to reconcile")
+		report.Error = lo.ToPtr(err.Error())
+		return report
+	}
+
+	obs := observeSrs(srs.Nodes())
+	for node := range obs.unreachable {
+		report.UnreachableNodes = append(report.UnreachableNodes, node)
+	}
+
+	// idle rooms with a publisher are not listed.
+	listed := lo.SliceToMap(rooms, func(room *models.Room) (uint, bool) { return room.ID, true })
+	for id := range obs.publishers {
+		if listed[id] {
+			continue
+		}
+		room, err := models.GetRoomByID(id, []string{})
+		if err != nil {
+			continue
+		}
+		rooms = append(rooms, room)
+	}
+
+	now := time.Now()
+	idleTimeout := time.Second * time.Duration(common.Config.Reconcile.IdleTimeout)
+	noPublisherSince := map[uint]time.Time{}
+	for _, room := range rooms {
+		if _, live := obs.publishers[room.ID]; room.Status == models.RoomStatusStreaming && !live {
+			since, ok := reconciler.noPublisherSince[room.ID]
+			if !ok {
+				since = now
+			}
+			noPublisherSince[room.ID] = since
+		}
+
+		actions := planRoomReconcile(room, obs, idleTimeout, reconciler.noPublisherSince[room.ID], now)
+		for _, action := range actions {
+			if err := applyReconcileAction(room, action, obs); err != nil {
+				logrus.WithError(err).WithField("room_id", room.ID).WithField("action", action.Action).Error("error applying reconcile action")
+				action.Error = lo.ToPtr(err.Error())
+			} else if action.Action == ReconcileActionSetIdle {
+				delete(noPublisherSince, room.ID)
+			}
+			report.Actions = append(report.Actions, action)
+		}
+	}
+	reconciler.noPublisherSince = noPublisherSince
+	report.RoomsChecked = len(rooms)
+
+	if len(report.Actions) > 0 {
+		logrus.WithField("actions", len(report.Actions)).Info("rooms reconciled with srs")
+	}
+	return report
+}
+
+// GetLastReconcileReport returns nil if rooms are never
+// reconciled.
+func GetLastReconcileReport() *ReconcileReport {
+	reconciler.Lock()
+	defer reconciler.Unlock()
+	return reconciler.lastReport
+}
+
+// StartReconciler reconciles rooms right away, then every
+// configured interval. The database must be connected.
+func StartReconciler() {
+	interval := time.Second * time.Duration(common.Config.Reconcile.Interval)
+	go func() {
+		ReconcileRooms()
+		if interval == 0 {
+			return
+		}
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			ReconcileRooms()
+		}
+	}()
+}
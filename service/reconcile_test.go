@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/internal/srstest"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/srs"
+	"gorm.io/gorm"
+)
+
+func actionNames(actions []ReconcileAction) []string {
+	return lo.Map(actions, func(action ReconcileAction, _ int) string { return action.Action })
+}
+
+func TestPlanRoomReconcile(t *testing.T) {
+	origin := srstest.NewFakeServer()
+	origin.AddStream(srs.Stream{ID: "st1", Name: "1", App: "live"})
+	origin.AddClient(srs.Client{ID: "pub1", StreamID: "st1", Publish: true})
+	origin.AddClient(srs.Client{ID: "v1", StreamID: "st1"})
+	origin.AddClient(srs.Client{ID: "v2", StreamID: "st1"})
+	origin.AddStream(srs.Stream{ID: "st2", Name: "2", App: "live"})
+	origin.AddClient(srs.Client{ID: "pub2", StreamID: "st2", Publish: true})
+
+	srs.SetNodes([]*srs.Node{
+		srs.NewNode("origin", origin, ""),
+		srs.NewNode("down", srstest.NewFakeServer(), ""),
+	})
+	defer srs.SetNodes(nil)
+
+	obs := observeSrs(srs.Nodes()[:1])
+	obs.unreachable["down"] = true
+
+	now := time.Now()
+	down := "down"
+	cases := []struct {
+		name     string
+		room     models.Room
+		since    time.Time
+		expected []string
+	}{
+		{
+			name:     "consistent",
+			room:     models.Room{Model: gorm.Model{ID: 1}, Status: models.RoomStatusStreaming, SrsClientID: lo.ToPtr("pub1"), SrsNode: lo.ToPtr("origin"), Viewers: 2},
+			expected: []string{},
+		},
+		{
+			name:     "missed publish",
+			room:     models.Room{Model: gorm.Model{ID: 1}, Status: models.RoomStatusStreaming},
+			expected: []string{ReconcileActionRecordPublisher, ReconcileActionFixViewers},
+		},
+		{
+			name:     "missed unpublish",
+			room:     models.Room{Model: gorm.Model{ID: 3}, Status: models.RoomStatusStreaming, SrsClientID: lo.ToPtr("gone"), Viewers: 3},
+			expected: []string{ReconcileActionClearClient, ReconcileActionFixViewers},
+		},
+		{
+			name:     "publishing to idle room",
+			room:     models.Room{Model: gorm.Model{ID: 2}, Status: models.RoomStatusIdle, SrsClientID: lo.ToPtr("pub2")},
+			expected: []string{ReconcileActionKickPublisher},
+		},
+		{
+			name:     "no publisher for too long",
+			room:     models.Room{Model: gorm.Model{ID: 4}, Status: models.RoomStatusStreaming},
+			since:    now.Add(-time.Hour),
+			expected: []string{ReconcileActionSetIdle},
+		},
+		{
+			name:     "node unreachable",
+			room:     models.Room{Model: gorm.Model{ID: 5}, Status: models.RoomStatusStreaming, SrsClientID: lo.ToPtr("pubx"), SrsNode: &down, Viewers: 5},
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		actions := actionNames(planRoomReconcile(&tc.room, obs, time.Minute, tc.since, now))
+		if len(actions) != len(tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, actions)
+			continue
+		}
+		for j := range actions {
+			if actions[j] != tc.expected[j] {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, actions)
+				break
+			}
+		}
+	}
+}
@@ -1,25 +1,32 @@
 package service
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/samber/lo"
 	"github.com/sheey11/chocolate/chat"
 	cerrors "github.com/sheey11/chocolate/errors"
 	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/srs"
 	"github.com/sirupsen/logrus"
 )
 
+// params is the query string srs gives in the on_publish
+// callback, e.g. `?uid=xxx&key=xxx`. Webrtc publishers may
+// carry extra queries, so compare by value instead.
 func CheckRoomStreamPermission(room *models.Room, params string) bool {
 	if room.Status != models.RoomStatusStreaming {
 		return false
 	}
-	key := room.GetStreamKey()
-	return fmt.Sprintf("%d%s", room.ID, params) == key
+	queries, err := url.ParseQuery(strings.TrimPrefix(params, "?"))
+	if err != nil {
+		return false
+	}
+	return queries.Get("uid") == room.UID && pushKeyMatches(room, queries.Get("key"))
 }
 
 func GetRoomByUID(uid string) (*models.Room, cerrors.ChocolateError) {
@@ -37,7 +44,13 @@ func GetRoomByID(id uint) (*models.Room, cerrors.ChocolateError) {
 }
 
 func GetRoomByIDWithDetail(id uint) (*models.Room, cerrors.ChocolateError) {
-	return models.GetRoomByID(id, []string{"Owner", "PermissionItems"})
+	return models.GetRoomByID(id, []string{"Owner", "PermissionItems", "Category", "Tags"})
+}
+
+// GetRoomByIDWithListing includes what the directory
+// shows about the room.
+func GetRoomByIDWithListing(id uint) (*models.Room, cerrors.ChocolateError) {
+	return models.GetRoomByID(id, []string{"PermissionItems", "Category", "Tags"})
 }
 
 // this method also generates push key before setting
@@ -47,6 +60,16 @@ func SetRoomStartStreaming(id uint) cerrors.ChocolateError {
 	if err != nil {
 		return err
 	}
+	// the publisher may still connect to another node,
+	// on_publish records the actual one.
+	if node := srs.PickNode(); node != nil {
+		err = models.RecordRoomSrsNode(id, node.Name)
+		if err != nil {
+			return err
+		}
+	} else {
+		logrus.WithField("room_id", id).Warn("no srs node is reachable when starting streaming")
+	}
 	return models.SetRoomStatus(id, models.RoomStatusStreaming)
 }
 
@@ -70,14 +93,84 @@ func ChangeRoomPermission(id uint, permission models.RoomPermissionType, clearPe
 	if err != nil {
 		return err
 	}
-	return models.ChangeRoomPermissionType(room, permission, clearPermission)
+	err = models.ChangeRoomPermissionType(room, permission, clearPermission)
+	if err == nil {
+		decisionCache.ForgetRoom(room.ID)
+	}
+	return err
+}
+
+func ChangeRoomVisibility(id uint, visibility models.RoomVisibility) cerrors.ChocolateError {
+	switch visibility {
+	case models.RoomVisibilityPublic, models.RoomVisibilityUnlisted, models.RoomVisibilityPrivate:
+	default:
+		return cerrors.RequestError{
+			ID:      cerrors.RequestUnknownRoomVisibility,
+			Message: "unknown visibility",
+		}
+	}
+	err := models.SetRoomVisibility(id, visibility)
+	if err == nil {
+		// private rooms are decided in the cache.
+		decisionCache.ForgetRoom(id)
+	}
+	return err
+}
+
+const (
+	maxPermissionItemReasonLen = 256
+	// a year, in minutes.
+	maxPermissionItemDuration = 365 * 24 * 60
+)
+
+type PermissionItemData struct {
+	Reason string `json:"reason"`
+	// in minutes, 0 for permanent.
+	Duration uint `json:"duration"`
+}
+
+func (d *PermissionItemData) meta(creator *models.User, now time.Time) (models.PermissionItemMeta, cerrors.ChocolateError) {
+	meta := models.PermissionItemMeta{
+		Reason: strings.TrimSpace(d.Reason),
+	}
+	if utf8.RuneCountInString(meta.Reason) > maxPermissionItemReasonLen {
+		return meta, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidPermissionItem,
+			Message: "reason should be at most 256 characters",
+		}
+	}
+	if d.Duration > maxPermissionItemDuration {
+		return meta, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidPermissionItem,
+			Message: "duration should be at most a year",
+		}
+	}
+	if d.Duration != 0 {
+		meta.ExpiresAt = lo.ToPtr(now.Add(time.Duration(d.Duration) * time.Minute))
+	}
+	if creator != nil {
+		meta.CreatedBy = &creator.ID
+	}
+	return meta, nil
 }
 
-func AddRoomPermissionItem_Label(id uint, label string) cerrors.ChocolateError {
-	return models.AddRoomPermissionItem_Label(id, label)
+func AddRoomPermissionItem_Label(id uint, label string, creator *models.User, data *PermissionItemData) cerrors.ChocolateError {
+	meta, err := data.meta(creator, time.Now())
+	if err != nil {
+		return err
+	}
+	err = models.AddRoomPermissionItem_Label(id, label, meta)
+	if err == nil {
+		decisionCache.ForgetRoom(id)
+	}
+	return err
 }
 
-func AddRoomPermissionItem_User(id uint, username string) cerrors.ChocolateError {
+func AddRoomPermissionItem_User(id uint, username string, creator *models.User, data *PermissionItemData) cerrors.ChocolateError {
+	meta, err := data.meta(creator, time.Now())
+	if err != nil {
+		return err
+	}
 	u, _ := models.GetUserByName(username, nil)
 	if u == nil {
 		return cerrors.RequestError{
@@ -85,11 +178,34 @@ func AddRoomPermissionItem_User(id uint, username string) cerrors.ChocolateError
 			Message: "user not found",
 		}
 	}
-	return models.AddRoomPermissionItem_User(id, u.ID)
+	err = models.AddRoomPermissionItem_User(id, u.ID, meta)
+	if err == nil {
+		decisionCache.ForgetRoom(id)
+	}
+	return err
+}
+
+func AddRoomPermissionItem_Role(id uint, role string, creator *models.User, data *PermissionItemData) cerrors.ChocolateError {
+	meta, err := data.meta(creator, time.Now())
+	if err != nil {
+		return err
+	}
+	if _, err := models.GetRoleByName(role); err != nil {
+		return err
+	}
+	err = models.AddRoomPermissionItem_Role(id, role, meta)
+	if err == nil {
+		decisionCache.ForgetRoom(id)
+	}
+	return err
 }
 
 func DeleteRoomPermissionItem_Label(id uint, label string) cerrors.ChocolateError {
-	return models.DeleteRoomPermissionItem_Label(id, label)
+	err := models.DeleteRoomPermissionItem_Label(id, label)
+	if err == nil {
+		decisionCache.ForgetRoom(id)
+	}
+	return err
 }
 
 func DeleteRoomPermissionItem_User(id uint, username string) cerrors.ChocolateError {
@@ -100,10 +216,24 @@ func DeleteRoomPermissionItem_User(id uint, username string) cerrors.ChocolateEr
 			Message: "user not found",
 		}
 	}
-	return models.DeleteRoomPermissionItem_User(id, u.ID)
+	err := models.DeleteRoomPermissionItem_User(id, u.ID)
+	if err == nil {
+		decisionCache.ForgetRoom(id)
+	}
+	return err
 }
 
-func CreateRoomForUser(user *models.User, title string) (*models.Room, cerrors.ChocolateError) {
+func DeleteRoomPermissionItem_Role(id uint, role string) cerrors.ChocolateError {
+	err := models.DeleteRoomPermissionItem_Role(id, role)
+	if err == nil {
+		decisionCache.ForgetRoom(id)
+	}
+	return err
+}
+
+// CreateRoomForUser copies settings of the template if
+// `templateId` is given, see SaveRoomTemplate.
+func CreateRoomForUser(user *models.User, title string, templateId *uint) (*models.Room, cerrors.ChocolateError) {
 	if user == nil {
 		return nil, cerrors.LogicError{
 			ID:         cerrors.LogicNilReference,
@@ -119,17 +249,16 @@ func CreateRoomForUser(user *models.User, title string) (*models.Room, cerrors.C
 		}
 	}
 
-	count, err := user.GetAfflicateRoomCount()
-	if err != nil {
-		return nil, err
-	} else if count >= user.MaxRoomCount && user.RoleName != "administrator" { // TODO: use Role.ManageRooms instead of user.RoleName
-		return nil, cerrors.RequestError{
-			ID:      cerrors.RequestRoomCountReachedMax,
-			Message: "you have created max room allowed",
+	// the cap is checked when creating, along with the
+	// other creations and transfers of the user.
+	if templateId != nil {
+		template, err := models.GetRoomTemplate(user.ID, *templateId)
+		if err != nil {
+			return nil, err
 		}
+		return models.CreateRoomFromTemplate(user, title, template.RoomID, userMaxRooms(user))
 	}
-
-	return models.CreateRoomForUser(user, title)
+	return models.CreateRoomForUser(user, title, userMaxRooms(user))
 }
 
 // includes owner
@@ -148,84 +277,41 @@ func ListRooms(owner *models.User, status *models.RoomStatus, search string, lim
 	return models.ListRooms(owner, status, filterId, filterTitle, limit, page)
 }
 
+// SystemOperator is the operator of actions taken by
+// chocolate itself, e.g. policy enforcement.
+const SystemOperator uint = 0
+
 func CutOffStream(room *models.Room, operator uint) cerrors.ChocolateError {
-	if room.SrsClientID != nil {
-		urlStr := fmt.Sprintf("http://srs:1985/api/v1/clients/%s", *room.SrsClientID)
-		url, _ := url.Parse(urlStr)
-		response, gerr := http.DefaultClient.Do(&http.Request{
-			Method: http.MethodDelete,
-			URL:    url,
-			Proto:  "HTTP/1.1",
-		})
-		if gerr != nil {
-			lerr := cerrors.LogicError{
-				ID:         cerrors.LogicDatabaseAffiliatedFieldsMissing,
-				Message:    "error requesting client kick, check your code",
-				StackTrace: cerrors.GetStackTrace(),
-				InnerError: gerr,
-				Context: map[string]interface{}{
-					"url": urlStr,
-				},
-			}
-			return lerr
-		}
+	return CutOffStreamWithReason(room, operator, "")
+}
 
-		body, gerr := ioutil.ReadAll(response.Body)
-		if gerr != nil {
-			lerr := cerrors.LogicError{
-				ID:         cerrors.LogicUnmarshalingHttpResponseBodyError,
-				Message:    "error unmarshaling srs response, check your struct declear (service.SRSStreamInfoResponse)",
-				StackTrace: cerrors.GetStackTrace(),
-				Context: map[string]interface{}{
-					"url":        url,
-					"body_bytes": body,
-				},
-				InnerError: gerr,
-			}
-			return lerr
+func CutOffStreamWithReason(room *models.Room, operator uint, reason string) cerrors.ChocolateError {
+	// closed before the kick, so the unpublish callback
+	// finds nothing to close.
+	EndBroadcast(room.ID, models.BroadcastEndReasonCutOff, reason)
+	if room.SrsClientID != nil {
+		err := GetRoomSrsServer(room).KickClient(*room.SrsClientID)
+		if err != nil && err != srs.ErrNotFound {
+			return srsError("error requesting client kick, check your configuration", err, map[string]interface{}{
+				"client_id": *room.SrsClientID,
+			})
 		}
+	}
 
-		codeProber := &struct {
-			code uint
-		}{}
-
-		gerr = json.Unmarshal(body, codeProber)
-		if gerr != nil {
-			lerr := cerrors.LogicError{
-				ID:         cerrors.LogicUnmarshalingHttpResponseBodyError,
-				Message:    "error unmarshaling srs response, check your struct declear",
-				StackTrace: cerrors.GetStackTrace(),
-				Context: map[string]interface{}{
-					"url":        url,
-					"body_bytes": body,
-				},
-				InnerError: gerr,
-			}
-			return lerr
-		}
+	StopRoomForwarding(room.ID)
 
-		if codeProber.code != 0 {
-			lerr := cerrors.LogicError{
-				ID:         cerrors.LogicSRSRepondNonZero,
-				Message:    "srs respond with non-zero code, check your parameter",
-				StackTrace: cerrors.GetStackTrace(),
-				Context: map[string]interface{}{
-					"url":  url,
-					"code": codeProber.code,
-				},
-			}
-			return lerr
-		}
+	message := &models.ChatMessage{
+		Room:    *room,
+		RoomID:  room.ID,
+		Type:    models.ChatMessageTypeAdministrationCutOff,
+		Message: reason,
 	}
+	if operator != SystemOperator {
+		message.SenderID = &operator
+	}
+	chat.SendMessage(message)
 
-	chat.SendMessage(&models.ChatMessage{
-		Room:     *room,
-		RoomID:   room.ID,
-		Type:     models.ChatMessageTypeAdministrationCutOff,
-		SenderID: &operator,
-	})
-
-	RecordCutOffEvent(room.ID, operator)
+	RecordCutOffEvent(room.ID, operator, reason)
 	return models.SetRoomStatus(room.ID, models.RoomStatusIdle)
 }
 
@@ -238,7 +324,11 @@ func DeleteRoom(roomid uint, operator uint) cerrors.ChocolateError {
 	if operator != 0 && room.Status == models.RoomStatusStreaming {
 		CutOffStream(room, operator)
 	}
-	return models.DeleteRoom(roomid)
+	err = models.DeleteRoom(roomid)
+	if err == nil {
+		decisionCache.ForgetRoom(roomid)
+	}
+	return err
 }
 
 func RetriveRoomTimeline(roomid uint) ([]*models.Log, cerrors.ChocolateError) {
@@ -246,13 +336,63 @@ func RetriveRoomTimeline(roomid uint) ([]*models.Log, cerrors.ChocolateError) {
 }
 
 func IncreaseRoomViewer(roomid uint) cerrors.ChocolateError {
-	return models.IncreaseRoomViewer(roomid)
+	err := models.IncreaseRoomViewer(roomid)
+	if err == nil {
+		sampleBroadcastViewers(roomid)
+	}
+	return err
 }
 func DecreaseRoomViewer(roomid uint) cerrors.ChocolateError {
-	return models.DecreaseRoomViewer(roomid)
+	err := models.DecreaseRoomViewer(roomid)
+	if err == nil {
+		sampleBroadcastViewers(roomid)
+	}
+	return err
+}
+
+// IsRoomVisibleToUser tells whether the user may know
+// the room exists, `user` is nil for guests.
+func IsRoomVisibleToUser(room *models.Room, user *models.User) bool {
+	return roomVisibleTo(room, user, isRoomMember)
+}
+
+func roomVisibleTo(room *models.Room, user *models.User, isMember func(*models.Room, *models.User) bool) bool {
+	if room.Visibility != models.RoomVisibilityPrivate {
+		return true
+	}
+	return isRoomInsider(room, user, isMember)
+}
+
+// isRoomInsider tells whether the user is the owner, an
+// admin or a member of the room, who are never kept out.
+func isRoomInsider(room *models.Room, user *models.User, isMember func(*models.Room, *models.User) bool) bool {
+	if user == nil {
+		return false
+	}
+	return room.OwnerID == user.ID || user.Role.AbilityManageRoom || isMember(room, user)
+}
+
+// isRoomMember treats lookup failures as not a member.
+func isRoomMember(room *models.Room, user *models.User) bool {
+	member, err := models.GetRoomMember(room.ID, user.ID)
+	if err != nil {
+		logrus.WithError(err).Error("error when looking up room member")
+		return false
+	}
+	return member != nil
 }
 
 func IsUserAllowedForRoom(room *models.Room, user *models.User) bool {
+	return isAllowedForRoom(room, user, false)
+}
+
+// IsViewerAllowedForRoom also honours the access ticket
+// obtained with the room password.
+func IsViewerAllowedForRoom(room *models.Room, user *models.User, accessTicket string) bool {
+	return room != nil && isAllowedForRoom(room, user, VerifyRoomAccessTicket(accessTicket, room, time.Now()))
+}
+
+func isAllowedForRoom(room *models.Room, user *models.User, unlocked bool) bool {
 	if room == nil {
 		err := cerrors.LogicError{
 			ID:         cerrors.LogicNilReference,
@@ -263,17 +403,28 @@ func IsUserAllowedForRoom(room *models.Room, user *models.User) bool {
 		return false
 	}
 
+	// membership takes a lookup, it is left to the cached
+	// decision.
+	noMember := func(*models.Room, *models.User) bool { return false }
+	if isRoomInsider(room, user, noMember) {
+		return true
+	}
+
+	now := time.Now()
+	var uid uint
 	if user != nil {
-		if room.OwnerID == user.ID {
-			return true
-		} else if user.Role.AbilityManageRoom {
+		uid = user.ID
+	}
+	return decisionCache.Decide(decisionKey{room.ID, uid, unlocked}, now, func() bool {
+		if user != nil && isRoomMember(room, user) {
 			return true
 		}
-	} else if room.PermissionType == models.RoomPermissionWhitelist {
-		return false
-	}
-
-	return models.IsUserAllowedForRoom(room, user)
+		if room.Visibility == models.RoomVisibilityPrivate {
+			return false
+		}
+		_, allowed := decideRoomAccess(room, user, roomAccessPolicy(room), NewAccessSubject(user, now), unlocked)
+		return allowed
+	})
 }
 
 func ModifyRoomTitle(roomid uint, title string) cerrors.ChocolateError {
@@ -294,33 +445,59 @@ func GetStreamingRoomCount() uint {
 	return models.GetStreamingRoomCount()
 }
 
-func RecordRoomClientID(roomId uint, stream string) cerrors.ChocolateError {
-	return models.RecordRoomClientID(roomId, stream)
+// RecordRoomClientID records the publisher and the node
+// it connects to, `serverId` is the `server_id` srs
+// carries in the on_publish callback.
+func RecordRoomClientID(roomId uint, serverId string, client string) cerrors.ChocolateError {
+	var nodeName *string
+	if node := srs.GetNodeByServerID(serverId); node != nil {
+		nodeName = &node.Name
+	} else {
+		logrus.WithFields(logrus.Fields{
+			"room_id":   roomId,
+			"server_id": serverId,
+		}).Warn("publish callback from an unknown srs node, keeping the node of the room")
+	}
+	return models.RecordRoomClientID(roomId, nodeName, client)
 }
 
-func ClearRoomStreamAndClientID(roomId uint) cerrors.ChocolateError {
-	return models.ClearRoomStreamAndClientID(roomId)
+// GetRoomSrsServer returns the srs node serving the room.
+func GetRoomSrsServer(room *models.Room) srs.Server {
+	return srs.ServerOf(room.SrsNode)
+}
+
+// GetRoomPublishServer returns the rtmp server the room
+// should push to, empty if its node does not configure
+// a public address.
+func GetRoomPublishServer(room *models.Room) string {
+	if room.SrsNode == nil {
+		return ""
+	}
+	node := srs.GetNode(*room.SrsNode)
+	if node == nil || node.PublicRtmpAddr == "" {
+		return ""
+	}
+	return "rtmp://" + strings.TrimSuffix(node.PublicRtmpAddr, "/") + "/live"
 }
 
-type SRSClientInfo struct {
-	ID      string          `json:"id"`
-	Vhost   string          `json:"vhost"`
-	Stream  string          `json:"stream"`
-	IP      string          `json:"ip"`
-	PageUrl string          `json:"pageUrl"`
-	SwfUrl  string          `json:"swfUrl"`
-	TcUrl   string          `json:"tcUrl"`
-	Url     string          `json:"url"`
-	Type    string          `json:"type"`
-	Publish bool            `json:"publish"`
-	Alive   float64         `json:"alive"`
-	Kbps    map[string]uint `json:"kbps"`
+func ClearRoomStreamAndClientID(roomId uint) cerrors.ChocolateError {
+	return models.ClearRoomStreamAndClientID(roomId)
 }
 
-type SRSClientInfoResponse struct {
-	Code   int           `json:"code"`
-	Server string        `json:"server"`
-	Client SRSClientInfo `json:"client"`
+// srsError wraps errors of the srs api, a non-zero
+// response code is distinguished from connection errors.
+func srsError(message string, err error, context map[string]interface{}) cerrors.LogicError {
+	id := cerrors.LogicSRSConnectionError
+	if err == srs.ErrNotFound {
+		id = cerrors.LogicSRSRepondNonZero
+	}
+	return cerrors.LogicError{
+		ID:         id,
+		Message:    message,
+		StackTrace: cerrors.GetStackTrace(),
+		Context:    context,
+		InnerError: err,
+	}
 }
 
 func GetRoomStreamID(room *models.Room) *string {
@@ -331,100 +508,26 @@ func GetRoomStreamID(room *models.Room) *string {
 		return room.SrsStreamID
 	}
 
-	url := fmt.Sprintf("http://srs:1985/api/v1/clients/%s", *room.SrsClientID)
-	response, err := http.Get(url)
+	client, err := GetRoomSrsServer(room).GetClient(*room.SrsClientID)
 	if err != nil {
-		lerr := cerrors.LogicError{
-			ID:         cerrors.LogicSRSConnectionError,
-			Message:    "error connecting to srs api, check your configuration",
-			StackTrace: cerrors.GetStackTrace(),
-			Context: map[string]interface{}{
-				"url": url,
-			},
-			InnerError: err,
-		}
+		lerr := srsError("error looking up srs client, check your configuration", err, map[string]interface{}{
+			"client_id": *room.SrsClientID,
+		})
 		logrus.WithError(lerr).Error("error trying to get stream id")
 		return nil
 	}
 
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		lerr := cerrors.LogicError{
-			ID:         cerrors.LogicReadingHttpResponseBodyError,
-			Message:    "error reading srs api response, check your configuration",
-			StackTrace: cerrors.GetStackTrace(),
-			Context: map[string]interface{}{
-				"url": url,
-			},
-			InnerError: err,
-		}
-		logrus.WithError(lerr).Error("error reading response body")
-		return nil
-	}
-
-	codeProber := &struct {
-		Code uint `json:"code"`
-	}{}
-
-	err = json.Unmarshal(body, codeProber)
-	if err != nil {
-		lerr := cerrors.LogicError{
-			ID:         cerrors.LogicUnmarshalingHttpResponseBodyError,
-			Message:    "error probing srs response code, check your struct declear",
-			StackTrace: cerrors.GetStackTrace(),
-			Context: map[string]interface{}{
-				"url":        url,
-				"body_bytes": body,
-			},
-			InnerError: err,
-		}
-		logrus.WithError(lerr).Error("error unmarshaling srs response body")
-		return nil
-	}
-
-	if codeProber.Code != 0 {
-		lerr := cerrors.LogicError{
-			ID:         cerrors.LogicSRSRepondNonZero,
-			Message:    "srs respond with non-zero code, check your parameter",
-			StackTrace: cerrors.GetStackTrace(),
-			Context: map[string]interface{}{
-				"url":  url,
-				"code": codeProber.Code,
-			},
-			InnerError: err,
-		}
-		logrus.WithError(lerr).Error("srs respond with non-zero code")
-		return nil
-	}
-
-	data := &SRSClientInfoResponse{}
-	err = json.Unmarshal(body, data)
-	if err != nil {
-		lerr := cerrors.LogicError{
-			ID:         cerrors.LogicUnmarshalingHttpResponseBodyError,
-			Message:    "error unmarshaling srs response, check your struct declear (service.SRSClientInfoResponse)",
-			StackTrace: cerrors.GetStackTrace(),
-			Context: map[string]interface{}{
-				"url":        url,
-				"body_bytes": body,
-			},
-			InnerError: err,
-		}
-		logrus.WithError(lerr).Error("error unmarshaling srs response body")
-		return nil
-	}
-
-	if data.Client.Stream == "" {
+	if client.StreamID == "" {
 		logrus.Error("responded stream id is empty, check your code")
 		return nil
 	}
 
-	cerr := models.RecordRoomStreamID(room.ID, data.Client.Stream)
+	cerr := models.RecordRoomStreamID(room.ID, client.StreamID)
 	if cerr != nil {
 		logrus.WithError(cerr).Error("error writing room stream id")
 		return nil
 	}
-	return &data.Client.Stream
+	return &client.StreamID
 }
 
 type SRSStreamInfo struct {
@@ -460,10 +563,32 @@ type SRSStreamInfo struct {
 	} `json:"audio"`
 }
 
-type SRSStreamInfoReponse struct {
-	Code   int           `json:"code"`
-	Server string        `json:"server"`
-	Stream SRSStreamInfo `json:"stream"`
+func newSRSStreamInfo(stream *srs.Stream) *SRSStreamInfo {
+	info := &SRSStreamInfo{
+		ID:        stream.ID,
+		Name:      stream.Name,
+		Vhost:     stream.VHostID,
+		App:       stream.App,
+		LiveMs:    int64(stream.LiveTimestamp),
+		Clients:   int(stream.ClientCount),
+		Frames:    int(stream.FrameCount),
+		SendBytes: int(stream.SendBytes),
+		RecvBytes: int(stream.RecvBytes),
+	}
+	info.Kbps.Recv30s = int(stream.Stat.Recv30sKBytes)
+	info.Kbps.Send30s = int(stream.Stat.Send30sKBytes)
+	info.Publish.Active = stream.Publish.Active
+	info.Publish.CID = stream.Publish.CID
+	info.Video.Codec = stream.Video.Codec
+	info.Video.Profile = stream.Video.Profile
+	info.Video.Level = stream.Video.Level
+	info.Video.Width = int(stream.Video.Width)
+	info.Video.Height = int(stream.Video.Height)
+	info.Audio.Codec = stream.Audio.Codec
+	info.Audio.SampleRate = int(stream.Audio.SampleRate)
+	info.Audio.Channel = int(stream.Audio.Channel)
+	info.Audio.Profile = stream.Audio.Profile
+	return info
 }
 
 func GetRoomStreamSRSInfo(room *models.Room) (*SRSStreamInfo, cerrors.ChocolateError) {
@@ -475,85 +600,13 @@ func GetRoomStreamSRSInfo(room *models.Room) (*SRSStreamInfo, cerrors.ChocolateE
 		}
 	}
 
-	url := fmt.Sprintf("http://srs:1985/api/v1/streams/%s", *streamId)
-	response, err := http.Get(url)
-
-	if err != nil {
-		lerr := cerrors.LogicError{
-			ID:         cerrors.LogicSRSConnectionError,
-			Message:    "error connecting to srs api, check your configuration",
-			StackTrace: cerrors.GetStackTrace(),
-			Context: map[string]interface{}{
-				"url": url,
-			},
-			InnerError: err,
-		}
-		return nil, lerr
-	}
-	body, err := ioutil.ReadAll(response.Body)
+	stream, err := GetRoomSrsServer(room).GetStream(*streamId)
 	if err != nil {
-		lerr := cerrors.LogicError{
-			ID:         cerrors.LogicReadingHttpResponseBodyError,
-			Message:    "error reading srs api response, check your configuration",
-			StackTrace: cerrors.GetStackTrace(),
-			Context: map[string]interface{}{
-				"url": url,
-			},
-			InnerError: err,
-		}
-		return nil, lerr
-	}
-
-	codeProber := &struct {
-		Code uint `json:"code"`
-	}{}
-
-	err = json.Unmarshal(body, codeProber)
-	if err != nil {
-		lerr := cerrors.LogicError{
-			ID:         cerrors.LogicUnmarshalingHttpResponseBodyError,
-			Message:    "error probing srs response code, check your struct declear",
-			StackTrace: cerrors.GetStackTrace(),
-			Context: map[string]interface{}{
-				"url":        url,
-				"body_bytes": body,
-			},
-			InnerError: err,
-		}
-		return nil, lerr
-	}
-
-	if codeProber.Code != 0 {
-		lerr := cerrors.LogicError{
-			ID:         cerrors.LogicSRSRepondNonZero,
-			Message:    "srs respond with non-zero code, check your parameter",
-			StackTrace: cerrors.GetStackTrace(),
-			Context: map[string]interface{}{
-				"url":  url,
-				"code": codeProber.Code,
-			},
-			InnerError: err,
-		}
-		return nil, lerr
-	}
-
-	data := &SRSStreamInfoReponse{}
-	err = json.Unmarshal(body, data)
-	if err != nil {
-		lerr := cerrors.LogicError{
-			ID:         cerrors.LogicUnmarshalingHttpResponseBodyError,
-			Message:    "error unmarshaling srs response, check your struct declear (service.SRSStreamInfoResponse)",
-			StackTrace: cerrors.GetStackTrace(),
-			Context: map[string]interface{}{
-				"url":        url,
-				"body_bytes": body,
-			},
-			InnerError: err,
-		}
-		return nil, lerr
+		return nil, srsError("error looking up srs stream, check your configuration", err, map[string]interface{}{
+			"stream_id": *streamId,
+		})
 	}
-
-	return &data.Stream, nil
+	return newSRSStreamInfo(stream), nil
 }
 
 func PermItemAutoCompelete(user *models.User, roomId uint, permType models.PermissionSubjectType, prefix string) ([]*models.PermItemAutoCompeleteItem, cerrors.ChocolateError) {
@@ -567,10 +620,14 @@ func PermItemAutoCompelete(user *models.User, roomId uint, permType models.Permi
 	room, err := models.GetRoomByID(roomId, []string{})
 	if err != nil {
 		return nil, err
-	} else if room.OwnerID != user.ID {
+	}
+	abilities, err := GetRoomAbilities(room, user)
+	if err != nil {
+		return nil, err
+	} else if !abilities.AbilityManagePermission {
 		return nil, cerrors.RequestError{
-			ID:      cerrors.RequestNotRoomOwner,
-			Message: "not room owner",
+			ID:      cerrors.RequestMissingRoomAbility,
+			Message: "not allowed to manage room permission",
 		}
 	}
 
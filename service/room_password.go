@@ -0,0 +1,235 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minRoomPasswordLen = 4
+	maxRoomPasswordLen = 64
+
+	roomAccessTicketTTL = 24 * time.Hour
+
+	// failed attempts allowed per room and client within
+	// the window.
+	maxPasswordAttempts   = 10
+	passwordAttemptWindow = 10 * time.Minute
+	// a client waits this long after the first failure,
+	// twice as long after each of the following ones.
+	passwordRetryDelay    = time.Second
+	maxPasswordRetryDelay = 5 * time.Minute
+	// failures of a room from all clients within the
+	// window worth a warning, rooms are never locked as a
+	// whole, or anyone could lock viewers out.
+	roomPasswordFailureAlert = 100
+)
+
+// ChangeRoomPassword removes the password if `password`
+// is empty, tickets issued with the old one stop working.
+func ChangeRoomPassword(id uint, password string) cerrors.ChocolateError {
+	var hash string
+	if password != "" {
+		// bcrypt takes at most 72 bytes.
+		if utf8.RuneCountInString(password) < minRoomPasswordLen || len(password) > maxRoomPasswordLen {
+			return cerrors.RequestError{
+				ID:      cerrors.RequestInvalidRoomPassword,
+				Message: fmt.Sprintf("password should be %d to %d characters", minRoomPasswordLen, maxRoomPasswordLen),
+			}
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return cerrors.RequestError{
+				ID:      cerrors.RequestInvalidRoomPassword,
+				Message: "password can not be hashed",
+			}
+		}
+		hash = string(hashed)
+	}
+
+	if err := models.SetRoomPassword(id, hash); err != nil {
+		return err
+	}
+	decisionCache.ForgetRoom(id)
+	return nil
+}
+
+// passwordFingerprint ties tickets to the password they
+// are issued with, without revealing the hash.
+func passwordFingerprint(room *models.Room) string {
+	sum := sha256.Sum256([]byte(room.Password))
+	return hex.EncodeToString(sum[:4])
+}
+
+func createRoomAccessTicket(room *models.Room, expire time.Time) string {
+	return common.CreateSignedToken(fmt.Sprintf("a=%d,e=%d,p=%s", room.ID, expire.Unix(), passwordFingerprint(room)))
+}
+
+// VerifyRoomAccessTicket is false for rooms without a
+// password.
+func VerifyRoomAccessTicket(ticket string, room *models.Room, now time.Time) bool {
+	if room.Password == "" || ticket == "" {
+		return false
+	}
+	payload, ok := common.VerifySignedToken(ticket)
+	if !ok {
+		return false
+	}
+
+	var rid uint
+	var expire int64
+	var fingerprint string
+	_, err := fmt.Sscanf(payload, "a=%d,e=%d,p=%s", &rid, &expire, &fingerprint)
+	if err != nil || rid != room.ID || now.After(time.Unix(expire, 0)) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(fingerprint), []byte(passwordFingerprint(room))) == 1
+}
+
+func roomAccessCookieName(roomId uint) string {
+	return fmt.Sprintf("room_access_%d", roomId)
+}
+
+// GetRoomAccessTicket reads the ticket from the `access`
+// query, or the cookie set when unlocking the room.
+func GetRoomAccessTicket(c *gin.Context, roomId uint) string {
+	if ticket := c.Query("access"); ticket != "" {
+		return ticket
+	}
+	ticket, _ := c.Cookie(roomAccessCookieName(roomId))
+	return ticket
+}
+
+var passwordAttempts = struct {
+	sync.Mutex
+	// room, or room and client -> failures within the
+	// window
+	failures map[string][]time.Time
+	sweptAt  time.Time
+}{failures: map[string][]time.Time{}}
+
+// passwordFailures records a failure if `failed`, and
+// returns the failures of `key` within the window, oldest
+// first.
+func passwordFailures(key string, now time.Time, failed bool) []time.Time {
+	passwordAttempts.Lock()
+	defer passwordAttempts.Unlock()
+
+	if now.Sub(passwordAttempts.sweptAt) >= passwordAttemptWindow {
+		sweepPasswordAttempts(now)
+	}
+
+	recent := passwordAttempts.failures[key][:0]
+	for _, at := range passwordAttempts.failures[key] {
+		if now.Sub(at) < passwordAttemptWindow {
+			recent = append(recent, at)
+		}
+	}
+	if failed {
+		recent = append(recent, now)
+	}
+	if len(recent) == 0 {
+		delete(passwordAttempts.failures, key)
+		return nil
+	}
+	passwordAttempts.failures[key] = recent
+	return append([]time.Time(nil), recent...)
+}
+
+// passwordRetryAfter records a failure of the client if
+// `failed`, and tells how long it must wait before trying
+// again, 0 if it may try now.
+func passwordRetryAfter(key string, now time.Time, failed bool) time.Duration {
+	recent := passwordFailures(key, now, failed)
+	if len(recent) == 0 {
+		return 0
+	}
+	var until time.Time
+	if len(recent) >= maxPasswordAttempts {
+		until = recent[len(recent)-maxPasswordAttempts].Add(passwordAttemptWindow)
+	} else {
+		delay := maxPasswordRetryDelay
+		if shift := len(recent) - 1; shift < 16 && passwordRetryDelay<<shift < delay {
+			delay = passwordRetryDelay << shift
+		}
+		until = recent[len(recent)-1].Add(delay)
+	}
+	if wait := until.Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// sweepPasswordAttempts drops the keys not tried within
+// the window, which are never looked up again otherwise.
+// Must be called with the lock held.
+func sweepPasswordAttempts(now time.Time) {
+	for key, failures := range passwordAttempts.failures {
+		if len(failures) == 0 || now.Sub(failures[len(failures)-1]) >= passwordAttemptWindow {
+			delete(passwordAttempts.failures, key)
+		}
+	}
+	passwordAttempts.sweptAt = now
+}
+
+func tooManyPasswordAttemptsError(wait time.Duration) cerrors.ChocolateError {
+	return cerrors.RequestError{
+		ID:      cerrors.RequestTooManyPasswordAttempts,
+		Message: fmt.Sprintf("too many wrong passwords, try again in %d seconds", int(math.Ceil(wait.Seconds()))),
+	}
+}
+
+// UnlockRoom checks the password and returns a ticket
+// admitting the holder as IsViewerAllowedForRoom tells.
+func UnlockRoom(room *models.Room, password string, client string) (string, time.Time, cerrors.ChocolateError) {
+	if room.Password == "" {
+		return "", time.Time{}, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidRoomPassword,
+			Message: "the room is not password protected",
+		}
+	}
+
+	now := time.Now()
+	key := fmt.Sprintf("%d/%s", room.ID, client)
+	if wait := passwordRetryAfter(key, now, false); wait > 0 {
+		return "", time.Time{}, tooManyPasswordAttemptsError(wait)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(room.Password), []byte(password)) != nil {
+		passwordRetryAfter(key, now, true)
+		if failures := passwordFailures(fmt.Sprintf("%d", room.ID), now, true); len(failures) == roomPasswordFailureAlert {
+			logrus.WithField("room_id", room.ID).Warnf("%d wrong room passwords within %v", len(failures), passwordAttemptWindow)
+		}
+		return "", time.Time{}, cerrors.RequestError{
+			ID:      cerrors.RequestWrongRoomPassword,
+			Message: "wrong password",
+		}
+	}
+
+	expire := now.Add(roomAccessTicketTTL)
+	return createRoomAccessTicket(room, expire), expire, nil
+}
+
+// RoomNeedsPassword tells whether the password may admit
+// a viewer the ticket fails to.
+func RoomNeedsPassword(room *models.Room, accessTicket string) bool {
+	return room.Password != "" && !VerifyRoomAccessTicket(accessTicket, room, time.Now())
+}
+
+// SetRoomAccessCookie lets browsers send the ticket along
+// without touching the urls.
+func SetRoomAccessCookie(c *gin.Context, roomId uint, ticket string) {
+	c.SetCookie(roomAccessCookieName(roomId), ticket, int(roomAccessTicketTTL.Seconds()), "/api", "", false, true)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestRoomPassword(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("letmein"), bcrypt.MinCost)
+	room := &models.Room{Model: gorm.Model{ID: 5}, PermissionType: models.RoomPermissionWhitelist, Password: string(hash)}
+	guest := NewAccessSubject(nil, time.Now())
+
+	if _, _, err := UnlockRoom(room, "wrong", "10.0.0.1"); err == nil {
+		t.Fatal("wrong passwords should be rejected")
+	}
+	// the client failed just now has to wait.
+	ticket, expire, err := UnlockRoom(room, "letmein", "10.0.0.2")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	now := time.Now()
+	if !VerifyRoomAccessTicket(ticket, room, now) || VerifyRoomAccessTicket(ticket, room, expire.Add(time.Second)) {
+		t.Fatal("the ticket should be valid until it expires")
+	}
+	if _, allowed := decideRoomAccess(room, nil, nil, guest, true); !allowed {
+		t.Fatal("the password should admit guests into whitelist rooms")
+	}
+
+	changed, _ := bcrypt.GenerateFromPassword([]byte("letmein"), bcrypt.MinCost)
+	room.Password = string(changed)
+	if VerifyRoomAccessTicket(ticket, room, now) {
+		t.Fatal("changing the password should revoke tickets")
+	}
+	room.Password = ""
+	if VerifyRoomAccessTicket(ticket, room, now) {
+		t.Fatal("removing the password should revoke tickets")
+	}
+}
+
+func TestPasswordAttempts(t *testing.T) {
+	now := time.Now()
+	if wait := passwordRetryAfter("1/client", now, true); wait != passwordRetryDelay {
+		t.Fatalf("the first failure should wait %v, got %v", passwordRetryDelay, wait)
+	}
+	if wait := passwordRetryAfter("1/client", now, true); wait != 2*passwordRetryDelay {
+		t.Fatalf("the delay should double, got %v", wait)
+	}
+	if wait := passwordRetryAfter("1/client", now.Add(2*passwordRetryDelay), false); wait != 0 {
+		t.Fatalf("the client should try again after the delay, got %v", wait)
+	}
+	for i := 2; i < maxPasswordAttempts; i++ {
+		passwordRetryAfter("1/client", now, true)
+	}
+	if wait := passwordRetryAfter("1/client", now.Add(maxPasswordRetryDelay), false); wait != passwordAttemptWindow-maxPasswordRetryDelay {
+		t.Fatalf("the client should be throttled until failures leave the window, got %v", wait)
+	}
+	if wait := passwordRetryAfter("2/client", now, false); wait != 0 {
+		t.Fatal("other rooms should not be throttled")
+	}
+	if wait := passwordRetryAfter("1/client", now.Add(passwordAttemptWindow), false); wait != 0 {
+		t.Fatal("failures out of the window should be forgotten")
+	}
+}
+
+func TestRoomPasswordAttempts(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("letmein"), bcrypt.MinCost)
+	room := &models.Room{Model: gorm.Model{ID: 6}, Password: string(hash)}
+	for i := 0; i < roomPasswordFailureAlert; i++ {
+		UnlockRoom(room, "wrong", fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+	if _, _, err := UnlockRoom(room, "letmein", "10.1.0.1"); err != nil {
+		t.Fatalf("failures of others should not lock the room, got %v", err)
+	}
+	_, _, err := UnlockRoom(room, "letmein", "10.0.0.0")
+	if rerr, ok := err.(cerrors.RequestError); !ok || rerr.ID != cerrors.RequestTooManyPasswordAttempts {
+		t.Fatalf("the client failed just now should wait, got %v", err)
+	}
+}
+
+func TestPasswordAttemptsSweep(t *testing.T) {
+	now := time.Now()
+	passwordRetryAfter("7/stale", now, true)
+	passwordRetryAfter("8/client", now.Add(passwordAttemptWindow*2), false)
+
+	passwordAttempts.Lock()
+	_, kept := passwordAttempts.failures["7/stale"]
+	passwordAttempts.Unlock()
+	if kept {
+		t.Fatal("stale failures should be swept")
+	}
+}
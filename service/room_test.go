@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/sheey11/chocolate/models"
+	"gorm.io/gorm"
+)
+
+func TestRoomVisibility(t *testing.T) {
+	owner := &models.User{Model: gorm.Model{ID: 1}}
+	admin := &models.User{Model: gorm.Model{ID: 2}, Role: models.Role{AbilityManageRoom: true}}
+	viewer := &models.User{Model: gorm.Model{ID: 3}}
+
+	member := &models.User{Model: gorm.Model{ID: 4}}
+	isMember := func(_ *models.Room, user *models.User) bool { return user.ID == member.ID }
+
+	room := &models.Room{OwnerID: owner.ID, Visibility: models.RoomVisibilityUnlisted}
+	if !roomVisibleTo(room, nil, isMember) || !roomVisibleTo(room, viewer, isMember) {
+		t.Fatal("unlisted rooms should be reachable by anyone")
+	}
+
+	room.Visibility = models.RoomVisibilityPrivate
+	if roomVisibleTo(room, nil, isMember) || roomVisibleTo(room, viewer, isMember) {
+		t.Fatal("private rooms should be hidden from others")
+	}
+	if !IsRoomVisibleToUser(room, owner) || !IsRoomVisibleToUser(room, admin) {
+		t.Fatal("private rooms should be visible to the owner and admins")
+	}
+	if !roomVisibleTo(room, member, isMember) {
+		t.Fatal("private rooms should be visible to members")
+	}
+	if followedRoomFilter(viewer, isMember)(room) || !followedRoomFilter(owner, isMember)(room) {
+		t.Fatal("followed private rooms should not be listed to others")
+	}
+	if !followedRoomFilter(admin, isMember)(room) || !followedRoomFilter(member, isMember)(room) {
+		t.Fatal("followed private rooms should be listed to admins and members")
+	}
+}
+
+func TestRoomAbilities(t *testing.T) {
+	owner := &models.User{Model: gorm.Model{ID: 1}}
+	admin := &models.User{Model: gorm.Model{ID: 2}, Role: models.Role{AbilityManageRoom: true}}
+	room := &models.Room{OwnerID: owner.ID}
+
+	for _, user := range []*models.User{owner, admin} {
+		abilities, err := GetRoomAbilities(room, user)
+		if err != nil || abilities != models.FullRoomAbilities {
+			t.Fatalf("the owner and admins should hold every ability, got %v, %v", abilities, err)
+		}
+	}
+	if abilities, _ := GetRoomAbilities(room, nil); abilities != (models.RoomAbilities{}) {
+		t.Fatalf("guests should hold no ability, got %v", abilities)
+	}
+
+	moderator := *models.RoomMemberRoleModerator.Abilities()
+	if !moderator.Covers(models.RoomAbilities{AbilityModerateChat: true}) {
+		t.Fatal("moderators should moderate chat")
+	}
+	if moderator.Covers(models.RoomAbilities{AbilityModerateChat: true, AbilityEditRoom: true}) {
+		t.Fatal("moderators should not edit the room")
+	}
+	if models.RoomMemberRole("owner").Abilities() != nil {
+		t.Fatal("unknown roles should hold no ability")
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+)
+
+const (
+	roomTransferTTL = 7 * 24 * time.Hour
+
+	maxRoomTemplates       = 20
+	maxRoomTemplateNameLen = 32
+)
+
+// userMaxRooms is the room cap of the user when creating
+// or receiving a room, administrators are not capped.
+func userMaxRooms(user *models.User) *uint {
+	if user.RoleName == models.AdministratorRoleName {
+		return nil
+	}
+	return &user.MaxRoomCount
+}
+
+func checkRecipientRoomCount(user *models.User) cerrors.ChocolateError {
+	max := userMaxRooms(user)
+	if max == nil {
+		return nil
+	}
+	count, err := user.GetAfflicateRoomCount()
+	if err != nil {
+		return err
+	}
+	if count >= *max {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestRoomCountReachedMax,
+			Message: "the recipient has reached the max rooms allowed",
+		}
+	}
+	return nil
+}
+
+// OfferRoomTransfer replaces the pending transfer of the
+// room, the room changes hands once `username` accepts.
+func OfferRoomTransfer(room *models.Room, username string) (*models.RoomTransfer, cerrors.ChocolateError) {
+	to, err := models.GetUserByName(username, nil)
+	if err != nil {
+		return nil, err
+	}
+	if to.ID == room.OwnerID {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidRoomTransfer,
+			Message: "the room is owned by the user already",
+		}
+	}
+	// checked again when accepting, this is to tell early.
+	if err := checkRecipientRoomCount(to); err != nil {
+		return nil, err
+	}
+
+	transfer := &models.RoomTransfer{
+		CreatedAt: time.Now(),
+		RoomID:    room.ID,
+		FromID:    room.OwnerID,
+		ToID:      to.ID,
+		ExpiresAt: time.Now().Add(roomTransferTTL),
+	}
+	if err := models.CreateRoomTransfer(transfer); err != nil {
+		return nil, err
+	}
+	// with the users loaded.
+	return models.GetRoomTransfer(room.ID)
+}
+
+type RoomTransferInfo struct {
+	RoomID    uint      `json:"room_id"`
+	RoomTitle string    `json:"room_title"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewRoomTransferInfo expects the room and both users
+// loaded.
+func NewRoomTransferInfo(transfer *models.RoomTransfer) RoomTransferInfo {
+	return RoomTransferInfo{
+		RoomID:    transfer.RoomID,
+		RoomTitle: transfer.Room.Title,
+		From:      transfer.From.Username,
+		To:        transfer.To.Username,
+		CreatedAt: transfer.CreatedAt,
+		ExpiresAt: transfer.ExpiresAt,
+	}
+}
+
+func GetRoomTransfer(roomId uint) (*models.RoomTransfer, cerrors.ChocolateError) {
+	return models.GetRoomTransfer(roomId)
+}
+
+func CancelRoomTransfer(roomId uint) cerrors.ChocolateError {
+	return models.DeleteRoomTransfer(roomId)
+}
+
+func ListIncomingRoomTransfers(user *models.User) ([]*models.RoomTransfer, cerrors.ChocolateError) {
+	return models.ListIncomingRoomTransfers(user.ID, time.Now())
+}
+
+// pendingTransferFor returns the transfer of the room if
+// it is offered to the user and not expired, others are
+// not told apart from no transfer at all.
+func pendingTransferFor(roomId uint, user *models.User, now time.Time) (*models.RoomTransfer, cerrors.ChocolateError) {
+	transfer, err := models.GetRoomTransfer(roomId)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.ToID != user.ID || !now.Before(transfer.ExpiresAt) {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestRoomTransferNotFound,
+			Message: "no pending transfer of the room",
+		}
+	}
+	return transfer, nil
+}
+
+func AcceptRoomTransfer(roomId uint, user *models.User) (*models.Room, cerrors.ChocolateError) {
+	if _, err := pendingTransferFor(roomId, user, time.Now()); err != nil {
+		return nil, err
+	}
+	return transferRoom(roomId, user, user.ID)
+}
+
+func DeclineRoomTransfer(roomId uint, user *models.User) cerrors.ChocolateError {
+	if _, err := pendingTransferFor(roomId, user, time.Now()); err != nil {
+		return err
+	}
+	return models.DeleteRoomTransfer(roomId)
+}
+
+// ForceTransferRoom is for admins, it skips the offer but
+// still respects the room cap of the recipient.
+func ForceTransferRoom(roomId uint, username string, operator uint) (*models.Room, cerrors.ChocolateError) {
+	to, err := models.GetUserByName(username, nil)
+	if err != nil {
+		return nil, err
+	}
+	room, err := GetRoomByID(roomId)
+	if err != nil {
+		return nil, err
+	}
+	if to.ID == room.OwnerID {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidRoomTransfer,
+			Message: "the room is owned by the user already",
+		}
+	}
+	return transferRoom(roomId, to, operator)
+}
+
+// transferRoom cuts off the stream and relays of the
+// previous owner, whose push key stops working.
+func transferRoom(roomId uint, to *models.User, operator uint) (*models.Room, cerrors.ChocolateError) {
+	if err := models.TransferRoom(roomId, to, userMaxRooms(to)); err != nil {
+		return nil, err
+	}
+	decisionCache.ForgetRoom(roomId)
+	StopRoomForwarding(roomId)
+
+	room, err := GetRoomByID(roomId)
+	if err != nil {
+		return nil, err
+	}
+	if room.Status == models.RoomStatusStreaming {
+		CutOffStreamWithReason(room, operator, "the room has been transferred")
+	}
+	return room, nil
+}
+
+// SaveRoomTemplate names the room as a template of the
+// user, see models.CreateRoomFromTemplate for what is
+// copied.
+func SaveRoomTemplate(room *models.Room, user *models.User, name string) (*models.RoomTemplate, cerrors.ChocolateError) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxRoomTemplateNameLen {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidRoomTemplate,
+			Message: "template name should be 1 to 32 characters",
+		}
+	}
+
+	templates, err := models.ListRoomTemplates(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) >= maxRoomTemplates {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestInvalidRoomTemplate,
+			Message: "you have saved max templates allowed",
+		}
+	}
+	for _, template := range templates {
+		if template.Name == name {
+			return nil, cerrors.RequestError{
+				ID:      cerrors.RequestInvalidRoomTemplate,
+				Message: "template name is used",
+			}
+		}
+	}
+
+	template := &models.RoomTemplate{
+		OwnerID: user.ID,
+		Name:    name,
+		RoomID:  room.ID,
+	}
+	if err := models.CreateRoomTemplate(template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func ListRoomTemplates(user *models.User) ([]*models.RoomTemplate, cerrors.ChocolateError) {
+	return models.ListRoomTemplates(user.ID)
+}
+
+func DeleteRoomTemplate(user *models.User, id uint) cerrors.ChocolateError {
+	return models.DeleteRoomTemplate(user.ID, id)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sheey11/chocolate/models"
+	"gorm.io/gorm"
+)
+
+func TestUserMaxRooms(t *testing.T) {
+	user := &models.User{RoleName: models.UserRoleName, MaxRoomCount: 3}
+	if max := userMaxRooms(user); max == nil || *max != 3 {
+		t.Fatalf("users should be capped by their max room count, got %v", max)
+	}
+	user.MaxRoomCount = 0
+	if max := userMaxRooms(user); max == nil || *max != 0 {
+		t.Fatal("a zero max room count should not mean unlimited")
+	}
+	admin := &models.User{RoleName: models.AdministratorRoleName}
+	if userMaxRooms(admin) != nil {
+		t.Fatal("administrators should not be capped")
+	}
+}
+
+func TestRoomTemplateName(t *testing.T) {
+	room := &models.Room{Model: gorm.Model{ID: 1}}
+	user := &models.User{Model: gorm.Model{ID: 1}}
+	for _, name := range []string{"", "   ", strings.Repeat("x", maxRoomTemplateNameLen+1)} {
+		if _, err := SaveRoomTemplate(room, user, name); err == nil {
+			t.Fatalf("template name %q should be rejected", name)
+		}
+	}
+}
+
+func TestRoomFromTemplate(t *testing.T) {
+	creator := &models.User{Model: gorm.Model{ID: 1}}
+	other := uint(2)
+	source := &models.Room{Password: "hash", MaxViewers: 5, Members: []models.RoomMember{{UserID: other}}}
+	items := []models.PermissionItem{{SubjectType: models.PermissionSubjectTypeUser, SubjectUserID: &other, CreatedBy: &other}}
+
+	room := models.NewRoomFromTemplate(creator, "copy", source, items)
+	if room.Password != "" || len(room.Members) != 0 {
+		t.Fatal("the password and members should not be copied")
+	}
+	if room.MaxViewers != 5 || len(room.PermissionItems) != 1 {
+		t.Fatal("settings and permission items should be copied")
+	}
+	if by := room.PermissionItems[0].CreatedBy; by == nil || *by != creator.ID {
+		t.Fatalf("copied items should be credited to the creator, got %v", by)
+	}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/srs"
+)
+
+func roomRtcQuery(room *models.Room) url.Values {
+	return url.Values{
+		"app":    []string{"live"},
+		"stream": []string{strconv.FormatUint(uint64(room.ID), 10)},
+	}
+}
+
+func exchangeRtcSdp(room *models.Room, kind srs.RtcKind, query url.Values, offer []byte) (*srs.RtcAnswer, cerrors.ChocolateError) {
+	answer, err := GetRoomSrsServer(room).ExchangeSdp(kind, query, offer)
+	if err != nil {
+		return nil, cerrors.LogicError{
+			ID:         cerrors.LogicSRSConnectionError,
+			Message:    "error exchanging sdp with srs rtc api, check your configuration",
+			StackTrace: cerrors.GetStackTrace(),
+			InnerError: err,
+			Context: map[string]interface{}{
+				"kind":  kind,
+				"query": query.Encode(),
+			},
+		}
+	}
+	return answer, nil
+}
+
+// webrtc players send nothing chocolate sees once the
+// session is up, it ends on teardown or the on_stop hook,
+// and expires after this in case neither comes.
+const rtcPlaybackSessionTTL = 12 * time.Hour
+
+func rtcPlaybackSessionKey(session string) string {
+	return "whep/" + session
+}
+
+// ExchangeRoomPlaySdp forwards the WHEP offer of a viewer,
+// the caller must have checked the viewer is allowed.
+// The session counts against the playback caps.
+// `u` and `s` queries are carried to the on_play hook to
+// record watching sessions, same as flv playback does.
+func ExchangeRoomPlaySdp(room *models.Room, user *models.User, offer []byte) (*srs.RtcAnswer, cerrors.ChocolateError) {
+	query := roomRtcQuery(room)
+	uid := uint(0)
+	if user != nil {
+		uid = user.ID
+	}
+	session := uuid.New().String()
+	if err := StartPlaybackSession(rtcPlaybackSessionKey(session), room, user, rtcPlaybackSessionTTL); err != nil {
+		return nil, err
+	}
+	query.Set("u", fmt.Sprintf("%d", uid))
+	query.Set("s", session)
+	answer, err := exchangeRtcSdp(room, srs.RtcKindWhep, query, offer)
+	if err != nil {
+		EndPlaybackSession(rtcPlaybackSessionKey(session))
+		return nil, err
+	}
+	answer.ResourceQuery = signRtcResource(room, srs.RtcKindWhep, answer.ResourceQuery, session)
+	return answer, nil
+}
+
+// ExchangeRoomPublishSdp checks the push key and forwards
+// the WHIP offer, srs will then call the on_publish hook
+// with the room uid and key as usual.
+func ExchangeRoomPublishSdp(room *models.Room, key string, offer []byte) (*srs.RtcAnswer, cerrors.ChocolateError) {
+	if room.Status != models.RoomStatusStreaming || !pushKeyMatches(room, key) {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestPermissionDenied,
+			Message: "invalid stream key or room is not streaming",
+		}
+	}
+
+	query := roomRtcQuery(room)
+	query.Set("uid", room.UID)
+	query.Set("key", room.PushKey)
+	answer, err := exchangeRtcSdp(room, srs.RtcKindWhip, query, offer)
+	if err != nil {
+		return nil, err
+	}
+	answer.ResourceQuery = signRtcResource(room, srs.RtcKindWhip, answer.ResourceQuery, "")
+	return answer, nil
+}
+
+func pushKeyMatches(room *models.Room, key string) bool {
+	return key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(room.PushKey)) == 1
+}
+
+// the session resource carries a signature of itself,
+// so only who created the session can delete it.
+const rtcResourceParam = "rtc"
+
+// rtcResourceDigest covers the resource as srs gives it,
+// the signature is left out.
+func rtcResourceDigest(resource url.Values) string {
+	values := url.Values{}
+	for k, v := range resource {
+		if k != rtcResourceParam {
+			values[k] = v
+		}
+	}
+	sum := sha256.Sum256([]byte(values.Encode()))
+	return hex.EncodeToString(sum[:16])
+}
+
+func signRtcResource(room *models.Room, kind srs.RtcKind, resourceQuery string, session string) string {
+	resource, err := url.ParseQuery(resourceQuery)
+	if err != nil || resourceQuery == "" {
+		return resourceQuery
+	}
+	payload := fmt.Sprintf("rtc=%d,k=%s,h=%s,s=%s", room.ID, kind, rtcResourceDigest(resource), session)
+	resource.Set(rtcResourceParam, common.CreateSignedToken(payload))
+	return resource.Encode()
+}
+
+// verifyRtcResource returns the playback session the
+// resource is signed with, empty for publish sessions.
+func verifyRtcResource(room *models.Room, kind srs.RtcKind, resource url.Values) (string, bool) {
+	payload, ok := common.VerifySignedToken(resource.Get(rtcResourceParam))
+	if !ok {
+		return "", false
+	}
+	prefix := fmt.Sprintf("rtc=%d,k=%s,h=%s,s=", room.ID, kind, rtcResourceDigest(resource))
+	if !strings.HasPrefix(payload, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(payload, prefix), true
+}
+
+// TeardownRoomPlaySession deletes a WHEP session, the
+// caller must have checked the viewer is allowed.
+func TeardownRoomPlaySession(room *models.Room, resource url.Values) (int, cerrors.ChocolateError) {
+	session, ok := verifyRtcResource(room, srs.RtcKindWhep, resource)
+	if !ok {
+		return 0, invalidRtcResourceError()
+	}
+	status, err := teardownRoomRtcSession(room, srs.RtcKindWhep, resource)
+	if err == nil {
+		EndPlaybackSession(rtcPlaybackSessionKey(session))
+	}
+	return status, err
+}
+
+// EndRtcPlaybackSession is for the on_stop hook, `session`
+// is the `s` query given when exchanging sdp.
+func EndRtcPlaybackSession(session string) {
+	if session != "" {
+		EndPlaybackSession(rtcPlaybackSessionKey(session))
+	}
+}
+
+// TeardownRoomPublishSession deletes a WHIP session, the
+// push key is required as when publishing.
+func TeardownRoomPublishSession(room *models.Room, key string, resource url.Values) (int, cerrors.ChocolateError) {
+	if !pushKeyMatches(room, key) {
+		return 0, cerrors.RequestError{
+			ID:      cerrors.RequestPermissionDenied,
+			Message: "invalid stream key",
+		}
+	}
+	if _, ok := verifyRtcResource(room, srs.RtcKindWhip, resource); !ok {
+		return 0, invalidRtcResourceError()
+	}
+	return teardownRoomRtcSession(room, srs.RtcKindWhip, resource)
+}
+
+func invalidRtcResourceError() cerrors.ChocolateError {
+	return cerrors.RequestError{
+		ID:      cerrors.RequestPermissionDenied,
+		Message: "invalid session resource",
+	}
+}
+
+// `resource` is the query of the session resource url
+// returned when exchanging sdp.
+func teardownRoomRtcSession(room *models.Room, kind srs.RtcKind, resource url.Values) (int, cerrors.ChocolateError) {
+	query := roomRtcQuery(room)
+	for k, v := range resource {
+		if k == "app" || k == "stream" || k == rtcResourceParam {
+			continue
+		}
+		query[k] = v
+	}
+
+	status, err := GetRoomSrsServer(room).TeardownRtcSession(kind, query)
+	if err != nil {
+		return 0, cerrors.LogicError{
+			ID:         cerrors.LogicSRSConnectionError,
+			Message:    "error tearing down srs rtc session",
+			StackTrace: cerrors.GetStackTrace(),
+			InnerError: err,
+			Context: map[string]interface{}{
+				"kind":    kind,
+				"room_id": room.ID,
+			},
+		}
+	}
+	return status, nil
+}
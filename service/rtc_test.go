@@ -0,0 +1,59 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/sheey11/chocolate/models"
+	"github.com/sheey11/chocolate/srs"
+	"gorm.io/gorm"
+)
+
+func TestRtcResourceSignature(t *testing.T) {
+	room := &models.Room{Model: gorm.Model{ID: 3}}
+	signed, _ := url.ParseQuery(signRtcResource(room, srs.RtcKindWhep, "action=delete&token=abc&session=1", "s1"))
+
+	if session, ok := verifyRtcResource(room, srs.RtcKindWhep, signed); !ok || session != "s1" {
+		t.Fatalf("the resource should be accepted, got %q, %v", session, ok)
+	}
+	if _, ok := verifyRtcResource(room, srs.RtcKindWhip, signed); ok {
+		t.Fatal("the resource should not be accepted as another kind")
+	}
+	if _, ok := verifyRtcResource(&models.Room{Model: gorm.Model{ID: 4}}, srs.RtcKindWhep, signed); ok {
+		t.Fatal("the resource should not be accepted by other rooms")
+	}
+
+	// the signature of one session can not delete another.
+	signed.Set("session", "2")
+	if _, ok := verifyRtcResource(room, srs.RtcKindWhep, signed); ok {
+		t.Fatal("a changed resource should be rejected")
+	}
+	unsigned, _ := url.ParseQuery("action=delete&token=abc&session=1")
+	if _, ok := verifyRtcResource(room, srs.RtcKindWhep, unsigned); ok {
+		t.Fatal("an unsigned resource should be rejected")
+	}
+}
+
+func TestPushKeyMatches(t *testing.T) {
+	room := &models.Room{PushKey: "secret"}
+	if !pushKeyMatches(room, "secret") || pushKeyMatches(room, "secre") || pushKeyMatches(room, "") {
+		t.Fatal("only the push key should match")
+	}
+	if pushKeyMatches(&models.Room{}, "") {
+		t.Fatal("empty keys should never match")
+	}
+}
+
+func TestRtcPlaybackSessionCaps(t *testing.T) {
+	room := &models.Room{Model: gorm.Model{ID: 9}, MaxViewers: 1}
+	if err := StartPlaybackSession(rtcPlaybackSessionKey("s1"), room, nil, rtcPlaybackSessionTTL); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if CheckPlaybackCaps(room, nil) == nil {
+		t.Fatal("whep sessions should count against the room cap")
+	}
+	EndRtcPlaybackSession("s1")
+	if err := CheckPlaybackCaps(room, nil); err != nil {
+		t.Fatalf("stopped whep sessions should not count, got %v", err)
+	}
+}
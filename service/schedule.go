@@ -0,0 +1,481 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+	"github.com/sheey11/chocolate/common"
+	cerrors "github.com/sheey11/chocolate/errors"
+	"github.com/sheey11/chocolate/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxSchedulesPerRoom = 50
+	// minutes
+	maxScheduleDuration = 24 * 60
+	// a publish this early before an occurrence is still
+	// linked to it.
+	scheduleLinkLeeway = 30 * time.Minute
+	// occurrences of a recurrent schedule expanded at most
+	// in a single query.
+	maxScheduleOccurrences = 1000
+	// the calendar feeds cover this long ago, so recent
+	// events do not disappear from calendars immediately.
+	calendarLookBehind = 30 * 24 * time.Hour
+	calendarLookAhead  = 90 * 24 * time.Hour
+)
+
+// scheduleOccurrences returns start times of occurrences
+// overlapping with [from, to), in UTC.
+func scheduleOccurrences(s *models.Schedule, from time.Time, to time.Time) []time.Time {
+	start := s.StartTime.UTC()
+	duration := s.DurationTime()
+
+	days := 0
+	switch s.Recurrence {
+	case models.ScheduleRecurrenceDaily:
+		days = 1
+	case models.ScheduleRecurrenceWeekly:
+		days = 7
+	default:
+		if start.Before(to) && start.Add(duration).After(from) {
+			return []time.Time{start}
+		}
+		return []time.Time{}
+	}
+
+	// skip occurrences ending before `from`, starting one
+	// period earlier to be safe.
+	k := 0
+	if skip := from.Sub(start.Add(duration)); skip > 0 {
+		k = lo.Max([]int{0, int(skip/(time.Duration(days)*24*time.Hour)) - 1})
+	}
+
+	result := []time.Time{}
+	for ; len(result) < maxScheduleOccurrences; k++ {
+		occurrence := start.AddDate(0, 0, k*days)
+		if !occurrence.Before(to) || (s.RecurrenceEnd != nil && !occurrence.Before(*s.RecurrenceEnd)) {
+			break
+		}
+		if occurrence.Add(duration).After(from) {
+			result = append(result, occurrence)
+		}
+	}
+	return result
+}
+
+// ScheduleOccurrence is an occurrence of a schedule, as
+// listed publicly.
+type ScheduleOccurrence struct {
+	ScheduleID    uint      `json:"schedule_id"`
+	RoomID        uint      `json:"room_id"`
+	RoomTitle     string    `json:"room_title"`
+	OwnerUsername string    `json:"owner_username"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	// seconds before the start, negative if started.
+	StartsIn  int64 `json:"starts_in"`
+	Streaming bool  `json:"streaming"`
+}
+
+func expandSchedules(schedules []*models.Schedule, from time.Time, to time.Time, now time.Time) []ScheduleOccurrence {
+	result := []ScheduleOccurrence{}
+	for _, s := range schedules {
+		for _, start := range scheduleOccurrences(s, from, to) {
+			result = append(result, ScheduleOccurrence{
+				ScheduleID:    s.ID,
+				RoomID:        s.RoomID,
+				RoomTitle:     s.Room.Title,
+				OwnerUsername: s.Room.Owner.Username,
+				Title:         s.Title,
+				Description:   s.Description,
+				StartTime:     start,
+				EndTime:       start.Add(s.DurationTime()),
+				StartsIn:      int64(start.Sub(now).Seconds()),
+				Streaming:     s.Room.Status == models.RoomStatusStreaming,
+			})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].StartTime.Before(result[j].StartTime) })
+	return result
+}
+
+// ListUpcomingStreams lists occurrences not ended yet
+// and starting within `window`, of all rooms if `roomIds`
+// is nil.
+func ListUpcomingStreams(roomIds []uint, window time.Duration, limit uint) ([]ScheduleOccurrence, cerrors.ChocolateError) {
+	return listUpcomingStreams(roomIds, window, limit, func(room *models.Room) bool {
+		// unlisted rooms are listed only to followers
+		return roomIds != nil || room.Visibility == models.RoomVisibilityPublic
+	})
+}
+
+// followedRoomFilter keeps followed rooms the user can
+// still see, a room may turn private after followed.
+func followedRoomFilter(user *models.User, isMember func(*models.Room, *models.User) bool) func(room *models.Room) bool {
+	return func(room *models.Room) bool {
+		return roomVisibleTo(room, user, isMember)
+	}
+}
+
+func listUpcomingStreams(roomIds []uint, window time.Duration, limit uint, keep func(room *models.Room) bool) ([]ScheduleOccurrence, cerrors.ChocolateError) {
+	now := time.Now()
+	schedules, err := models.ListActiveSchedules(roomIds, now, now.Add(window))
+	if err != nil {
+		return nil, err
+	}
+	// rooms deleted
+	schedules = lo.Filter(schedules, func(s *models.Schedule, _ int) bool { return s.Room.ID != 0 && keep(&s.Room) })
+
+	result := expandSchedules(schedules, now, now.Add(window), now)
+	if uint(len(result)) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+type ScheduleInfo struct {
+	ID            uint                      `json:"id"`
+	Title         string                    `json:"title"`
+	Description   string                    `json:"description"`
+	StartTime     time.Time                 `json:"start_time"`
+	Duration      uint                      `json:"duration"`
+	Recurrence    models.ScheduleRecurrence `json:"recurrence"`
+	RecurrenceEnd *time.Time                `json:"recurrence_end"`
+	// the current or next occurrence, nil if all have
+	// ended.
+	Next     *time.Time `json:"next"`
+	StartsIn *int64     `json:"starts_in"`
+}
+
+func NewScheduleInfo(s *models.Schedule, now time.Time) ScheduleInfo {
+	info := ScheduleInfo{
+		ID:            s.ID,
+		Title:         s.Title,
+		Description:   s.Description,
+		StartTime:     s.StartTime,
+		Duration:      s.Duration,
+		Recurrence:    s.Recurrence,
+		RecurrenceEnd: s.RecurrenceEnd,
+	}
+	// occurrences are at most a week apart, unless the
+	// first one is further.
+	to := now.Add(8 * 24 * time.Hour)
+	if s.StartTime.After(to) {
+		to = s.StartTime.Add(time.Second)
+	}
+	if occurrences := scheduleOccurrences(s, now, to); len(occurrences) > 0 {
+		info.Next = &occurrences[0]
+		info.StartsIn = lo.ToPtr(int64(occurrences[0].Sub(now).Seconds()))
+	}
+	return info
+}
+
+func ListRoomSchedules(roomId uint) ([]*models.Schedule, cerrors.ChocolateError) {
+	return models.ListRoomSchedules(roomId)
+}
+
+type ScheduleData struct {
+	Title         string                    `json:"title"`
+	Description   string                    `json:"description"`
+	StartTime     time.Time                 `json:"start_time"`
+	Duration      uint                      `json:"duration"`
+	Recurrence    models.ScheduleRecurrence `json:"recurrence"`
+	RecurrenceEnd *time.Time                `json:"recurrence_end"`
+}
+
+func (d *ScheduleData) validate() cerrors.ChocolateError {
+	invalid := func(message string) cerrors.ChocolateError {
+		return cerrors.RequestError{
+			ID:      cerrors.RequestInvalidSchedule,
+			Message: message,
+		}
+	}
+
+	d.Title = strings.TrimSpace(d.Title)
+	if d.Title == "" || utf8.RuneCountInString(d.Title) > 64 {
+		return invalid("title should be 1 to 64 characters")
+	}
+	if utf8.RuneCountInString(d.Description) > 1024 {
+		return invalid("description should be at most 1024 characters")
+	}
+	if d.StartTime.IsZero() {
+		return invalid("start time is required")
+	}
+	if d.Duration == 0 || d.Duration > maxScheduleDuration {
+		return invalid("duration should be 1 to 1440 minutes")
+	}
+	switch d.Recurrence {
+	case models.ScheduleRecurrenceNone:
+		d.RecurrenceEnd = nil
+	case models.ScheduleRecurrenceDaily, models.ScheduleRecurrenceWeekly:
+		if d.RecurrenceEnd != nil && !d.RecurrenceEnd.After(d.StartTime) {
+			return invalid("recurrence should end after the start time")
+		}
+	default:
+		return invalid("recurrence should be empty, daily or weekly")
+	}
+	return nil
+}
+
+func (d *ScheduleData) apply(s *models.Schedule) {
+	s.Title = d.Title
+	s.Description = d.Description
+	s.StartTime = d.StartTime
+	s.Duration = d.Duration
+	s.Recurrence = d.Recurrence
+	s.RecurrenceEnd = d.RecurrenceEnd
+}
+
+func CreateRoomSchedule(roomId uint, data *ScheduleData) (*models.Schedule, cerrors.ChocolateError) {
+	if err := data.validate(); err != nil {
+		return nil, err
+	}
+
+	count, err := models.CountRoomSchedules(roomId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxSchedulesPerRoom {
+		return nil, cerrors.RequestError{
+			ID:      cerrors.RequestScheduleCountReachedMax,
+			Message: "schedule count reached max",
+		}
+	}
+
+	schedule := &models.Schedule{RoomID: roomId}
+	data.apply(schedule)
+	if err := models.CreateSchedule(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func UpdateRoomSchedule(roomId uint, id uint, data *ScheduleData) (*models.Schedule, cerrors.ChocolateError) {
+	if err := data.validate(); err != nil {
+		return nil, err
+	}
+
+	schedule, err := models.GetSchedule(roomId, id)
+	if err != nil {
+		return nil, err
+	}
+	data.apply(schedule)
+	if err := models.UpdateSchedule(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func DeleteRoomSchedule(roomId uint, id uint) cerrors.ChocolateError {
+	return models.DeleteSchedule(roomId, id)
+}
+
+// linkBroadcastSchedule links the broadcast to the
+// occurrence of the room it is closest to, if any.
+func linkBroadcastSchedule(broadcast *models.Broadcast) {
+	schedules, err := models.ListRoomSchedules(broadcast.RoomID)
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", broadcast.RoomID).Error("error listing schedules to link broadcast")
+		return
+	}
+
+	var best *models.Schedule
+	var bestAt time.Time
+	for _, s := range schedules {
+		// occurrences the broadcast starts during or a bit
+		// before.
+		occurrences := scheduleOccurrences(s, broadcast.StartTime, broadcast.StartTime.Add(scheduleLinkLeeway))
+		for _, at := range occurrences {
+			if best == nil || absDuration(at.Sub(broadcast.StartTime)) < absDuration(bestAt.Sub(broadcast.StartTime)) {
+				best, bestAt = s, at
+			}
+		}
+	}
+	if best == nil {
+		return
+	}
+
+	err = models.LinkBroadcastSchedule(broadcast.ID, best.ID, bestAt)
+	if err != nil {
+		logrus.WithError(err).WithField("room_id", broadcast.RoomID).Error("error linking broadcast to schedule")
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func FollowRoom(uid uint, roomId uint) cerrors.ChocolateError {
+	return models.FollowRoom(uid, roomId)
+}
+
+func UnfollowRoom(uid uint, roomId uint) cerrors.ChocolateError {
+	return models.UnfollowRoom(uid, roomId)
+}
+
+// CreateCalendarToken issues the token for the feed of
+// followed rooms, calendar apps can not send headers.
+// It never expires, but is revoked by rotating, see
+// RotateCalendarToken.
+func CreateCalendarToken(user *models.User) string {
+	return createCalendarToken(user.ID, user.CalendarTokenVersion)
+}
+
+func createCalendarToken(uid uint, version uint) string {
+	return common.CreateSignedToken(fmt.Sprintf("cal=%d,v=%d", uid, version))
+}
+
+func parseCalendarToken(token string) (uint, uint, bool) {
+	payload, ok := common.VerifySignedToken(token)
+	if !ok {
+		return 0, 0, false
+	}
+	var uid, version uint
+	n, err := fmt.Sscanf(payload, "cal=%d,v=%d", &uid, &version)
+	if err != nil || n != 2 || payload != fmt.Sprintf("cal=%d,v=%d", uid, version) {
+		return 0, 0, false
+	}
+	return uid, version, true
+}
+
+// VerifyCalendarToken returns the user of the token, if
+// it is not revoked.
+func VerifyCalendarToken(token string) (uint, bool, cerrors.ChocolateError) {
+	uid, version, ok := parseCalendarToken(token)
+	if !ok {
+		return 0, false, nil
+	}
+	current, found, err := models.GetUserCalendarTokenVersion(uid)
+	if err != nil || !found || current != version {
+		return 0, false, err
+	}
+	return uid, true, nil
+}
+
+// RotateCalendarToken revokes tokens issued so far, and
+// returns the new one.
+func RotateCalendarToken(user *models.User) (string, cerrors.ChocolateError) {
+	version, err := models.RotateUserCalendarToken(user.ID)
+	if err != nil {
+		return "", err
+	}
+	return createCalendarToken(user.ID, version), nil
+}
+
+func RenderRoomCalendar(room *models.Room) (string, cerrors.ChocolateError) {
+	now := time.Now()
+	schedules, err := models.ListActiveSchedules([]uint{room.ID}, now.Add(-calendarLookBehind), now.Add(calendarLookAhead))
+	if err != nil {
+		return "", err
+	}
+	return renderICalendar(room.Title, schedules, now), nil
+}
+
+func ListFollowedUpcomingStreams(user *models.User, window time.Duration, limit uint) ([]ScheduleOccurrence, cerrors.ChocolateError) {
+	ids, err := models.ListFollowedRoomIDs(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return listUpcomingStreams(ids, window, limit, followedRoomFilter(user, isRoomMember))
+}
+
+func RenderFollowedCalendar(uid uint) (string, cerrors.ChocolateError) {
+	ids, err := models.ListFollowedRoomIDs(uid)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	schedules, err := models.ListActiveSchedules(ids, now.Add(-calendarLookBehind), now.Add(calendarLookAhead))
+	if err != nil {
+		return "", err
+	}
+	// the token only tells the user id, the role and
+	// memberships are looked up.
+	keep := followedRoomFilter(models.GetUserByID(uid), isRoomMember)
+	schedules = lo.Filter(schedules, func(s *models.Schedule, _ int) bool { return s.Room.ID != 0 && keep(&s.Room) })
+	return renderICalendar("Followed streams", schedules, now), nil
+}
+
+func escapeICalendarText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// foldICalendarLine splits lines longer than 75 octets,
+// not breaking utf-8 characters, see RFC 5545 3.1.
+func foldICalendarLine(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+const icalTimeLayout = "20060102T150405Z"
+
+// renderICalendar renders the schedules as an iCalendar,
+// recurrent schedules are rendered with rules instead
+// of being expanded.
+func renderICalendar(name string, schedules []*models.Schedule, now time.Time) string {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//chocolate//schedule//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + escapeICalendarText(name),
+	}
+	for _, s := range schedules {
+		start := s.StartTime.UTC()
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:schedule-%d@chocolate", s.ID),
+			"DTSTAMP:"+now.UTC().Format(icalTimeLayout),
+			"LAST-MODIFIED:"+s.UpdatedAt.UTC().Format(icalTimeLayout),
+			"DTSTART:"+start.Format(icalTimeLayout),
+			"DTEND:"+start.Add(s.DurationTime()).Format(icalTimeLayout),
+			"SUMMARY:"+escapeICalendarText(s.Title),
+		)
+		if s.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escapeICalendarText(s.Description))
+		}
+		if s.Room.Title != "" {
+			lines = append(lines, "LOCATION:"+escapeICalendarText(s.Room.Title))
+		}
+		if s.Recurrence != models.ScheduleRecurrenceNone {
+			rule := "RRULE:FREQ=" + strings.ToUpper(string(s.Recurrence))
+			if s.RecurrenceEnd != nil {
+				// UNTIL is inclusive while the end is not.
+				rule += ";UNTIL=" + s.RecurrenceEnd.UTC().Add(-time.Second).Format(icalTimeLayout)
+			}
+			lines = append(lines, rule)
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	return strings.Join(lo.Map(lines, func(line string, _ int) string { return foldICalendarLine(line) }), "\r\n") + "\r\n"
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sheey11/chocolate/common"
+	"github.com/sheey11/chocolate/models"
+	"gorm.io/gorm"
+)
+
+func TestScheduleOccurrences(t *testing.T) {
+	start := time.Date(2023, 6, 1, 20, 0, 0, 0, time.UTC)
+	once := &models.Schedule{StartTime: start, Duration: 60}
+
+	if o := scheduleOccurrences(once, start.Add(30*time.Minute), start.Add(time.Hour*24)); len(o) != 1 {
+		t.Fatalf("an occurrence in progress should be listed, got %v", o)
+	}
+	if o := scheduleOccurrences(once, start.Add(time.Hour), start.Add(time.Hour*24)); len(o) != 0 {
+		t.Fatalf("an ended occurrence should not be listed, got %v", o)
+	}
+
+	end := start.AddDate(0, 0, 21)
+	weekly := &models.Schedule{StartTime: start, Duration: 60, Recurrence: models.ScheduleRecurrenceWeekly, RecurrenceEnd: &end}
+	o := scheduleOccurrences(weekly, start.AddDate(0, 0, 3), start.AddDate(0, 0, 60))
+	if len(o) != 2 || !o[0].Equal(start.AddDate(0, 0, 7)) || !o[1].Equal(start.AddDate(0, 0, 14)) {
+		t.Fatalf("expected the 2nd and 3rd weeks, got %v", o)
+	}
+
+	daily := &models.Schedule{StartTime: start, Duration: 60, Recurrence: models.ScheduleRecurrenceDaily}
+	from := start.AddDate(1, 0, 0)
+	o = scheduleOccurrences(daily, from, from.AddDate(0, 0, 2))
+	if len(o) != 2 || !o[0].Equal(from) {
+		t.Fatalf("expected 2 occurrences from a year later, got %v", o)
+	}
+}
+
+func TestRenderICalendar(t *testing.T) {
+	start := time.Date(2023, 6, 1, 20, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	schedules := []*models.Schedule{
+		{
+			Model:         gorm.Model{ID: 3},
+			Title:         "Speedrun, part 2; finale",
+			Description:   strings.Repeat("long description ", 10),
+			StartTime:     start,
+			Duration:      90,
+			Recurrence:    models.ScheduleRecurrenceWeekly,
+			RecurrenceEnd: &end,
+		},
+	}
+
+	calendar := renderICalendar("Room", schedules, start)
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:schedule-3@chocolate\r\n",
+		"DTSTART:20230601T200000Z\r\n",
+		"DTEND:20230601T213000Z\r\n",
+		`SUMMARY:Speedrun\, part 2\; finale` + "\r\n",
+		"RRULE:FREQ=WEEKLY;UNTIL=20230701T195959Z\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(calendar, expected) {
+			t.Fatalf("calendar should contain %q, got:\n%s", expected, calendar)
+		}
+	}
+	for _, line := range strings.Split(calendar, "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("line longer than 75 octets: %q", line)
+		}
+	}
+}
+
+func TestCalendarToken(t *testing.T) {
+	token := CreateCalendarToken(&models.User{Model: gorm.Model{ID: 7}, CalendarTokenVersion: 2})
+	if uid, version, ok := parseCalendarToken(token); !ok || uid != 7 || version != 2 {
+		t.Fatalf("expected user 7 version 2, got %d, %d, %v", uid, version, ok)
+	}
+	// tokens issued before versioning can not be revoked.
+	for _, payload := range []string{"cal=7", "cal=7,v=2x", "g=7,v=2"} {
+		if _, _, ok := parseCalendarToken(common.CreateSignedToken(payload)); ok {
+			t.Fatalf("payload %q should be rejected", payload)
+		}
+	}
+}
@@ -324,6 +324,7 @@ func DeleteUser(username string) cerrors.ChocolateError {
 		return err
 	}
 	tx.Commit()
+	decisionCache.ForgetUser(user.ID)
 	return nil
 }
 
@@ -352,15 +353,27 @@ func UpdateRole(username string, roleName string) cerrors.ChocolateError {
 			Message: "no such role",
 		}
 	}
-	return models.UpdateUserRole(username, roleName)
+	err := models.UpdateUserRole(username, roleName)
+	if err == nil {
+		forgetUserByName(username)
+	}
+	return err
 }
 
 func AddLabelToUser(username string, label string) cerrors.ChocolateError {
-	return models.AddLabelToUser(username, label)
+	err := models.AddLabelToUser(username, label)
+	if err == nil {
+		forgetUserByName(username)
+	}
+	return err
 }
 
 func DeleteUserLabel(username string, label string) cerrors.ChocolateError {
-	return models.DeleteUserLabel(username, label)
+	err := models.DeleteUserLabel(username, label)
+	if err == nil {
+		forgetUserByName(username)
+	}
+	return err
 }
 
 func ModifyUserMaxRoom(username string, count uint) cerrors.ChocolateError {
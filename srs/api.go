@@ -1,21 +1,40 @@
 package srs
 
-func GetSummariesHistory() []*Summaries {
-	return cacheSummries.ToList()
+// the history getters return stats of the named node,
+// an empty name means the default node, nil is returned
+// if there is no such node.
+
+func GetSummariesHistory(node string) []*Summaries {
+	if c := getNodeCaches(node); c != nil {
+		return c.summaries.ToList()
+	}
+	return nil
 }
 
-func GetMemInfoHistory() []*MemInfo {
-	return cacheMemInfos.ToList()
+func GetMemInfoHistory(node string) []*MemInfo {
+	if c := getNodeCaches(node); c != nil {
+		return c.memInfos.ToList()
+	}
+	return nil
 }
 
-func GetVHostsHistory() []*VHosts {
-	return cacheVHosts.ToList()
+func GetVHostsHistory(node string) []*VHosts {
+	if c := getNodeCaches(node); c != nil {
+		return c.vhosts.ToList()
+	}
+	return nil
 }
 
-func GetStreamsHistory() []*Streams {
-	return cacheStreams.ToList()
+func GetStreamsHistory(node string) []*Streams {
+	if c := getNodeCaches(node); c != nil {
+		return c.streams.ToList()
+	}
+	return nil
 }
 
-func GetClientsHistory() []*Clients {
-	return cacheClients.ToList()
+func GetClientsHistory(node string) []*Clients {
+	if c := getNodeCaches(node); c != nil {
+		return c.clients.ToList()
+	}
+	return nil
 }
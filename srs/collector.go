@@ -74,12 +74,18 @@ func (c collector) StartCollect(interval uint) {
 	c.stop = make(chan struct{}, 0)
 	go func() {
 		for {
-			// FIXME: use interface & struct method may be better
-			cacheSummries.Append(collect("summaries", getSummaries))
-			cacheMemInfos.Append(collect("meminfo", getMeminfo))
-			cacheVHosts.Append(collect("vhosts", getVHosts))
-			cacheStreams.Append(collect("streams", getStreams))
-			cacheClients.Append(collect("clients", getClients))
+			RefreshServerIDs()
+			for _, node := range Nodes() {
+				caches := getNodeCaches(node.Name)
+				if caches == nil {
+					continue
+				}
+				caches.summaries.Append(collect("summaries", node.GetSummaries))
+				caches.memInfos.Append(collect("meminfo", node.GetMemInfo))
+				caches.vhosts.Append(collect("vhosts", node.GetVHosts))
+				caches.streams.Append(collect("streams", node.GetStreams))
+				caches.clients.Append(collect("clients", node.GetClients))
+			}
 
 			select {
 			case <-time.After(time.Second * time.Duration(interval)):
@@ -99,20 +105,37 @@ func (c collector) Stop() {
 	c.running = false
 }
 
-var (
-	cacheSummries FixedLengthArray[Summaries]
-	cacheMemInfos FixedLengthArray[MemInfo]
-	cacheVHosts   FixedLengthArray[VHosts]
-	cacheStreams  FixedLengthArray[Streams]
-	cacheClients  FixedLengthArray[Clients]
-)
+type nodeCaches struct {
+	summaries FixedLengthArray[Summaries]
+	memInfos  FixedLengthArray[MemInfo]
+	vhosts    FixedLengthArray[VHosts]
+	streams   FixedLengthArray[Streams]
+	clients   FixedLengthArray[Clients]
+}
+
+// node name -> caches, it is filled once before the
+// collector starts, so reading needs no lock.
+var caches = map[string]*nodeCaches{}
+
+func getNodeCaches(node string) *nodeCaches {
+	if node == "" {
+		if n := Nodes(); len(n) > 0 {
+			node = n[0].Name
+		}
+	}
+	return caches[node]
+}
 
 func initMetricsCollector(nCaches uint, interval uint) {
-	cacheSummries.Init(nCaches)
-	cacheMemInfos.Init(nCaches)
-	cacheVHosts.Init(nCaches)
-	cacheStreams.Init(nCaches)
-	cacheClients.Init(nCaches)
+	for _, node := range Nodes() {
+		c := &nodeCaches{}
+		c.summaries.Init(nCaches)
+		c.memInfos.Init(nCaches)
+		c.vhosts.Init(nCaches)
+		c.streams.Init(nCaches)
+		c.clients.Init(nCaches)
+		caches[node.Name] = c
+	}
 
 	metricsCollector.StartCollect(interval)
 	logrus.Info("srs stats collector start collecting")
@@ -11,29 +11,50 @@ import (
 
 func init() {
 	common.HookPostConfigLoad(func(cfg *common.ChocolateConfig) {
-		if cfg.Srs.ApiAddr == "" || cfg.Srs.RtmpAddr == "" || cfg.Srs.HlsAddr == "" {
-			logrus.Fatalf("invalid config file, missing srs addrs")
+		nodeConfigs := cfg.Srs.Nodes
+		if len(nodeConfigs) == 0 {
+			nodeConfigs = []common.SrsNodeConfig{{
+				Name:     DefaultNodeName,
+				ApiAddr:  cfg.Srs.ApiAddr,
+				HttpAddr: cfg.Srs.HlsAddr,
+				RtmpAddr: cfg.Srs.RtmpAddr,
+			}}
 		}
-		if err := setAddressAndCheck(cfg.Srs.ApiAddr); err != nil {
-			logrus.Fatalf("cannot connect to srs api, check your config file")
-		} else {
-			if cfg.Srs.Stats.CacheNumber == 0 {
-				logrus.Fatalf("invalid srs cache numver")
-			} else if cfg.Srs.Stats.CollectInterval < 10 {
-				logrus.Fatalf("srs collect interval is too short")
+
+		n := make([]*Node, 0, len(nodeConfigs))
+		names := map[string]bool{}
+		for _, nc := range nodeConfigs {
+			if nc.Name == "" || nc.ApiAddr == "" || nc.RtmpAddr == "" || nc.HttpAddr == "" {
+				logrus.Fatalf("invalid config file, missing srs node name or addrs")
+			}
+			if names[nc.Name] {
+				logrus.Fatalf("invalid config file, duplicated srs node %s", nc.Name)
 			}
-			initMetricsCollector(cfg.Srs.Stats.CacheNumber, cfg.Srs.Stats.CollectInterval)
+			names[nc.Name] = true
+
+			node := NewNode(nc.Name, NewServer(nc.ApiAddr, nc.HttpAddr, nc.RtmpAddr), nc.PublicRtmpAddr)
+			if err := checkServer(node.Server); err != nil {
+				logrus.WithError(err).WithField("node", nc.Name).Fatalf("cannot connect to srs api, check your config file")
+			}
+			node.refreshServerID()
+			n = append(n, node)
 		}
+		SetNodes(n)
+
+		if cfg.Srs.Stats.CacheNumber == 0 {
+			logrus.Fatalf("invalid srs cache numver")
+		} else if cfg.Srs.Stats.CollectInterval < 10 {
+			logrus.Fatalf("srs collect interval is too short")
+		}
+		initMetricsCollector(cfg.Srs.Stats.CacheNumber, cfg.Srs.Stats.CollectInterval)
 	})
 }
 
-var srsApiAddr string = ""
-
 func composeUrl(base string, path string) string {
 	if strings.HasSuffix(base, "/") {
 		base = base[:len(base)-1]
 	}
-	if !strings.HasPrefix(base, "http://") {
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
 		base = "http://" + base
 	}
 
@@ -59,9 +80,31 @@ type api struct {
 	clientsUrl   string
 	clustersUrl  string
 	perfStatUrl  string
+	rawUrl       string
+	whipUrl      string
+	whepUrl      string
 }
 
-var apiCollection api
+func newApiCollection(addr string) api {
+	return api{
+		versionUrl:   composeUrl(addr, "/api/v1/versions"),
+		summaryUrl:   composeUrl(addr, "/api/v1/summaries"),
+		rusageUrl:    composeUrl(addr, "/api/v1/rusages"),
+		procStatsUrl: composeUrl(addr, "/api/v1/self_proc_stats"),
+		sysStatsUrl:  composeUrl(addr, "/api/v1/system_proc_stats"),
+		memInfoUrl:   composeUrl(addr, "/api/v1/meminfos"),
+		featuresUrl:  composeUrl(addr, "/api/v1/features"),
+		requestsUrl:  composeUrl(addr, "/api/v1/requests"),
+		vhostsUrl:    composeUrl(addr, "/api/v1/vhosts"),
+		streamsUrl:   composeUrl(addr, "/api/v1/streams/"),
+		clientsUrl:   composeUrl(addr, "/api/v1/clients/"),
+		clustersUrl:  composeUrl(addr, "/api/v1/clusters"),
+		perfStatUrl:  composeUrl(addr, "/api/v1/perf"),
+		rawUrl:       composeUrl(addr, "/api/v1/raw"),
+		whipUrl:      composeUrl(addr, "/rtc/v1/whip/"),
+		whepUrl:      composeUrl(addr, "/rtc/v1/whep/"),
+	}
+}
 
 func isResponseOk(body []byte) bool {
 	var tester struct {
@@ -75,32 +118,14 @@ func isResponseOk(body []byte) bool {
 	return tester.Code == 0
 }
 
-func setAddressAndCheck(addr string) error {
-	versionUrl := composeUrl(addr, "/api/v1/versions")
-	jobj, err := get(versionUrl)
+func checkServer(server Server) error {
+	v, err := server.GetVersion()
 	if err != nil {
 		logrus.WithError(err).Errorf("srs test failed")
 		return errors.New("errors on connecting srs api server")
 	}
-	if !isResponseOk(jobj) {
+	if v.ResponseCode != 0 {
 		return errors.New("the server did not reponds ok when testing connectivity to the srs server")
 	}
-
-	apiCollection = api{
-		versionUrl:   composeUrl(addr, "/api/v1/versions"),
-		summaryUrl:   composeUrl(addr, "/api/v1/summaries"),
-		rusageUrl:    composeUrl(addr, "/api/v1/rusages"),
-		procStatsUrl: composeUrl(addr, "/api/v1/self_proc_stats"),
-		sysStatsUrl:  composeUrl(addr, "/api/v1/system_proc_stats"),
-		memInfoUrl:   composeUrl(addr, "/api/v1/meminfos"),
-		featuresUrl:  composeUrl(addr, "/api/v1/features"),
-		requestsUrl:  composeUrl(addr, "/api/v1/requests"),
-		vhostsUrl:    composeUrl(addr, "/api/v1/vhosts"),
-		streamsUrl:   composeUrl(addr, "/api/v1/streams/"),
-		clientsUrl:   composeUrl(addr, "/api/v1/clients"),
-		clustersUrl:  composeUrl(addr, "/api/v1/clusters"),
-		perfStatUrl:  composeUrl(addr, "/api/v1/perf"),
-	}
-
 	return nil
 }
@@ -0,0 +1,146 @@
+package srs
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// the node name used when no nodes are configured.
+const DefaultNodeName = "default"
+
+// Node is an origin srs server of the cluster.
+type Node struct {
+	Server
+	Name string
+	// PublicRtmpAddr is the rtmp address given to the
+	// streamers assigned to this node, may be empty.
+	PublicRtmpAddr string
+
+	mu sync.Mutex
+	// the `server_id` srs carries in http callbacks,
+	// learned from the api.
+	serverID string
+}
+
+func NewNode(name string, server Server, publicRtmpAddr string) *Node {
+	return &Node{
+		Server:         server,
+		Name:           name,
+		PublicRtmpAddr: publicRtmpAddr,
+	}
+}
+
+func (n *Node) ServerID() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.serverID
+}
+
+// refreshServerID asks the node for its server id, srs
+// may regenerate it after restarting.
+func (n *Node) refreshServerID() error {
+	v, err := n.GetVersion()
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.serverID = v.ServerID
+	n.mu.Unlock()
+	return nil
+}
+
+var (
+	nodesLock sync.RWMutex
+	nodes     []*Node
+)
+
+// SetNodes replaces the nodes of the cluster, the first
+// node becomes the default server.
+func SetNodes(n []*Node) {
+	nodesLock.Lock()
+	nodes = n
+	nodesLock.Unlock()
+	if len(n) > 0 {
+		SetDefault(n[0].Server)
+	}
+}
+
+func Nodes() []*Node {
+	nodesLock.RLock()
+	defer nodesLock.RUnlock()
+	return append([]*Node{}, nodes...)
+}
+
+// GetNode returns nil if there is no such node.
+func GetNode(name string) *Node {
+	for _, node := range Nodes() {
+		if node.Name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// ServerOf returns the server of the named node, rooms
+// recorded before nodes were introduced, or whose node
+// has been removed from config, fall back to default.
+func ServerOf(name *string) Server {
+	if name != nil {
+		if node := GetNode(*name); node != nil {
+			return node.Server
+		}
+	}
+	return Default()
+}
+
+// GetNodeByServerID finds the node sending a callback,
+// it returns nil if no node has the given server id. Ids
+// are refreshed by RefreshServerIDs, not here, callbacks
+// should not wait on every node.
+func GetNodeByServerID(serverId string) *Node {
+	if serverId == "" {
+		return nil
+	}
+	for _, node := range Nodes() {
+		if node.ServerID() == serverId {
+			return node
+		}
+	}
+	return nil
+}
+
+// RefreshServerIDs asks every node for its server id,
+// which changes when srs restarts.
+func RefreshServerIDs() {
+	for _, node := range Nodes() {
+		if err := node.refreshServerID(); err != nil {
+			logrus.WithError(err).WithField("node", node.Name).Warn("error refreshing srs node server id")
+		}
+	}
+}
+
+// PickNode returns the reachable node serving the least
+// streams, nil if none of them is reachable.
+func PickNode() *Node {
+	var picked *Node
+	least := -1
+	for _, node := range Nodes() {
+		streams, err := node.GetStreams()
+		if err != nil {
+			logrus.WithError(err).WithField("node", node.Name).Warn("srs node unreachable when picking node")
+			continue
+		}
+		count := 0
+		for _, stream := range streams.StreamList {
+			if stream.Publish.Active {
+				count++
+			}
+		}
+		if least < 0 || count < least {
+			picked = node
+			least = count
+		}
+	}
+	return picked
+}
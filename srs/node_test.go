@@ -0,0 +1,52 @@
+package srs_test
+
+import (
+	"testing"
+
+	"github.com/sheey11/chocolate/internal/srstest"
+	"github.com/sheey11/chocolate/srs"
+)
+
+func TestNodes(t *testing.T) {
+	busy := srstest.NewFakeServer()
+	busy.ServerID = "vid-busy"
+	busy.AddStream(srs.Stream{ID: "st1"})
+	busy.AddClient(srs.Client{ID: "c1", StreamID: "st1", Publish: true})
+
+	idle := srstest.NewFakeServer()
+	idle.ServerID = "vid-idle"
+	// a stream without publisher does not count.
+	idle.AddStream(srs.Stream{ID: "st2"})
+
+	srs.SetNodes([]*srs.Node{
+		srs.NewNode("busy", busy, ""),
+		srs.NewNode("idle", idle, "live.example.com:1935"),
+	})
+	defer srs.SetNodes(nil)
+
+	if srs.Default() != busy {
+		t.Fatal("the first node should be the default server")
+	}
+	if node := srs.PickNode(); node == nil || node.Name != "idle" {
+		t.Fatalf("expected idle node, got %+v", node)
+	}
+	if srs.GetNodeByServerID("vid-idle") != nil {
+		t.Fatal("server ids should be unknown until refreshed")
+	}
+	srs.RefreshServerIDs()
+	if node := srs.GetNodeByServerID("vid-idle"); node == nil || node.Name != "idle" {
+		t.Fatalf("expected idle node, got %+v", node)
+	}
+	if node := srs.GetNodeByServerID("vid-unknown"); node != nil {
+		t.Fatalf("expected no node, got %+v", node)
+	}
+
+	name := "idle"
+	if srs.ServerOf(&name) != idle {
+		t.Fatal("expected idle server")
+	}
+	unknown := "removed"
+	if srs.ServerOf(&unknown) != busy || srs.ServerOf(nil) != busy {
+		t.Fatal("unknown nodes should fall back to default")
+	}
+}
@@ -3,22 +3,31 @@ package srs
 import (
 	"io/ioutil"
 	"net/http"
-	"time"
 )
 
-var httpClient http.Client
-
-func init() {
-	httpClient = http.Client{
-		Timeout: 5 * time.Second,
+func get(client *http.Client, url string) ([]byte, error) {
+	response, err := client.Get(url)
+	if err != nil {
+		return []byte{}, err
 	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return []byte{}, err
+	}
+	return body, nil
 }
 
-func get(url string) ([]byte, error) {
-	response, err := httpClient.Get(url)
+func del(client *http.Client, url string) ([]byte, error) {
+	request, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return []byte{}, err
+	}
+	response, err := client.Do(request)
 	if err != nil {
 		return []byte{}, err
 	}
+	defer response.Body.Close()
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		return []byte{}, err
@@ -0,0 +1,94 @@
+package srs
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+type RtcKind string
+
+const (
+	// WHIP, WebRTC-HTTP ingestion protocol, for publishing.
+	RtcKindWhip RtcKind = "whip"
+	// WHEP, WebRTC-HTTP egress protocol, for playing.
+	RtcKindWhep RtcKind = "whep"
+)
+
+type RtcAnswer struct {
+	StatusCode  int
+	ContentType string
+	// query part of the session resource url given by
+	// srs, it is required when tearing down the session.
+	ResourceQuery string
+	Body          []byte
+}
+
+func (s *apiServer) rtcUrl(kind RtcKind, query url.Values) (string, error) {
+	var base string
+	switch kind {
+	case RtcKindWhip:
+		base = s.api.whipUrl
+	case RtcKindWhep:
+		base = s.api.whepUrl
+	default:
+		return "", errors.New("unknown rtc kind")
+	}
+	return base + "?" + query.Encode(), nil
+}
+
+// ExchangeSdp posts the sdp offer to srs rtc api and
+// returns the answer, `query` should at least contain
+// `app` and `stream`, it will be passed to srs http
+// hooks as param.
+func (s *apiServer) ExchangeSdp(kind RtcKind, query url.Values, offer []byte) (*RtcAnswer, error) {
+	u, err := s.rtcUrl(kind, query)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.client.Post(u, "application/sdp", bytes.NewReader(offer))
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to post sdp offer to srs"), err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to read srs sdp answer"), err)
+	}
+
+	answer := &RtcAnswer{
+		StatusCode:  response.StatusCode,
+		ContentType: response.Header.Get("Content-Type"),
+		Body:        body,
+	}
+	if location := response.Header.Get("Location"); location != "" {
+		if l, err := url.Parse(location); err == nil {
+			answer.ResourceQuery = l.RawQuery
+		}
+	}
+	return answer, nil
+}
+
+// TeardownRtcSession deletes the session identified by
+// the query srs responded when exchanging sdp.
+func (s *apiServer) TeardownRtcSession(kind RtcKind, query url.Values) (int, error) {
+	u, err := s.rtcUrl(kind, query)
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequest(http.MethodDelete, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, errors.Join(errors.New("unable to teardown srs rtc session"), err)
+	}
+	response.Body.Close()
+	return response.StatusCode, nil
+}
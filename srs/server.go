@@ -0,0 +1,206 @@
+package srs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Server is the typed api of a srs server, the default
+// one is built from config. Tests may substitute it
+// with an in-process fake by `SetDefault`.
+type Server interface {
+	// HttpUrl returns the url of path on the srs http
+	// server, where hls and flv are served.
+	HttpUrl(path string) string
+	// RtmpUrl returns the rtmp url of path.
+	RtmpUrl(path string) string
+
+	GetVersion() (*Version, error)
+	GetSummaries() (*Summaries, error)
+	GetMemInfo() (*MemInfo, error)
+	GetFeatures() (*Features, error)
+	GetVHosts() (*VHosts, error)
+	GetStreams() (*Streams, error)
+	GetStream(id string) (*Stream, error)
+	GetClients() (*Clients, error)
+	GetClient(id string) (*Client, error)
+	// KickClient disconnects a client, kicking the
+	// publisher cuts off the stream.
+	KickClient(id string) error
+	// Reload asks srs to reload its config file.
+	Reload() error
+
+	ExchangeSdp(kind RtcKind, query url.Values, offer []byte) (*RtcAnswer, error)
+	TeardownRtcSession(kind RtcKind, query url.Values) (int, error)
+}
+
+var (
+	defaultServerLock sync.RWMutex
+	defaultServer     Server
+)
+
+// Default returns the srs server built from config.
+func Default() Server {
+	defaultServerLock.RLock()
+	defer defaultServerLock.RUnlock()
+	return defaultServer
+}
+
+func SetDefault(server Server) {
+	defaultServerLock.Lock()
+	defer defaultServerLock.Unlock()
+	defaultServer = server
+}
+
+// ErrNotFound is returned when srs does not know the
+// requested client or stream.
+var ErrNotFound = errors.New("srs: no such object")
+
+// codes of srs when the stream or client is not found.
+const (
+	srsCodeStreamNotFound = 2048
+	srsCodeClientNotFound = 2049
+)
+
+type apiServer struct {
+	api      api
+	httpAddr string
+	rtmpAddr string
+	client   *http.Client
+}
+
+// NewServer creates a client of the srs server, `apiAddr`
+// is the http api address, `httpAddr` is the http server
+// address and `rtmpAddr` is the rtmp server address, e.g.
+// `srs:1985`, `srs:8080`, `srs:1935`.
+func NewServer(apiAddr string, httpAddr string, rtmpAddr string) Server {
+	return &apiServer{
+		api:      newApiCollection(apiAddr),
+		httpAddr: httpAddr,
+		rtmpAddr: rtmpAddr,
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+func (s *apiServer) HttpUrl(path string) string {
+	return composeUrl(s.httpAddr, path)
+}
+
+func (s *apiServer) RtmpUrl(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		path = "/" + path
+	}
+	return "rtmp://" + s.rtmpAddr + path
+}
+
+func (s *apiServer) GetVersion() (*Version, error) {
+	return _getStatsContainedInData(s.client, s.api.versionUrl, &Version{})
+}
+
+func (s *apiServer) GetSummaries() (*Summaries, error) {
+	return _getStatsContainedInData(s.client, s.api.summaryUrl, &Summaries{})
+}
+
+func (s *apiServer) GetMemInfo() (*MemInfo, error) {
+	return _getStatsContainedInData(s.client, s.api.memInfoUrl, &MemInfo{})
+}
+
+func (s *apiServer) GetFeatures() (*Features, error) {
+	return _getStatsContainedInData(s.client, s.api.featuresUrl, &Features{})
+}
+
+func (s *apiServer) GetVHosts() (*VHosts, error) {
+	return _getStats(s.client, s.api.vhostsUrl, &VHosts{})
+}
+
+func (s *apiServer) GetStreams() (*Streams, error) {
+	return _getStats(s.client, s.api.streamsUrl, &Streams{})
+}
+
+func (s *apiServer) GetClients() (*Clients, error) {
+	return _getStats(s.client, s.api.clientsUrl, &Clients{})
+}
+
+func (s *apiServer) GetStream(id string) (*Stream, error) {
+	v := &struct {
+		ServerInfo
+		Stream *Stream `json:"stream"`
+	}{}
+	if err := s.getObject(s.api.streamsUrl+url.PathEscape(id), v, &v.ServerInfo); err != nil {
+		return nil, err
+	}
+	if v.Stream == nil {
+		return nil, ErrNotFound
+	}
+	return v.Stream, nil
+}
+
+func (s *apiServer) GetClient(id string) (*Client, error) {
+	v := &struct {
+		ServerInfo
+		Client *Client `json:"client"`
+	}{}
+	if err := s.getObject(s.api.clientsUrl+url.PathEscape(id), v, &v.ServerInfo); err != nil {
+		return nil, err
+	}
+	if v.Client == nil {
+		return nil, ErrNotFound
+	}
+	return v.Client, nil
+}
+
+func (s *apiServer) getObject(u string, v any, info *ServerInfo) error {
+	body, err := get(s.client, u)
+	if err != nil {
+		return errors.Join(errors.New("unable to retrive srs object"), err)
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return errors.Join(errors.New("unable to unmarshal srs object"), err)
+	}
+	return codeToError(info.ResponseCode)
+}
+
+func (s *apiServer) KickClient(id string) error {
+	body, err := del(s.client, s.api.clientsUrl+url.PathEscape(id))
+	if err != nil {
+		return errors.Join(errors.New("unable to kick srs client"), err)
+	}
+	return responseToError(body)
+}
+
+func (s *apiServer) Reload() error {
+	body, err := get(s.client, s.api.rawUrl+"?rpc=reload")
+	if err != nil {
+		return errors.Join(errors.New("unable to reload srs"), err)
+	}
+	return responseToError(body)
+}
+
+func codeToError(code uint) error {
+	switch code {
+	case 0:
+		return nil
+	case srsCodeStreamNotFound, srsCodeClientNotFound:
+		return ErrNotFound
+	default:
+		return fmt.Errorf("srs responded with code %d", code)
+	}
+}
+
+func responseToError(body []byte) error {
+	if isResponseOk(body) {
+		return nil
+	}
+	info := ServerInfo{}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return errors.Join(errors.New("unable to unmarshal srs response"), err)
+	}
+	return codeToError(info.ResponseCode)
+}
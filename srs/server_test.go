@@ -0,0 +1,91 @@
+package srs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestApi(t *testing.T) (Server, *[]string) {
+	requests := []string{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch {
+		case r.URL.Path == "/api/v1/clients/c1" && r.Method == http.MethodGet:
+			w.Write([]byte(`{"code":0,"server":"s","client":{"id":"c1","vhost":"v","stream":"st1","publish":true}}`))
+		case r.URL.Path == "/api/v1/clients/c1" && r.Method == http.MethodDelete:
+			w.Write([]byte(`{"code":0}`))
+		case r.URL.Path == "/api/v1/streams/st1":
+			w.Write([]byte(`{"code":0,"server":"s","stream":{"id":"st1","name":"1","app":"live","clients":3,"publish":{"active":true,"cid":"c1"}}}`))
+		case r.URL.Path == "/api/v1/raw":
+			w.Write([]byte(`{"code":0}`))
+		case strings.HasPrefix(r.URL.Path, "/api/v1/clients/"):
+			w.Write([]byte(`{"code":2049}`))
+		default:
+			w.Write([]byte(`{"code":2048}`))
+		}
+	}))
+	t.Cleanup(api.Close)
+	return NewServer(api.URL, "srs:8080", "srs:1935"), &requests
+}
+
+func TestServerClientAndStream(t *testing.T) {
+	server, _ := newTestApi(t)
+
+	client, err := server.GetClient("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.StreamID != "st1" || !client.Publish {
+		t.Fatalf("unexpected client %+v", client)
+	}
+
+	stream, err := server.GetStream(client.StreamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream.ClientCount != 3 || !stream.Publish.Active || stream.Publish.CID != "c1" {
+		t.Fatalf("unexpected stream %+v", stream)
+	}
+
+	if _, err := server.GetClient("c2"); err != ErrNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if _, err := server.GetStream("st2"); err != ErrNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestServerKickAndReload(t *testing.T) {
+	server, requests := newTestApi(t)
+
+	if err := server.KickClient("c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.KickClient("c2"); err != ErrNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := server.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"DELETE /api/v1/clients/c1",
+		"DELETE /api/v1/clients/c2",
+		"GET /api/v1/raw?rpc=reload",
+	}
+	if strings.Join(*requests, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected requests %v", *requests)
+	}
+}
+
+func TestServerUrls(t *testing.T) {
+	server := NewServer("srs:1985", "srs:8080/", "srs:1935")
+	if u := server.HttpUrl("/live/1.flv"); u != "http://srs:8080/live/1.flv" {
+		t.Fatalf("unexpected http url %s", u)
+	}
+	if u := server.RtmpUrl("live/1"); u != "rtmp://srs:1935/live/1" {
+		t.Fatalf("unexpected rtmp url %s", u)
+	}
+}
@@ -3,6 +3,7 @@ package srs
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
 	"time"
 )
 
@@ -18,8 +19,8 @@ type statTypes interface {
 // funcs in this package is extremly confusion,
 // all function start with an underscroe is a
 // helper function.
-func _getStatsContainedInData[T Version | Summaries | MemInfo | Features](url string, i *T) (*T, error) {
-	body, err := get(url)
+func _getStatsContainedInData[T Version | Summaries | MemInfo | Features](client *http.Client, url string, i *T) (*T, error) {
+	body, err := get(client, url)
 	if err != nil {
 		return i, errors.Join(errors.New("unable to retrive server info"), err)
 	}
@@ -41,8 +42,8 @@ func _getStatsContainedInData[T Version | Summaries | MemInfo | Features](url st
 	return i, nil
 }
 
-func _getStats[T VHosts | Streams | Clients](url string, i *T) (*T, error) {
-	body, err := get(url)
+func _getStats[T VHosts | Streams | Clients](client *http.Client, url string, i *T) (*T, error) {
+	body, err := get(client, url)
 	if err != nil {
 		return i, errors.Join(errors.New("unable to retrive server info"), err)
 	}
@@ -54,37 +55,5 @@ func _getStats[T VHosts | Streams | Clients](url string, i *T) (*T, error) {
 }
 
 func GetVersion() (*Version, error) {
-	v := &Version{}
-	v, err := _getStatsContainedInData(apiCollection.versionUrl, v)
-	return v, err
-}
-
-func getSummaries() (*Summaries, error) {
-	v := &Summaries{}
-	return _getStatsContainedInData(apiCollection.summaryUrl, v)
-}
-
-func getMeminfo() (*MemInfo, error) {
-	v := &MemInfo{}
-	return _getStatsContainedInData(apiCollection.memInfoUrl, v)
-}
-
-func getFeatures() (*Features, error) {
-	v := &Features{}
-	return _getStatsContainedInData(apiCollection.featuresUrl, v)
-}
-
-func getVHosts() (*VHosts, error) {
-	v := &VHosts{}
-	return _getStats(apiCollection.vhostsUrl, v)
-}
-
-func getStreams() (*Streams, error) {
-	v := &Streams{}
-	return _getStats(apiCollection.streamsUrl, v)
-}
-
-func getClients() (*Clients, error) {
-	v := &Clients{}
-	return _getStats(apiCollection.clientsUrl, v)
+	return Default().GetVersion()
 }
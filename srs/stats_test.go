@@ -7,12 +7,12 @@ import (
 )
 
 func TestVersion(t *testing.T) {
-	err := setAddressAndCheck("172.29.16.1:1985")
-	if err != nil {
+	node := NewNode("test", NewServer("172.29.16.1:1985", "", ""), "")
+	if err := checkServer(node.Server); err != nil {
 		t.Fatal(err)
 	}
 
-	v, err := GetVersion()
+	v, err := node.GetVersion()
 	if err != nil {
 		t.Fatal(err)
 	}
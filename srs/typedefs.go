@@ -138,7 +138,7 @@ type Stream struct {
 	RecvBytes     uint   `json:"recv_bytes"`
 	Stat          struct {
 		Recv30sKBytes uint `json:"recv_30s"`
-		Send30sKBytes uint `json:"sned_30s"`
+		Send30sKBytes uint `json:"send_30s"`
 	} `json:"kbps"`
 	Publish struct {
 		Active bool
@@ -62,8 +62,9 @@ export interface OwnedRoomInfoResponse extends RoomInfoResponse {
     uid: string
     permission_type: "whitelist" | "blacklist"
     permission_items: {
-        type: "user" | "label"
+        type: "user" | "label" | "role"
         label: string | null
+        role: string | null
         user_id: number | null
         username: string | null
     }[]
@@ -277,9 +278,10 @@ export interface AdminRoomDetailResponse extends ChocolcateResponse {
         permission_type: string
         permission_items: {
             label: string | null
+            role: string | null
             user_id: number | null
             username: string | null
-            type: 'user' | 'label'
+            type: 'user' | 'label' | 'role'
         }[]
         last_streaming: string
         srs_stream: null | {
@@ -435,10 +437,11 @@ export interface UserPasswordUpdatePayload {
 
 export interface StartStreamingResponse extends ChocolcateResponse {
     streamkey: string
+    server: string
 }
 
 export interface PermItemAutoComplete {
-    type: "label" | "user",
+    type: "label" | "user" | "role",
     name: string
 }
 
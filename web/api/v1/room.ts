@@ -20,11 +20,11 @@ export async function updateRoomPermissionType(id: number, type: "whitelist" | "
     return PUT_WithoutData<ChocolcateResponse>(`/api/v1/rooms/${id}/permission-type/${type}`)
 }
 
-export async function deleteRoomPermissionItem(id: number, type: "user" | "label", item: string): Promise<ChocolcateResponse> {
+export async function deleteRoomPermissionItem(id: number, type: "user" | "label" | "role", item: string): Promise<ChocolcateResponse> {
     return DELETE<ChocolcateResponse>(`/api/v1/rooms/${id}/permission/${type}/${item}`)
 }
 
-export async function addRoomPermissionItem(id: number, type: "user" | "label", item: string): Promise<ChocolcateResponse> {
+export async function addRoomPermissionItem(id: number, type: "user" | "label" | "role", item: string): Promise<ChocolcateResponse> {
     return PUT_WithoutData<ChocolcateResponse>(`/api/v1/rooms/${id}/permission/${type}/${item}`)
 }
 
@@ -40,7 +40,7 @@ export async function stopStreaming(id: number): Promise<StartStreamingResponse>
     return PATCH_WithoutData<StartStreamingResponse>(`/api/v1/rooms/${id}/stop-streaming`)
 }
 
-export async function autoCompletePermItem(id: number, type: "label" | "user", prefix: string): Promise<PermItemAutoCompleteResponse> {
+export async function autoCompletePermItem(id: number, type: "label" | "user" | "role", prefix: string): Promise<PermItemAutoCompleteResponse> {
     return GET<PermItemAutoCompleteResponse>(`/api/v1/rooms/${id}/permission/${type}/auto-complete`, { prefix })
 }
 
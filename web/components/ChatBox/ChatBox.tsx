@@ -122,6 +122,13 @@ export default function ChatBox({ websocketUrl, onCutoff, onStartStreaming }: Ch
         } else if(message.type === 'start_streaming') {
             setTimeout(onStartStreaming, 1000)
             return
+        } else if(message.type === 'policy_warning') {
+            sendToViewer({
+                id: chatId.current,
+                adminMessageContent: message.content,
+            })
+            increaseChatId()
+            return
         } else if (message.type !== 'chat') {
             return
         }
@@ -197,6 +197,7 @@ export default function ProfilePage() {
   const [ permissionTypeHelpShow, setPermissionTypeHelpShow ] = useState<boolean>(false)
   const [ roomPermissionType, setRoomPermissionType ] = useState<'blacklist' | 'whitelist'>('blacklist')
   const [ roomStreamKey, setRoomStreamKey] = useState<string | null>(null)
+  const [ roomStreamServer, setRoomStreamServer] = useState<string | null>(null)
   const [ passwordChangeErrorI18nKey, setPasswordChangeErrorI18nKey ] = useState<string | undefined>(undefined)
 
   const [ deleteConfirmDialogOpen, setDeleteConfirmDialogOpen ] = useState<boolean>(false)
@@ -258,6 +259,7 @@ export default function ProfilePage() {
     if(!selectedRoom) return
     setRoomPermissionType(selectedRoom.permission_type)
     setRoomStreamKey(null)
+    setRoomStreamServer(null)
     reloadSelectedRoomDetail(selectedRoom.id)
     if(titleRef.current) {
       titleRef.current.value = selectedRoom.title
@@ -290,6 +292,7 @@ export default function ProfilePage() {
     startStreaming(id)
       .then(r => {
         setRoomStreamKey(r.streamkey)
+        setRoomStreamServer(r.server || null)
         reloadInfo()
         reloadSelectedRoomDetail(id)
         showSuccessMessage()
@@ -301,6 +304,7 @@ export default function ProfilePage() {
     stopStreaming(id)
       .then(() => {
         setRoomStreamKey(null)
+        setRoomStreamServer(null)
         reloadInfo()
         reloadSelectedRoomDetail(id)
         showSuccessMessage()
@@ -710,7 +714,7 @@ export default function ProfilePage() {
                             { localize(lang, "stream-server") }
                           </dt>
                           <dd className="mt-1 text-sm leading-6 text-gray-700 sm:col-span-2 sm:mt-0 code select-all">
-                            {`rtmp://${ location.host.replace(/:\d+$/, '') }:1935/live`}
+                            { roomStreamServer ?? `rtmp://${ location.host.replace(/:\d+$/, '') }:1935/live` }
                           </dd>
                         </div>
                         <div className="px-4 py-4 sm:grid sm:grid-cols-3 sm:gap-4 sm:px-0">